@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"os/signal"
 	"syscall"
 
@@ -26,6 +27,13 @@ func main() {
 	log.Printf("Build date: %s\n", buildDate)
 	log.Printf("Build commit: %s\n", buildCommit)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("failed to migrate: %s", err)
+		}
+		return
+	}
+
 	cfg, err := app.NewConfig()
 	if err != nil {
 		log.Fatalf("failed to get new config: %s", err)
@@ -78,6 +86,30 @@ func main() {
 		return nil
 	})
 
+	g.Go(func() error {
+		if err := a.StartSweeper(ctx); err != nil {
+			return fmt.Errorf("service sweeper error: %w", err)
+		}
+
+		return nil
+	})
+
+	g.Go(func() error {
+		if err := a.StartClickRecorder(ctx); err != nil {
+			return fmt.Errorf("service click recorder error: %w", err)
+		}
+
+		return nil
+	})
+
+	g.Go(func() error {
+		if err := a.StartFileStorage(ctx); err != nil {
+			return fmt.Errorf("file storage error: %w", err)
+		}
+
+		return nil
+	})
+
 	if err := g.Wait(); err != nil {
 		log.Fatalf("group error: %s", err)
 	}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/lks-go/url-shortener/migrations"
+)
+
+const migrateUsage = `Usage: shortener migrate [-d dsn] up|down [steps]|status
+
+Commands:
+  up      apply all migrations which aren't applied yet
+  down    roll back the given count of the latest migrations, 1 by default
+  status  print all migrations and the time they were applied at
+
+Flags:
+`
+
+// runMigrate runs the migrate subcommand with its arguments
+func runMigrate(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, migrateUsage)
+		fs.PrintDefaults()
+	}
+
+	dsn := fs.String("d", os.Getenv("DATABASE_DSN"), "Database connection string")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("migrate command is required")
+	}
+
+	if *dsn == "" {
+		return errors.New("database connection string is required, set it by -d or DATABASE_DSN")
+	}
+
+	db, err := sql.Open("pgx", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database connection: %w", err)
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	switch cmd := fs.Arg(0); cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mg := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "no migrations to apply")
+		}
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			steps, err = strconv.Atoi(fs.Arg(1))
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid count of steps %s", fs.Arg(1))
+			}
+		}
+
+		rolledBack, err := m.Down(ctx, steps)
+		for _, mg := range rolledBack {
+			fmt.Fprintf(out, "rolled back %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(rolledBack) == 0 {
+			fmt.Fprintln(out, "no migrations to roll back")
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %s", cmd)
+	}
+
+	return nil
+}
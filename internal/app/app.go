@@ -2,28 +2,39 @@ package app
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chiMw "github.com/go-chi/chi/v5/middleware"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 
 	"github.com/lks-go/url-shortener/internal/lib/cert"
+	"github.com/lks-go/url-shortener/internal/lib/jwt"
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
 	"github.com/lks-go/url-shortener/internal/lib/random"
+	"github.com/lks-go/url-shortener/internal/lib/realip"
 	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/service/clickrecorder"
+	"github.com/lks-go/url-shortener/internal/service/policy"
 	"github.com/lks-go/url-shortener/internal/service/urldeleter"
+	"github.com/lks-go/url-shortener/internal/service/urlsweeper"
+	"github.com/lks-go/url-shortener/internal/transport/cachestorage"
 	"github.com/lks-go/url-shortener/internal/transport/dbstorage"
 	"github.com/lks-go/url-shortener/internal/transport/grpchandler"
 	"github.com/lks-go/url-shortener/internal/transport/httphandlers"
 	"github.com/lks-go/url-shortener/internal/transport/infilestorage"
 	"github.com/lks-go/url-shortener/internal/transport/inmemstorage"
 	"github.com/lks-go/url-shortener/internal/transport/interceptor"
+	"github.com/lks-go/url-shortener/internal/transport/kvstorage"
 	"github.com/lks-go/url-shortener/internal/transport/middleware"
+	"github.com/lks-go/url-shortener/internal/transport/redisstorage"
 	"github.com/lks-go/url-shortener/migrations"
 	"github.com/lks-go/url-shortener/pkg/proto"
 )
@@ -39,17 +50,31 @@ type App struct {
 	Config         Config
 	handler        http.Handler
 	serviceDeleter Service
+	serviceSweeper Service
+	clickRecorder  Service
 	grpcHandler    proto.URLShortenerServer
-
-	pool *sql.DB
+	adminHandler   proto.AdminServiceServer
+	banChecker     interceptor.BanChecker
+	keyring        *jwt.Keyring
+	oidc           *oidc.Provider
+	realIP         *realip.Resolver
+
+	pool        *pgxpool.Pool
+	redis       *redis.Client
+	kvStorage   *kvstorage.Storage
+	fileStorage *infilestorage.Storage
 }
 
 // Build builds the application
 func (a *App) Build() error {
 	var (
-		storage service.URLStorage
-		pool    *sql.DB
-		err     error
+		storage           service.URLStorage
+		clickStorage      service.ClickStorage
+		revisionStorage   service.RevisionStorage
+		accountStorage    service.AccountStorage
+		moderationStorage service.ModerationStorage
+		pool              *pgxpool.Pool
+		err               error
 	)
 
 	switch {
@@ -59,24 +84,100 @@ func (a *App) Build() error {
 			return fmt.Errorf("failed to setup database: %w", err)
 		}
 
-		if err := migrations.RunUp(pool); err != nil {
+		if err := runMigrations(pool); err != nil {
 			return fmt.Errorf("failed to run migrations: %w", err)
 		}
 
-		storage = dbstorage.New(pool)
+		db := dbstorage.New(pool)
+		storage, clickStorage, revisionStorage, accountStorage, moderationStorage = db, db, db, db, db
+	case a.Config.RedisAddr != "":
+		client, err := setupRedis(a.Config.RedisAddr)
+		if err != nil {
+			return fmt.Errorf("failed to setup redis: %w", err)
+		}
+
+		redisStorage := redisstorage.New(client)
+		storage, clickStorage = redisStorage, redisStorage
+		a.redis = client
+	case a.Config.KVStoragePath != "":
+		kvStorage, err := kvstorage.New(a.Config.KVStoragePath)
+		if err != nil {
+			return fmt.Errorf("failed to setup kv storage: %w", err)
+		}
+		storage, clickStorage = kvStorage, kvStorage
+		a.kvStorage = kvStorage
 	case a.Config.FileStoragePath != "":
-		storage = infilestorage.New(a.Config.FileStoragePath)
+		fileStorage, err := infilestorage.New(infilestorage.Config{
+			UrlsFilename:    a.Config.FileStoragePath,
+			SyncPolicy:      a.Config.FileStorageConfig.SyncPolicy,
+			SyncInterval:    a.Config.FileStorageConfig.SyncInterval,
+			CompactInterval: a.Config.FileStorageConfig.CompactInterval,
+			Recover:         a.Config.FileStorageConfig.Recover,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to setup file storage: %w", err)
+		}
+		storage, clickStorage = fileStorage, fileStorage
+		a.fileStorage = fileStorage
 	default:
-		storage = inmemstorage.MustNew(make(map[string]string))
+		memStorage := inmemstorage.MustNew(make(map[string]string))
+		storage, clickStorage, revisionStorage, accountStorage, moderationStorage = memStorage, memStorage, memStorage, memStorage, memStorage
 	}
 
-	s := service.New(service.Config{IDSize: 8}, service.Dependencies{
-		Storage:      storage,
-		RandomString: random.NewString,
-	})
+	deps := service.Dependencies{
+		ClickStorage:      clickStorage,
+		RevisionStorage:   revisionStorage,
+		AccountStorage:    accountStorage,
+		ModerationStorage: moderationStorage,
+		Policy:            policy.New(a.Config.Admins),
+		RandomString:      random.NewString,
+	}
+
+	if a.Config.CacheConfig.Size > 0 {
+		cached := cachestorage.New(cachestorage.Config(a.Config.CacheConfig), storage)
+		storage, deps.Cache = cached, cached
+	}
+	deps.Storage = storage
+
+	s := service.New(service.Config{
+		IDSize:         8,
+		AliasAlphabet:  a.Config.AliasConfig.Alphabet,
+		AliasMinLength: a.Config.AliasConfig.MinLength,
+		AliasMaxLength: a.Config.AliasConfig.MaxLength,
+	}, deps)
 
 	d := urldeleter.NewDeleter(urldeleter.Config{}, urldeleter.Deps{Storage: storage})
-	httpHandlers, err := httphandlers.New(httphandlers.Config(a.Config.HTTPHandlerConfig), httphandlers.Dependencies{Service: s, Deleter: d})
+	sw := urlsweeper.NewSweeper(urlsweeper.Config{Interval: a.Config.SweepInterval}, urlsweeper.Deps{Storage: storage})
+	cr := clickrecorder.NewRecorder(clickrecorder.Config{}, clickrecorder.Deps{Storage: clickStorage})
+	keyring, err := setupKeyring(a.Config.JWTConfig)
+	if err != nil {
+		return fmt.Errorf("failed to setup jwt keyring: %w", err)
+	}
+
+	resolver, err := realip.NewResolver(a.Config.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+
+	handlerDeps := httphandlers.Dependencies{
+		Service:       s,
+		Deleter:       d,
+		ClickRecorder: cr,
+		TokenBuilder:  keyring,
+	}
+
+	var oidcProvider *oidc.Provider
+	if a.Config.OIDCConfig.Issuer != "" {
+		oidcProvider, err = oidc.Discover(context.Background(), oidc.Config(a.Config.OIDCConfig), &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			return fmt.Errorf("failed to discover oidc provider: %w", err)
+		}
+
+		// set only if configured, the handlers check the interface for nil
+		handlerDeps.OIDCProvider = oidcProvider
+	}
+
+	httpHandlers, err := httphandlers.New(httphandlers.Config(a.Config.HTTPHandlerConfig), handlerDeps)
 	if err != nil {
 		return fmt.Errorf("failed to get new http handler: %w", err)
 	}
@@ -85,7 +186,8 @@ func (a *App) Build() error {
 	r.Use(
 		middleware.WithRequestLogger,
 		chiMw.Recoverer,
-		middleware.WithAuth,
+		middleware.WithRealIP(resolver),
+		middleware.WithAuth(keyring, s, oidcProvider),
 		middleware.WithCompressor,
 	)
 
@@ -93,16 +195,65 @@ func (a *App) Build() error {
 		r.Use(middleware.WithForbidden)
 	}
 
-	r.Post("/", httpHandlers.ShortURL)
 	r.Get("/{id}", httpHandlers.Redirect)
-	r.Post("/api/shorten", httpHandlers.ShortenURL)
-	r.Post("/api/shorten/batch", httpHandlers.ShortenBatchURL)
-	r.Get("/api/user/urls", httpHandlers.UsersURLs)
-	r.Delete("/api/user/urls", httpHandlers.Delete)
 	r.Get("/api/internal/stats", httpHandlers.Stats)
+	r.Post("/api/user/signup", httpHandlers.SignUp)
+	r.Post("/api/user/login", httpHandlers.Login)
+	r.Get("/api/auth/oidc/login", httpHandlers.OIDCLogin)
+	r.Get("/api/auth/oidc/callback", httpHandlers.OIDCCallback)
+
+	// requests of banned users are rejected, public routes above don't act on behalf of the user
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.WithBanCheck(s))
+
+		// requests authenticated by API keys are limited by scopes of the keys
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithScope(service.ScopeShorten))
+
+			r.Post("/", httpHandlers.ShortURL)
+			r.Post("/api/shorten", httpHandlers.ShortenURL)
+			r.Post("/api/shorten/batch", httpHandlers.ShortenBatchURL)
+			r.Post("/api/user/urls/import", httpHandlers.ImportURLs)
+			r.Patch("/api/user/urls/{code}", httpHandlers.UpdateURL)
+			r.Post("/api/user/urls/{code}/restore", httpHandlers.RestoreURL)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithScope(service.ScopeRead))
+
+			r.Get("/api/user/urls", httpHandlers.UsersURLs)
+			r.Get("/api/user/urls/export", httpHandlers.ExportURLs)
+			r.Get("/api/user/urls/{code}/history", httpHandlers.URLHistory)
+		})
+		r.With(middleware.WithScope(service.ScopeDelete)).Delete("/api/user/urls", httpHandlers.Delete)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithScope(service.ScopeStats))
+
+			r.Get("/api/user/urls/{code}/stats", httpHandlers.URLStats)
+			r.Get("/api/user/urls/{code}/stats/aggregate", httpHandlers.ClickStats)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithScope(service.ScopeAccount))
+
+			r.Post("/api/user/keys", httpHandlers.CreateAPIKey)
+			r.Get("/api/user/keys", httpHandlers.APIKeys)
+			r.Delete("/api/user/keys/{id}", httpHandlers.RevokeAPIKey)
+		})
+		// the scope only keeps API keys out, roles of users are checked by the policy of the service
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithScope(service.ScopeAdmin))
+
+			r.Get("/api/admin/urls/{code}", httpHandlers.AdminURL)
+			r.Delete("/api/admin/urls/{code}", httpHandlers.AdminDeleteURL)
+			r.Put("/api/admin/urls/{code}/block", httpHandlers.AdminBlockURL)
+			r.Delete("/api/admin/urls/{code}/block", httpHandlers.AdminUnblockURL)
+			r.Get("/api/admin/users/{id}/urls", httpHandlers.AdminUserURLs)
+			r.Put("/api/admin/users/{id}/ban", httpHandlers.AdminBanUser)
+			r.Delete("/api/admin/users/{id}/ban", httpHandlers.AdminUnbanUser)
+		})
+	})
 
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-		if err := pool.Ping(); err != nil {
+		if pool == nil || pool.Ping(r.Context()) != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -116,9 +267,16 @@ func (a *App) Build() error {
 	}
 
 	a.grpcHandler = grpcHandler
+	a.adminHandler = grpchandler.NewAdmin(grpchandler.Config(a.Config.GRPCHandlerConfig), &grpchandler.AdminDeps{Service: s})
+	a.banChecker = s
+	a.keyring = keyring
+	a.oidc = oidcProvider
+	a.realIP = resolver
 	a.pool = pool
 	a.handler = r
 	a.serviceDeleter = d
+	a.serviceSweeper = sw
+	a.clickRecorder = cr
 
 	return nil
 }
@@ -127,6 +285,11 @@ func (a *App) Build() error {
 func (a *App) StartHTTPServer(ctx context.Context) error {
 	idleConnsClosed := make(chan struct{})
 
+	listen, err := a.listen(a.Config.NetAddress.String())
+	if err != nil {
+		return fmt.Errorf("filed to start listen address %s: %w", a.Config.NetAddress.String(), err)
+	}
+
 	srv := http.Server{
 		Addr:    a.Config.NetAddress.String(),
 		Handler: a.handler,
@@ -155,7 +318,7 @@ func (a *App) StartHTTPServer(ctx context.Context) error {
 			return fmt.Errorf("failed to get new cert: %w", err)
 		}
 
-		if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil {
+		if err := srv.ServeTLS(listen, certFile, keyFile); err != nil {
 			return fmt.Errorf("filed to listern and serve TLS: %w", err)
 		}
 
@@ -164,7 +327,7 @@ func (a *App) StartHTTPServer(ctx context.Context) error {
 		return nil
 	}
 
-	if err := srv.ListenAndServe(); err != nil {
+	if err := srv.Serve(listen); err != nil {
 		return fmt.Errorf("filed to listern and serve: %w", err)
 	}
 
@@ -185,43 +348,178 @@ func (a *App) StartDeleter(ctx context.Context) error {
 	return nil
 }
 
+// StartSweeper starts service of deleting expired URLs
+func (a *App) StartSweeper(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		a.serviceSweeper.Stop()
+	}()
+
+	a.serviceSweeper.Start()
+
+	return nil
+}
+
+// StartClickRecorder starts service of recording visits of short URLs
+func (a *App) StartClickRecorder(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		a.clickRecorder.Stop()
+	}()
+
+	a.clickRecorder.Start()
+
+	return nil
+}
+
+// StartFileStorage starts syncing and compaction of the file storage if it's used
+func (a *App) StartFileStorage(ctx context.Context) error {
+	if a.fileStorage == nil {
+		return nil
+	}
+
+	// the file is closed by Exit when the servers are shut down and can't write to it anymore
+	go a.fileStorage.Start()
+
+	<-ctx.Done()
+
+	return nil
+}
+
 func (a *App) StartGRPCServer(ctx context.Context) error {
-	listen, err := net.Listen("tcp", a.Config.GRPCNetAddress.String())
+	listen, err := a.listen(a.Config.GRPCNetAddress.String())
 	if err != nil {
 		return fmt.Errorf("filed to start listen address %s: %w", a.Config.GRPCNetAddress.String(), err)
 	}
 
-	s := grpc.NewServer(grpc.UnaryInterceptor(interceptor.Auth))
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			interceptor.RealIP(a.realIP),
+			interceptor.Auth(a.keyring, a.oidc),
+			interceptor.CheckBan(a.banChecker),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptor.StreamRealIP(a.realIP),
+			interceptor.StreamAuth(a.keyring, a.oidc),
+			interceptor.StreamCheckBan(a.banChecker),
+		),
+	)
 	proto.RegisterURLShortenerServer(s, a.grpcHandler)
+	proto.RegisterAdminServiceServer(s, a.adminHandler)
 
+	stopped := make(chan struct{})
 	go func() {
 		<-ctx.Done()
-		listen.Close()
+		s.GracefulStop()
+		close(stopped)
 	}()
 
 	if err := s.Serve(listen); err != nil {
 		return fmt.Errorf("filed to start serving: %w", err)
 	}
 
+	<-stopped
+
 	return nil
 }
 
+// listen listens the TCP address, with ProxyProtocol connections of trusted proxies may start with the PROXY protocol header
+func (a *App) listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Config.ProxyProtocol {
+		return a.realIP.Listener(l), nil
+	}
+
+	return l, nil
+}
+
 // Exit finishes the app by closing inited db connections and etc
 func (a *App) Exit() {
-	if err := a.pool.Close(); err != nil {
-		log.Printf("failed to close pool: %s", err)
+	if a.fileStorage != nil {
+		if err := a.fileStorage.Stop(); err != nil {
+			log.Printf("failed to stop file storage: %s", err)
+		}
+	}
+
+	if a.pool != nil {
+		a.pool.Close()
+	}
+
+	if a.redis != nil {
+		if err := a.redis.Close(); err != nil {
+			log.Printf("failed to close redis client: %s", err)
+		}
+	}
+
+	if a.kvStorage != nil {
+		if err := a.kvStorage.Close(); err != nil {
+			log.Printf("failed to close kv storage: %s", err)
+		}
 	}
 }
 
-func setupDB(dsn string) (*sql.DB, error) {
-	pool, err := sql.Open("pgx", dsn)
+func setupRedis(addr string) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to ping redis after connect: %w", err)
+	}
+
+	return client, nil
+}
+
+// setupKeyring loads signing keys of auth tokens in order: keys file, keys of json config, HS256 secret
+// without keys a random secret is generated, tokens become invalid after restart and aren't shared between instances
+func setupKeyring(cfg JWTConfig) (*jwt.Keyring, error) {
+	keys := cfg.Keys
+	if cfg.KeysFile != "" {
+		var err error
+		keys, err = jwt.ReadKeyConfigs(cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keys file: %w", err)
+		}
+	}
+
+	if len(keys) == 0 && cfg.Secret != "" {
+		keys = []jwt.KeyConfig{{ID: "default", Algorithm: jwt.AlgHS256, Secret: cfg.Secret}}
+	}
+
+	if len(keys) > 0 {
+		return jwt.LoadKeyring(keys)
+	}
+
+	log.Println("jwt keys aren't configured, a random secret is used")
+
+	key, err := jwt.GenerateKey("random")
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.NewKeyring(key)
+}
+
+func setupDB(dsn string) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(context.Background(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
-	if err := pool.Ping(); err != nil {
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database after connect: %w", err)
 	}
 
 	return pool, nil
 }
+
+// runMigrations migrates the database through database/sql connections taken from the pool
+func runMigrations(pool *pgxpool.Pool) error {
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+
+	return migrations.RunUp(db)
+}
@@ -9,6 +9,9 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/lks-go/url-shortener/internal/lib/jwt"
 )
 
 // Default settings
@@ -28,8 +31,39 @@ func NewConfig() (Config, error) {
 	flag.StringVar(&redirectBasePath, "b", DefaultBaseURL, "Base path for short URL")
 	flag.StringVar(&cfg.FileStoragePath, "f", DefaultFSPath, "Path for file storage")
 	flag.StringVar(&cfg.DatabaseDSN, "d", "", "Database connection string")
+	flag.StringVar(&cfg.RedisAddr, "r", "", "Redis server address host:port")
+	flag.StringVar(&cfg.KVStoragePath, "kv", "", "Path for embedded key-value storage")
 	flag.BoolVar(&cfg.EnableHTTPS, "s", false, "Enable HTTPS")
 	flag.StringVar(&cfg.HTTPHandlerConfig.TrustedSubnet, "t", "", "Trusted subnet")
+	flag.Func("trusted-proxies", "Comma separated IPs or CIDRs of proxies trusted to forward client IPs", func(s string) error {
+		cfg.TrustedProxies = splitList(s)
+		return nil
+	})
+	flag.Func("admins", "Comma separated IDs of users having the admin role", func(s string) error {
+		cfg.Admins = splitList(s)
+		return nil
+	})
+	flag.BoolVar(&cfg.ProxyProtocol, "proxy-protocol", false, "Accept PROXY protocol headers from trusted proxies")
+	flag.StringVar(&cfg.AliasConfig.Alphabet, "alias-alphabet", "", "Allowed characters of custom aliases")
+	flag.IntVar(&cfg.AliasConfig.MinLength, "alias-min-length", 0, "Min length of custom aliases")
+	flag.IntVar(&cfg.AliasConfig.MaxLength, "alias-max-length", 0, "Max length of custom aliases")
+	flag.DurationVar(&cfg.SweepInterval, "sweep-interval", 0, "Interval of deleting expired URLs")
+	flag.StringVar(&cfg.FileStorageConfig.SyncPolicy, "fs-sync", "", "Sync policy of file storage: always, interval or never")
+	flag.DurationVar(&cfg.FileStorageConfig.SyncInterval, "fs-sync-interval", 0, "Interval of syncing file storage")
+	flag.DurationVar(&cfg.FileStorageConfig.CompactInterval, "fs-compact-interval", 0, "Interval of compacting file storage")
+	flag.BoolVar(&cfg.FileStorageConfig.Recover, "fs-recover", true, "Discard a torn trailing record of file storage")
+	flag.IntVar(&cfg.CacheConfig.Size, "cache-size", 0, "Max count of cached redirect lookups, 0 disables the cache")
+	flag.DurationVar(&cfg.CacheConfig.TTL, "cache-ttl", 0, "Time to live of cached redirect lookups")
+	flag.StringVar(&cfg.JWTConfig.Secret, "jwt-secret", "", "HS256 secret of auth tokens")
+	flag.StringVar(&cfg.JWTConfig.KeysFile, "jwt-keys", "", "Path to json file with signing keys of auth tokens")
+	flag.StringVar(&cfg.OIDCConfig.Issuer, "oidc-issuer", "", "Issuer URL of OIDC provider, empty disables OIDC login")
+	flag.StringVar(&cfg.OIDCConfig.ClientID, "oidc-client-id", "", "Client ID registered in OIDC provider")
+	flag.StringVar(&cfg.OIDCConfig.ClientSecret, "oidc-client-secret", "", "Client secret registered in OIDC provider")
+	flag.StringVar(&cfg.OIDCConfig.RedirectURL, "oidc-redirect-url", "", "URL of OIDC login callback registered in OIDC provider")
+	flag.Func("oidc-scopes", "Comma separated OIDC scopes requested in addition to openid", func(s string) error {
+		cfg.OIDCConfig.Scopes = splitList(s)
+		return nil
+	})
 
 	cfg.HTTPHandlerConfig.RedirectBasePath, cfg.GRPCHandlerConfig.RedirectBasePath = redirectBasePath, redirectBasePath
 
@@ -59,6 +93,14 @@ func NewConfig() (Config, error) {
 		cfg.DatabaseDSN = dbConnString
 	}
 
+	if redisAddr, ok := os.LookupEnv("REDIS_ADDR"); ok {
+		cfg.RedisAddr = redisAddr
+	}
+
+	if kvPath, ok := os.LookupEnv("KV_STORAGE_PATH"); ok {
+		cfg.KVStoragePath = kvPath
+	}
+
 	if enableHTTPS, ok := os.LookupEnv("ENABLE_HTTPS"); ok {
 		cfg.EnableHTTPS = enableHTTPS == "true" || enableHTTPS == "1"
 	}
@@ -67,13 +109,123 @@ func NewConfig() (Config, error) {
 		cfg.HTTPHandlerConfig.TrustedSubnet = trustedSubnet
 	}
 
+	if trustedProxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.TrustedProxies = splitList(trustedProxies)
+	}
+
+	if admins, ok := os.LookupEnv("ADMINS"); ok {
+		cfg.Admins = splitList(admins)
+	}
+
+	if proxyProtocol, ok := os.LookupEnv("PROXY_PROTOCOL"); ok {
+		cfg.ProxyProtocol = proxyProtocol == "true" || proxyProtocol == "1"
+	}
+
+	if alphabet, ok := os.LookupEnv("ALIAS_ALPHABET"); ok {
+		cfg.AliasConfig.Alphabet = alphabet
+	}
+
+	if minLength, ok := os.LookupEnv("ALIAS_MIN_LENGTH"); ok {
+		l, err := strconv.Atoi(minLength)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse ALIAS_MIN_LENGTH: %w", err)
+		}
+		cfg.AliasConfig.MinLength = l
+	}
+
+	if maxLength, ok := os.LookupEnv("ALIAS_MAX_LENGTH"); ok {
+		l, err := strconv.Atoi(maxLength)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse ALIAS_MAX_LENGTH: %w", err)
+		}
+		cfg.AliasConfig.MaxLength = l
+	}
+
+	if sweepInterval, ok := os.LookupEnv("SWEEP_INTERVAL"); ok {
+		d, err := time.ParseDuration(sweepInterval)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse SWEEP_INTERVAL: %w", err)
+		}
+		cfg.SweepInterval = d
+	}
+
+	if cacheSize, ok := os.LookupEnv("CACHE_SIZE"); ok {
+		size, err := strconv.Atoi(cacheSize)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse CACHE_SIZE: %w", err)
+		}
+		cfg.CacheConfig.Size = size
+	}
+
+	if cacheTTL, ok := os.LookupEnv("CACHE_TTL"); ok {
+		d, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse CACHE_TTL: %w", err)
+		}
+		cfg.CacheConfig.TTL = d
+	}
+
+	if syncPolicy, ok := os.LookupEnv("FILE_STORAGE_SYNC"); ok {
+		cfg.FileStorageConfig.SyncPolicy = syncPolicy
+	}
+
+	if syncInterval, ok := os.LookupEnv("FILE_STORAGE_SYNC_INTERVAL"); ok {
+		d, err := time.ParseDuration(syncInterval)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse FILE_STORAGE_SYNC_INTERVAL: %w", err)
+		}
+		cfg.FileStorageConfig.SyncInterval = d
+	}
+
+	if compactInterval, ok := os.LookupEnv("FILE_STORAGE_COMPACT_INTERVAL"); ok {
+		d, err := time.ParseDuration(compactInterval)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse FILE_STORAGE_COMPACT_INTERVAL: %w", err)
+		}
+		cfg.FileStorageConfig.CompactInterval = d
+	}
+
+	if fsRecover, ok := os.LookupEnv("FILE_STORAGE_RECOVER"); ok {
+		cfg.FileStorageConfig.Recover = fsRecover == "true" || fsRecover == "1"
+	}
+
+	if jwtSecret, ok := os.LookupEnv("JWT_SECRET"); ok {
+		cfg.JWTConfig.Secret = jwtSecret
+	}
+
+	if jwtKeysFile, ok := os.LookupEnv("JWT_KEYS_FILE"); ok {
+		cfg.JWTConfig.KeysFile = jwtKeysFile
+	}
+
+	if oidcIssuer, ok := os.LookupEnv("OIDC_ISSUER"); ok {
+		cfg.OIDCConfig.Issuer = oidcIssuer
+	}
+
+	if oidcClientID, ok := os.LookupEnv("OIDC_CLIENT_ID"); ok {
+		cfg.OIDCConfig.ClientID = oidcClientID
+	}
+
+	if oidcClientSecret, ok := os.LookupEnv("OIDC_CLIENT_SECRET"); ok {
+		cfg.OIDCConfig.ClientSecret = oidcClientSecret
+	}
+
+	if oidcRedirectURL, ok := os.LookupEnv("OIDC_REDIRECT_URL"); ok {
+		cfg.OIDCConfig.RedirectURL = oidcRedirectURL
+	}
+
+	if oidcScopes, ok := os.LookupEnv("OIDC_SCOPES"); ok {
+		cfg.OIDCConfig.Scopes = splitList(oidcScopes)
+	}
+
 	if configFile != "" {
 		jsonCfg, err := parseJSONConfig(configFile)
 		if err != nil {
 			return Config{}, fmt.Errorf("failed to parse json config: %w", err)
 		}
 
-		mapJSONConfig(&cfg, jsonCfg)
+		if err := mapJSONConfig(&cfg, jsonCfg); err != nil {
+			return Config{}, fmt.Errorf("failed to map json config: %w", err)
+		}
 	}
 
 	return cfg, nil
@@ -81,16 +233,68 @@ func NewConfig() (Config, error) {
 
 // Config contains application config
 type Config struct {
-	NetAddress           NetAddress
-	GRPCNetAddress       NetAddress
-	FileStoragePath      string
-	DatabaseDSN          string
-	EnableHTTPS          bool
+	NetAddress      NetAddress
+	GRPCNetAddress  NetAddress
+	FileStoragePath string
+	DatabaseDSN     string
+	RedisAddr       string
+	KVStoragePath   string
+	EnableHTTPS     bool
+	TrustedProxies  []string
+	ProxyProtocol   bool
+	// Admins are IDs of users having the admin role
+	Admins               []string
 	HTTPHandlerConfig    HTTPHandlerConfig
 	GRPCHandlerConfig    GRPCHandlerConfig
+	AliasConfig          AliasConfig
+	SweepInterval        time.Duration
+	FileStorageConfig    FileStorageConfig
+	CacheConfig          CacheConfig
+	JWTConfig            JWTConfig
+	OIDCConfig           OIDCConfig
 	ForbiddenAllHandlers bool
 }
 
+// CacheConfig contains settings of the cache of redirect lookups
+// the cache is disabled if Size isn't positive
+type CacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+// JWTConfig contains signing keys of auth tokens
+// Keys and keys of KeysFile are ordered: the first key signs tokens, the rest verify tokens of previous rotations
+type JWTConfig struct {
+	Secret   string
+	KeysFile string
+	Keys     []jwt.KeyConfig
+}
+
+// OIDCConfig contains settings of login by the OIDC provider
+// login by the provider is disabled if Issuer is empty
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// FileStorageConfig contains durability settings of the file storage
+type FileStorageConfig struct {
+	SyncPolicy      string
+	SyncInterval    time.Duration
+	CompactInterval time.Duration
+	Recover         bool
+}
+
+// AliasConfig contains restrictions of custom aliases
+type AliasConfig struct {
+	Alphabet  string
+	MinLength int
+	MaxLength int
+}
+
 // HTTPHandlerConfig конфиг для HTTP хендлеров
 type HTTPHandlerConfig struct {
 	RedirectBasePath string
@@ -138,13 +342,35 @@ func (a *NetAddress) Set(s string) error {
 }
 
 type jsonConfig struct {
-	ServerAddress     string `json:"server_address"`
-	GRPCServerAddress string `json:"grpc_server_address"`
-	BaseURL           string `json:"base_url"`
-	FileStoragePath   string `json:"file_storage_path"`
-	DatabaseDSN       string `json:"database_dsn"`
-	EnableHTTPS       bool   `json:"enable_https"`
-	TrustedSubnet     string `json:"trusted_subnet"`
+	ServerAddress     string          `json:"server_address"`
+	GRPCServerAddress string          `json:"grpc_server_address"`
+	BaseURL           string          `json:"base_url"`
+	FileStoragePath   string          `json:"file_storage_path"`
+	DatabaseDSN       string          `json:"database_dsn"`
+	RedisAddr         string          `json:"redis_addr"`
+	KVStoragePath     string          `json:"kv_storage_path"`
+	EnableHTTPS       bool            `json:"enable_https"`
+	TrustedSubnet     string          `json:"trusted_subnet"`
+	TrustedProxies    []string        `json:"trusted_proxies"`
+	ProxyProtocol     bool            `json:"proxy_protocol"`
+	Admins            []string        `json:"admins"`
+	AliasAlphabet     string          `json:"alias_alphabet"`
+	AliasMinLength    int             `json:"alias_min_length"`
+	AliasMaxLength    int             `json:"alias_max_length"`
+	SweepInterval     string          `json:"sweep_interval"`
+	FSSync            string          `json:"file_storage_sync"`
+	FSSyncInterval    string          `json:"file_storage_sync_interval"`
+	FSCompactInterval string          `json:"file_storage_compact_interval"`
+	CacheSize         int             `json:"cache_size"`
+	CacheTTL          string          `json:"cache_ttl"`
+	JWTSecret         string          `json:"jwt_secret"`
+	JWTKeysFile       string          `json:"jwt_keys_file"`
+	JWTKeys           []jwt.KeyConfig `json:"jwt_keys"`
+	OIDCIssuer        string          `json:"oidc_issuer"`
+	OIDCClientID      string          `json:"oidc_client_id"`
+	OIDCClientSecret  string          `json:"oidc_client_secret"`
+	OIDCRedirectURL   string          `json:"oidc_redirect_url"`
+	OIDCScopes        []string        `json:"oidc_scopes"`
 }
 
 func parseJSONConfig(file string) (*jsonConfig, error) {
@@ -167,7 +393,7 @@ func parseJSONConfig(file string) (*jsonConfig, error) {
 	return &cfg, nil
 }
 
-func mapJSONConfig(cfg *Config, jsonCfg *jsonConfig) {
+func mapJSONConfig(cfg *Config, jsonCfg *jsonConfig) error {
 	if cfg.NetAddress.String() == "" {
 		cfg.NetAddress.Set(jsonCfg.ServerAddress)
 	}
@@ -189,14 +415,130 @@ func mapJSONConfig(cfg *Config, jsonCfg *jsonConfig) {
 		cfg.DatabaseDSN = jsonCfg.DatabaseDSN
 	}
 
+	if cfg.RedisAddr == "" {
+		cfg.RedisAddr = jsonCfg.RedisAddr
+	}
+
+	if cfg.KVStoragePath == "" {
+		cfg.KVStoragePath = jsonCfg.KVStoragePath
+	}
+
 	if !cfg.EnableHTTPS {
 		cfg.EnableHTTPS = jsonCfg.EnableHTTPS
 	}
 
+	if cfg.TrustedProxies == nil {
+		cfg.TrustedProxies = jsonCfg.TrustedProxies
+	}
+
+	if !cfg.ProxyProtocol {
+		cfg.ProxyProtocol = jsonCfg.ProxyProtocol
+	}
+
+	if cfg.Admins == nil {
+		cfg.Admins = jsonCfg.Admins
+	}
+
+	if cfg.AliasConfig.Alphabet == "" {
+		cfg.AliasConfig.Alphabet = jsonCfg.AliasAlphabet
+	}
+
+	if cfg.AliasConfig.MinLength == 0 {
+		cfg.AliasConfig.MinLength = jsonCfg.AliasMinLength
+	}
+
+	if cfg.AliasConfig.MaxLength == 0 {
+		cfg.AliasConfig.MaxLength = jsonCfg.AliasMaxLength
+	}
+
+	if cfg.SweepInterval == 0 && jsonCfg.SweepInterval != "" {
+		d, err := time.ParseDuration(jsonCfg.SweepInterval)
+		if err != nil {
+			return fmt.Errorf("failed to parse sweep_interval: %w", err)
+		}
+		cfg.SweepInterval = d
+	}
+
+	if cfg.CacheConfig.Size == 0 {
+		cfg.CacheConfig.Size = jsonCfg.CacheSize
+	}
+
+	if cfg.CacheConfig.TTL == 0 && jsonCfg.CacheTTL != "" {
+		d, err := time.ParseDuration(jsonCfg.CacheTTL)
+		if err != nil {
+			return fmt.Errorf("failed to parse cache_ttl: %w", err)
+		}
+		cfg.CacheConfig.TTL = d
+	}
+
+	if cfg.JWTConfig.Secret == "" {
+		cfg.JWTConfig.Secret = jsonCfg.JWTSecret
+	}
+
+	if cfg.JWTConfig.KeysFile == "" {
+		cfg.JWTConfig.KeysFile = jsonCfg.JWTKeysFile
+	}
+
+	cfg.JWTConfig.Keys = jsonCfg.JWTKeys
+
+	if cfg.OIDCConfig.Issuer == "" {
+		cfg.OIDCConfig.Issuer = jsonCfg.OIDCIssuer
+	}
+
+	if cfg.OIDCConfig.ClientID == "" {
+		cfg.OIDCConfig.ClientID = jsonCfg.OIDCClientID
+	}
+
+	if cfg.OIDCConfig.ClientSecret == "" {
+		cfg.OIDCConfig.ClientSecret = jsonCfg.OIDCClientSecret
+	}
+
+	if cfg.OIDCConfig.RedirectURL == "" {
+		cfg.OIDCConfig.RedirectURL = jsonCfg.OIDCRedirectURL
+	}
+
+	if cfg.OIDCConfig.Scopes == nil {
+		cfg.OIDCConfig.Scopes = jsonCfg.OIDCScopes
+	}
+
+	if cfg.FileStorageConfig.SyncPolicy == "" {
+		cfg.FileStorageConfig.SyncPolicy = jsonCfg.FSSync
+	}
+
+	if cfg.FileStorageConfig.SyncInterval == 0 && jsonCfg.FSSyncInterval != "" {
+		d, err := time.ParseDuration(jsonCfg.FSSyncInterval)
+		if err != nil {
+			return fmt.Errorf("failed to parse file_storage_sync_interval: %w", err)
+		}
+		cfg.FileStorageConfig.SyncInterval = d
+	}
+
+	if cfg.FileStorageConfig.CompactInterval == 0 && jsonCfg.FSCompactInterval != "" {
+		d, err := time.ParseDuration(jsonCfg.FSCompactInterval)
+		if err != nil {
+			return fmt.Errorf("failed to parse file_storage_compact_interval: %w", err)
+		}
+		cfg.FileStorageConfig.CompactInterval = d
+	}
+
 	if cfg.HTTPHandlerConfig.TrustedSubnet == "" {
 		cfg.HTTPHandlerConfig.TrustedSubnet = jsonCfg.TrustedSubnet
 		if jsonCfg.TrustedSubnet == "" {
 			cfg.ForbiddenAllHandlers = true
 		}
 	}
+
+	return nil
+}
+
+// splitList parses comma separated values like OIDC scopes or trusted proxies
+func splitList(s string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
 }
@@ -3,7 +3,6 @@ package entity
 import "time"
 
 const (
-	AuthTokenHeader  = "auth_token"
-	CookieExpires    = time.Hour * 24 * 30
-	UserIDHeaderName = "User-Id"
+	AuthTokenHeader = "auth_token"
+	CookieExpires   = time.Hour * 24 * 30
 )
@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"context"
+	"net"
+)
+
+// Kinds of identity by the way the request is authenticated
+const (
+	// KindSession is a token issued by the service in the cookie or metadata, the user is anonymous or an account
+	KindSession = "session"
+	KindAPIKey  = "api_key"
+	KindOIDC    = "oidc"
+)
+
+// Identity is the authenticated user of the request
+// it's put to the context by the auth middleware and interceptor only, so clients can't supply it
+type Identity struct {
+	UserID string
+	// Scopes limit the actions of the request, see service.HasScope
+	Scopes []string
+	Kind   string
+}
+
+type identityKey struct{}
+
+type clientIPKey struct{}
+
+// WithIdentity returns the context with the identity of the request
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the request, false if the request isn't authenticated
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	if !ok || id.UserID == "" {
+		return Identity{}, false
+	}
+
+	return id, true
+}
+
+// WithClientIP returns the context with the IP of the client resolved behind trusted proxies
+func WithClientIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the IP of the client, false if it isn't resolved
+func ClientIPFromContext(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(net.IP)
+	return ip, ok && ip != nil
+}
@@ -14,21 +14,57 @@ type Claims struct {
 	UserID string
 }
 
-const (
-	tokenExp  = time.Hour * 60
-	secretKey = "secret"
+const tokenExp = time.Hour * 60
+
+// Token errors
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
 )
 
-// BuildNewJWTToken builds new jwt to userID
-func BuildNewJWTToken(userID string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+// NewKeyring returns keyring which signs tokens with the first key and verifies them with any of the keys
+// keys of the previous rotations are kept after the first one until the tokens signed by them expire
+func NewKeyring(keys ...*Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+
+	if keys[0].signKey == nil {
+		return nil, fmt.Errorf("key %s can't sign tokens: private key is missing", keys[0].ID)
+	}
+
+	k := &Keyring{
+		signing: keys[0],
+		keys:    make(map[string]*Key, len(keys)),
+	}
+
+	for _, key := range keys {
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		k.keys[key.ID] = key
+	}
+
+	return k, nil
+}
+
+// Keyring contains keys of signing and verifying tokens
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// BuildNewJWTToken builds new jwt to userID signed by the current key
+func (k *Keyring) BuildNewJWTToken(userID string) (string, error) {
+	token := jwt.NewWithClaims(k.signing.Method, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExp)),
 		},
 		UserID: userID,
 	})
+	token.Header["kid"] = k.signing.ID
 
-	tokenString, err := token.SignedString([]byte(secretKey))
+	tokenString, err := token.SignedString(k.signing.signKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to get signed string: %w", err)
 	}
@@ -36,30 +72,32 @@ func BuildNewJWTToken(userID string) (string, error) {
 	return tokenString, nil
 }
 
-// Token errors
-var (
-	ErrInvalidToken = errors.New("invalid token")
-	ErrTokenExpired = errors.New("token expired")
-)
-
-// ParseJWTToken validates jwt
-func ParseJWTToken(token string) (*Claims, error) {
+// ParseJWTToken validates jwt with the key of its kid header
+// tokens without kid, of unknown keys or with a bad signature are invalid
+func (k *Keyring) ParseJWTToken(token string) (*Claims, error) {
 	claims := Claims{}
 	parsedToken, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
-		if t.Method != jwt.SigningMethodHS256 {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %q", kid)
+		}
+
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
 
-		return []byte(secretKey), nil
+		return key.verifyKey, nil
 	})
-	if err != nil && !errors.Is(err, jwt.ErrTokenExpired) {
-		return nil, fmt.Errorf("failed to parse jwt: %w", err)
-	}
 
 	if errors.Is(err, jwt.ErrTokenExpired) {
 		return nil, ErrTokenExpired
 	}
 
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
 	if !parsedToken.Valid {
 		return nil, ErrInvalidToken
 	}
@@ -0,0 +1,219 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/lib/jwt"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestKeyring_Algorithms(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  jwt.KeyConfig
+	}{
+		{
+			name: "HS256",
+			cfg:  jwt.KeyConfig{ID: "hs", Algorithm: jwt.AlgHS256, Secret: testSecret},
+		},
+		{
+			name: "RS256",
+			cfg:  jwt.KeyConfig{ID: "rs", Algorithm: jwt.AlgRS256, File: writePrivateKey(t, dir, "rs.pem", rsaKey)},
+		},
+		{
+			name: "ES256",
+			cfg:  jwt.KeyConfig{ID: "es", Algorithm: jwt.AlgES256, File: writePrivateKey(t, dir, "es.pem", ecKey)},
+		},
+		{
+			name: "EdDSA",
+			cfg:  jwt.KeyConfig{ID: "ed", Algorithm: jwt.AlgEdDSA, File: writePrivateKey(t, dir, "ed.pem", edKey)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := jwt.LoadKeyring([]jwt.KeyConfig{tt.cfg})
+			require.NoError(t, err)
+
+			token, err := keyring.BuildNewJWTToken("user1")
+			require.NoError(t, err)
+
+			parsed, _, err := gojwt.NewParser().ParseUnverified(token, &gojwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.cfg.ID, parsed.Header["kid"])
+			assert.Equal(t, tt.cfg.Algorithm, parsed.Header["alg"])
+
+			claims, err := keyring.ParseJWTToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user1", claims.UserID)
+		})
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	oldCfg := jwt.KeyConfig{ID: "old", Algorithm: jwt.AlgES256, File: writePrivateKey(t, dir, "old.pem", ecKey)}
+	newCfg := jwt.KeyConfig{ID: "new", Algorithm: jwt.AlgHS256, Secret: testSecret}
+
+	oldKeyring, err := jwt.LoadKeyring([]jwt.KeyConfig{oldCfg})
+	require.NoError(t, err)
+
+	oldToken, err := oldKeyring.BuildNewJWTToken("user1")
+	require.NoError(t, err)
+
+	// the retired key is kept as a public key only
+	pubCfg := jwt.KeyConfig{ID: "old", Algorithm: jwt.AlgES256, File: writePublicKey(t, dir, "old.pub.pem", &ecKey.PublicKey)}
+	rotated, err := jwt.LoadKeyring([]jwt.KeyConfig{newCfg, pubCfg})
+	require.NoError(t, err)
+
+	claims, err := rotated.ParseJWTToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", claims.UserID)
+
+	newToken, err := rotated.BuildNewJWTToken("user2")
+	require.NoError(t, err)
+
+	_, err = oldKeyring.ParseJWTToken(newToken)
+	require.ErrorIs(t, err, jwt.ErrInvalidToken)
+
+	finished, err := jwt.LoadKeyring([]jwt.KeyConfig{newCfg})
+	require.NoError(t, err)
+
+	_, err = finished.ParseJWTToken(oldToken)
+	require.ErrorIs(t, err, jwt.ErrInvalidToken)
+
+	_, err = jwt.LoadKeyring([]jwt.KeyConfig{pubCfg, newCfg})
+	require.Error(t, err, "public key can't sign tokens")
+}
+
+func TestKeyring_ParseJWTToken(t *testing.T) {
+	keyring, err := jwt.LoadKeyring([]jwt.KeyConfig{{ID: "hs", Algorithm: jwt.AlgHS256, Secret: testSecret}})
+	require.NoError(t, err)
+
+	sign := func(kid string, secret string, exp time.Time) string {
+		token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, jwt.Claims{
+			RegisteredClaims: gojwt.RegisteredClaims{ExpiresAt: gojwt.NewNumericDate(exp)},
+			UserID:           "user1",
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		s, err := token.SignedString([]byte(secret))
+		require.NoError(t, err)
+
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "valid token",
+			token: sign("hs", testSecret, time.Now().Add(time.Hour)),
+		},
+		{
+			name:    "forged with another secret",
+			token:   sign("hs", "secret", time.Now().Add(time.Hour)),
+			wantErr: jwt.ErrInvalidToken,
+		},
+		{
+			name:    "without kid",
+			token:   sign("", testSecret, time.Now().Add(time.Hour)),
+			wantErr: jwt.ErrInvalidToken,
+		},
+		{
+			name:    "unknown kid",
+			token:   sign("other", testSecret, time.Now().Add(time.Hour)),
+			wantErr: jwt.ErrInvalidToken,
+		},
+		{
+			name:    "expired",
+			token:   sign("hs", testSecret, time.Now().Add(-time.Hour)),
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:    "malformed",
+			token:   "abc",
+			wantErr: jwt.ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := keyring.ParseJWTToken(tt.token)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "user1", claims.UserID)
+		})
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	_, err := jwt.NewKey(jwt.KeyConfig{ID: "hs", Algorithm: jwt.AlgHS256, Secret: "secret"})
+	require.Error(t, err, "short secret")
+
+	_, err = jwt.NewKey(jwt.KeyConfig{ID: "hs", Algorithm: "none", Secret: testSecret})
+	require.Error(t, err, "unsupported algorithm")
+
+	_, err = jwt.NewKey(jwt.KeyConfig{Algorithm: jwt.AlgHS256, Secret: testSecret})
+	require.Error(t, err, "empty id")
+
+	dir := t.TempDir()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = jwt.NewKey(jwt.KeyConfig{ID: "es", Algorithm: jwt.AlgES256, File: writePrivateKey(t, dir, "es.pem", ecKey)})
+	require.Error(t, err, "curve doesn't match the algorithm")
+}
+
+func writePrivateKey(t *testing.T, dir, name string, key any) string {
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return writePEM(t, filepath.Join(dir, name), "PRIVATE KEY", b)
+}
+
+func writePublicKey(t *testing.T, dir, name string, key any) string {
+	b, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	return writePEM(t, filepath.Join(dir, name), "PUBLIC KEY", b)
+}
+
+func writePEM(t *testing.T, path, typ string, b []byte) string {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600))
+
+	return path
+}
@@ -0,0 +1,202 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minSecretSize is the min size of HS256 secrets in bytes
+const minSecretSize = 32
+
+// KeyConfig describes a key of the keyring
+// the key material is Secret of HS256 or the content of File: a raw HS256 secret or a PEM encoded key,
+// asymmetric keys of previous rotations may be public only, they verify tokens but can't sign them
+type KeyConfig struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Secret    string `json:"secret,omitempty"`
+	File      string `json:"file,omitempty"`
+}
+
+// Key is a key of signing and verifying tokens
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewKey builds key by the config
+func NewKey(cfg KeyConfig) (*Key, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("key id is empty")
+	}
+
+	material := []byte(cfg.Secret)
+	if cfg.File != "" {
+		var err error
+		material, err = os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file of %s: %w", cfg.ID, err)
+		}
+	}
+
+	if len(material) == 0 {
+		return nil, fmt.Errorf("key %s is empty", cfg.ID)
+	}
+
+	key := &Key{ID: cfg.ID}
+	var err error
+
+	switch cfg.Algorithm {
+	case AlgHS256:
+		key.Method = jwt.SigningMethodHS256
+		err = key.setHMAC(bytes.TrimSpace(material))
+	case AlgRS256:
+		key.Method = jwt.SigningMethodRS256
+		err = key.setRSA(material)
+	case AlgES256:
+		key.Method = jwt.SigningMethodES256
+		err = key.setECDSA(material)
+	case AlgEdDSA:
+		key.Method = jwt.SigningMethodEdDSA
+		err = key.setEd25519(material)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q of key %s", cfg.Algorithm, cfg.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", cfg.ID, err)
+	}
+
+	return key, nil
+}
+
+// GenerateKey returns a random HS256 key
+func GenerateKey(id string) (*Key, error) {
+	secret := make([]byte, minSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	key := &Key{ID: id, Method: jwt.SigningMethodHS256}
+	if err := key.setHMAC(secret); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// LoadKeyring builds keyring by the configs, the first key signs tokens
+func LoadKeyring(cfgs []KeyConfig) (*Keyring, error) {
+	keys := make([]*Key, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		key, err := NewKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(keys...)
+}
+
+// ReadKeyConfigs reads configs of keys from the json file containing an array of KeyConfig
+func ReadKeyConfigs(file string) ([]KeyConfig, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var cfgs []KeyConfig
+	if err := json.Unmarshal(b, &cfgs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal keys: %w", err)
+	}
+
+	return cfgs, nil
+}
+
+func (k *Key) setHMAC(secret []byte) error {
+	if len(secret) < minSecretSize {
+		return fmt.Errorf("secret must be at least %d bytes", minSecretSize)
+	}
+
+	k.signKey, k.verifyKey = secret, secret
+
+	return nil
+}
+
+func (k *Key) setRSA(pem []byte) error {
+	if priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		k.signKey, k.verifyKey = priv, &priv.PublicKey
+		return nil
+	}
+
+	pub, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err != nil {
+		return err
+	}
+	k.verifyKey = pub
+
+	return nil
+}
+
+func (k *Key) setECDSA(pem []byte) error {
+	if priv, err := jwt.ParseECPrivateKeyFromPEM(pem); err == nil {
+		k.signKey, k.verifyKey = priv, &priv.PublicKey
+		return checkCurve(&priv.PublicKey)
+	}
+
+	pub, err := jwt.ParseECPublicKeyFromPEM(pem)
+	if err != nil {
+		return err
+	}
+	k.verifyKey = pub
+
+	return checkCurve(pub)
+}
+
+func (k *Key) setEd25519(pem []byte) error {
+	if priv, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+		edPriv, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return errors.New("not an ed25519 private key")
+		}
+		k.signKey, k.verifyKey = edPriv, edPriv.Public()
+		return nil
+	}
+
+	pub, err := jwt.ParseEdPublicKeyFromPEM(pem)
+	if err != nil {
+		return err
+	}
+	k.verifyKey = pub
+
+	return nil
+}
+
+// checkCurve checks that the key fits ES256
+func checkCurve(pub *ecdsa.PublicKey) error {
+	if pub.Curve != elliptic.P256() {
+		return fmt.Errorf("curve %s doesn't match ES256", pub.Curve.Params().Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+// Package oidc implements login by the OpenID Connect authorization code flow and validation of ID tokens
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// OIDC errors
+var (
+	ErrInvalidToken = errors.New("invalid id token")
+	ErrInvalidCode  = errors.New("invalid authorization code")
+)
+
+// signingMethods are algorithms of ID tokens accepted by Verify, symmetric algorithms and none aren't accepted
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// userIDNamespace is a namespace of user IDs derived from subjects of the provider
+var userIDNamespace = uuid.MustParse("7d5c2b2e-4a0f-4f0e-9a57-0c8f3b8e6a11")
+
+// Config contains settings of the provider and the client registered in it
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested in addition to openid
+	Scopes []string
+}
+
+// IDToken contains validated claims of an ID token
+type IDToken struct {
+	Issuer    string
+	Subject   string
+	Nonce     string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// UserID maps the subject to the user ID of the service
+// user IDs are UUIDs, so the same subject of the same issuer always gets the same UUID
+func (t *IDToken) UserID() string {
+	return uuid.NewSHA1(userIDNamespace, []byte(t.Issuer+" "+t.Subject)).String()
+}
+
+// Provider is a client of the OIDC provider
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	authEndpoint  string
+	tokenEndpoint string
+	keys          *keySet
+}
+
+// discovery is a part of the provider metadata used by Provider
+type discovery struct {
+	Issuer        string `json:"issuer"`
+	AuthEndpoint  string `json:"authorization_endpoint"`
+	TokenEndpoint string `json:"token_endpoint"`
+	JWKSURI       string `json:"jwks_uri"`
+}
+
+// Discover returns Provider configured by the metadata of the issuer
+func Discover(ctx context.Context, cfg Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status of provider metadata: %s", resp.Status)
+	}
+
+	d := discovery{}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("failed to decode provider metadata: %w", err)
+	}
+
+	if d.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("issuer of provider metadata %q doesn't match %q", d.Issuer, cfg.Issuer)
+	}
+
+	if d.AuthEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("provider metadata misses endpoints")
+	}
+
+	return &Provider{
+		cfg:           cfg,
+		client:        client,
+		authEndpoint:  d.AuthEndpoint,
+		tokenEndpoint: d.TokenEndpoint,
+		keys:          newKeySet(d.JWKSURI, client),
+	}, nil
+}
+
+// AuthCodeURL returns URL of the login page of the provider
+func (p *Provider) AuthCodeURL(state, nonce string) string {
+	v := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.cfg.ClientID},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"scope":         {strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+
+	sep := "?"
+	if strings.Contains(p.authEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.authEndpoint + sep + v.Encode()
+}
+
+// Exchange exchanges the authorization code for the ID token and validates it
+// the nonce of the token must be checked by the caller
+// returns ErrInvalidCode if the provider rejects the code
+func (p *Provider) Exchange(ctx context.Context, code string) (*IDToken, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.cfg.RedirectURL},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	switch {
+	case body.Error == "invalid_grant":
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCode, body.ErrorDescription)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("token endpoint responded %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	case body.IDToken == "":
+		return nil, errors.New("token response misses id_token")
+	}
+
+	return p.Verify(ctx, body.IDToken)
+}
+
+// idTokenClaims are claims of an ID token used by Verify
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	Email           string `json:"email"`
+	AuthorizedParty string `json:"azp"`
+}
+
+// Verify validates signature, issuer, audience and expiration of the ID token
+// returns ErrInvalidToken if the token isn't valid
+func (p *Provider) Verify(ctx context.Context, rawToken string) (*IDToken, error) {
+	var keyErr error
+	claims := idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := p.keys.key(ctx, kid)
+		if err != nil && !errors.Is(err, ErrInvalidToken) {
+			keyErr = err
+		}
+
+		return key, err
+	}, jwt.WithValidMethods(signingMethods))
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	switch {
+	case claims.Issuer != p.cfg.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != "" && claims.AuthorizedParty != p.cfg.ClientID:
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidToken, claims.AuthorizedParty)
+	case claims.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: missing expiration", ErrInvalidToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return &IDToken{
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		Nonce:     claims.Nonce,
+		Email:     claims.Email,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+	"github.com/lks-go/url-shortener/internal/lib/oidc/oidctest"
+)
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	mock := oidctest.NewProvider(t)
+
+	p, err := oidc.Discover(context.Background(), mock.Config("http://localhost:8080/callback"), nil)
+	require.NoError(t, err)
+
+	u, err := url.Parse(p.AuthCodeURL("state", "nonce"))
+	require.NoError(t, err)
+
+	assert.Equal(t, mock.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "code", u.Query().Get("response_type"))
+	assert.Equal(t, oidctest.ClientID, u.Query().Get("client_id"))
+	assert.Equal(t, "http://localhost:8080/callback", u.Query().Get("redirect_uri"))
+	assert.Equal(t, "openid", u.Query().Get("scope"))
+	assert.Equal(t, "state", u.Query().Get("state"))
+	assert.Equal(t, "nonce", u.Query().Get("nonce"))
+}
+
+func TestDiscover(t *testing.T) {
+	mock := oidctest.NewProvider(t)
+
+	cfg := mock.Config("http://localhost:8080/callback")
+	cfg.Issuer += "/"
+
+	_, err := oidc.Discover(context.Background(), cfg, nil)
+	require.Error(t, err, "issuer must match the metadata exactly")
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	ctx := context.Background()
+	mock := oidctest.NewProvider(t)
+
+	p, err := oidc.Discover(ctx, mock.Config("http://localhost:8080/callback"), nil)
+	require.NoError(t, err)
+
+	code := mock.Code("alice", "nonce")
+
+	token, err := p.Exchange(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", token.Subject)
+	assert.Equal(t, "nonce", token.Nonce)
+
+	_, err = p.Exchange(ctx, code)
+	require.ErrorIs(t, err, oidc.ErrInvalidCode, "code is single use")
+
+	wrongSecret := mock.Config("http://localhost:8080/callback")
+	wrongSecret.ClientSecret = "wrong"
+	p, err = oidc.Discover(ctx, wrongSecret, nil)
+	require.NoError(t, err)
+
+	_, err = p.Exchange(ctx, mock.Code("alice", "nonce"))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, oidc.ErrInvalidCode)
+}
+
+func TestProvider_Verify(t *testing.T) {
+	ctx := context.Background()
+	mock := oidctest.NewProvider(t)
+
+	p, err := oidc.Discover(ctx, mock.Config("http://localhost:8080/callback"), &http.Client{Timeout: time.Second})
+	require.NoError(t, err)
+
+	claims := func(override jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": mock.URL,
+			"sub": "alice",
+			"aud": oidctest.ClientID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range override {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid token",
+			token: mock.SignIDToken(claims(nil)),
+		},
+		{
+			name:  "audience list",
+			token: mock.SignIDToken(claims(jwt.MapClaims{"aud": []string{"other", oidctest.ClientID}})),
+		},
+		{
+			name:    "another issuer",
+			token:   mock.SignIDToken(claims(jwt.MapClaims{"iss": "https://evil.com"})),
+			wantErr: true,
+		},
+		{
+			name:    "another audience",
+			token:   mock.SignIDToken(claims(jwt.MapClaims{"aud": "other"})),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   mock.SignIDToken(claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})),
+			wantErr: true,
+		},
+		{
+			name:    "without expiration",
+			token:   mock.SignIDToken(claims(jwt.MapClaims{"exp": nil})),
+			wantErr: true,
+		},
+		{
+			name:    "symmetric algorithm",
+			token:   hs256(t, claims(nil)),
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			token:   "abc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := p.Verify(ctx, tt.token)
+			if tt.wantErr {
+				require.ErrorIs(t, err, oidc.ErrInvalidToken)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "alice", token.Subject)
+		})
+	}
+}
+
+func TestProvider_VerifyKeysCache(t *testing.T) {
+	ctx := context.Background()
+	mock := oidctest.NewProvider(t)
+
+	p, err := oidc.Discover(ctx, mock.Config("http://localhost:8080/callback"), nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := p.Verify(ctx, mock.IDToken("alice", ""))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, mock.KeysRequests(), "keys are cached")
+
+	// tokens of an unknown key don't refetch keys right after the previous fetch
+	mock.RotateKey()
+	_, err = p.Verify(ctx, mock.IDToken("alice", ""))
+	require.ErrorIs(t, err, oidc.ErrInvalidToken)
+	assert.Equal(t, 1, mock.KeysRequests())
+}
+
+func TestIDToken_UserID(t *testing.T) {
+	alice := oidc.IDToken{Issuer: "https://idp.example.com", Subject: "alice"}
+	bob := oidc.IDToken{Issuer: "https://idp.example.com", Subject: "bob"}
+
+	assert.Equal(t, alice.UserID(), alice.UserID())
+	assert.NotEqual(t, alice.UserID(), bob.UserID())
+	assert.Len(t, alice.UserID(), 36)
+}
+
+func hs256(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "key-1"
+
+	s, err := token.SignedString([]byte(oidctest.ClientSecret))
+	require.NoError(t, err)
+
+	return s
+}
@@ -0,0 +1,224 @@
+// Package oidctest provides a local OIDC provider for tests
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+)
+
+// Credentials of the client registered in the provider
+const (
+	ClientID     = "url-shortener"
+	ClientSecret = "client-secret"
+)
+
+// Provider is an OIDC provider serving discovery, keys, authorization and token endpoints
+// the authorization endpoint logs in Subject without asking anything
+type Provider struct {
+	*httptest.Server
+	Subject string
+
+	t            *testing.T
+	mu           sync.Mutex
+	key          *rsa.PrivateKey
+	kid          string
+	rotations    int
+	codes        map[string]string
+	keysRequests int
+}
+
+// NewProvider starts the provider, it's stopped on the test cleanup
+func NewProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	p := &Provider{
+		Subject: "subject",
+		t:       t,
+		codes:   make(map[string]string),
+	}
+	p.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/keys", p.keys)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+
+	return p
+}
+
+// Config returns config of the client registered in the provider
+func (p *Provider) Config(redirectURL string) oidc.Config {
+	return oidc.Config{
+		Issuer:       p.URL,
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		RedirectURL:  redirectURL,
+	}
+}
+
+// RotateKey replaces the signing key by a new one with a new key ID
+func (p *Provider) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(p.t, err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rotations++
+	p.key = key
+	p.kid = "key-" + strconv.Itoa(p.rotations)
+}
+
+// KeysRequests returns count of requests of the keys endpoint
+func (p *Provider) KeysRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.keysRequests
+}
+
+// IDToken returns ID token of the subject valid for an hour
+func (p *Provider) IDToken(subject, nonce string) string {
+	return p.SignIDToken(jwt.MapClaims{
+		"iss":   p.URL,
+		"sub":   subject,
+		"aud":   ClientID,
+		"nonce": nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+}
+
+// SignIDToken signs the claims by the current key of the provider
+func (p *Provider) SignIDToken(claims jwt.MapClaims) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+
+	s, err := token.SignedString(p.key)
+	require.NoError(p.t, err)
+
+	return s
+}
+
+// Code returns a single use authorization code of the subject
+func (p *Provider) Code(subject, nonce string) string {
+	idToken := p.IDToken(subject, nonce)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	require.NoError(p.t, err)
+
+	code := base64.RawURLEncoding.EncodeToString(b)
+	p.codes[code] = idToken
+
+	return code
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/keys",
+	})
+}
+
+func (p *Provider) keys(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.keysRequests++
+	pub := p.key.PublicKey
+	kid := p.kid
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	v := redirect.Query()
+	v.Set("code", p.Code(p.Subject, q.Get("nonce")))
+	v.Set("state", q.Get("state"))
+	redirect.RawQuery = v.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != ClientID || secret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	p.mu.Lock()
+	idToken, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown code"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,182 @@
+package realip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidProxyHeader is returned by reads of a connection with a malformed PROXY protocol header
+var ErrInvalidProxyHeader = errors.New("invalid proxy protocol header")
+
+// proxyHeaderTimeout limits waiting for the PROXY protocol header of a new connection
+const proxyHeaderTimeout = 5 * time.Second
+
+// maxProxyHeaderV1 is a max length of the PROXY protocol v1 header including CRLF
+const maxProxyHeaderV1 = 107
+
+// proxySignatureV2 starts the PROXY protocol v2 header
+var proxySignatureV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Listener returns the listener accepting the PROXY protocol header (v1 or v2) from trusted proxies
+// the address of the header replaces the remote address of the connection
+// the header is optional, connections of untrusted hops are never checked for it
+func (r *Resolver) Listener(l net.Listener) net.Listener {
+	return &proxyListener{Listener: l, resolver: r}
+}
+
+type proxyListener struct {
+	net.Listener
+	resolver *Resolver
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.resolver.Trusted(parseIP(c.RemoteAddr().String())) {
+		return c, nil
+	}
+
+	return &proxyConn{Conn: c, r: bufio.NewReader(c)}, nil
+}
+
+// proxyConn reads the header lazily on the first read or request of the remote address,
+// so a slow proxy doesn't block accepting of other connections
+type proxyConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		// servers request the remote address before setting their own deadlines, so the reset doesn't override them
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		c.remoteAddr, c.err = readProxyHeader(c.r)
+		if c.remoteAddr == nil {
+			c.remoteAddr = c.Conn.RemoteAddr()
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	return c.remoteAddr
+}
+
+// readProxyHeader reads the header if the connection starts with it
+// returns nil address if there is no header or it has no address of the client, e.g. health checks of the proxy
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		// the error is returned by the next read
+		return nil, nil
+	}
+
+	switch b[0] {
+	case 'P':
+		if b, err := r.Peek(6); err == nil && string(b) == "PROXY " {
+			return readProxyHeaderV1(r)
+		}
+	case '\r':
+		if b, err := r.Peek(len(proxySignatureV2)); err == nil && bytes.Equal(b, proxySignatureV2) {
+			return readProxyHeaderV2(r)
+		}
+	}
+
+	return nil, nil
+}
+
+// readProxyHeaderV1 reads the text header like "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil || len(line) > maxProxyHeaderV1 || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: malformed line", ErrInvalidProxyHeader)
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: unexpected fields %q", ErrInvalidProxyHeader, fields)
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("%w: invalid source address", ErrInvalidProxyHeader)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyHeaderV2 reads the binary header
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxySignatureV2)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProxyHeader, err)
+	}
+
+	verCmd, family := header[12], header[13]
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProxyHeader, err)
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidProxyHeader, verCmd>>4)
+	}
+
+	switch cmd := verCmd & 0x0f; {
+	case cmd == 0x0:
+		// LOCAL command, the connection is made by the proxy itself
+		return nil, nil
+	case cmd != 0x1:
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidProxyHeader, cmd)
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, fmt.Errorf("%w: short ipv4 addresses", ErrInvalidProxyHeader)
+		}
+
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, fmt.Errorf("%w: short ipv6 addresses", ErrInvalidProxyHeader)
+		}
+
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+
+	// other families carry no IP of the client
+	return nil, nil
+}
@@ -0,0 +1,89 @@
+// Package realip resolves IP addresses of clients behind trusted proxies
+package realip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Resolver resolves the client IP by the connection address and forwarding info of trusted proxies
+// forwarding info of other hops is ignored, so clients can't spoof their IP
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver returns Resolver trusting the proxies, a proxy is an IP or a CIDR
+func NewResolver(proxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy ip %q", p)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy subnet: %w", err)
+		}
+		r.trusted = append(r.trusted, ipNet)
+	}
+
+	return r, nil
+}
+
+// Trusted reports whether the IP is an address of a trusted proxy
+func (r *Resolver) Trusted(ip net.IP) bool {
+	if r == nil || ip == nil {
+		return false
+	}
+
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns IP of the client by the address of the connection and values of X-Forwarded-For
+// hops are walked from the closest one while they are trusted proxies, the first untrusted hop is the client
+// returns nil if the address of the connection isn't an IP
+func (r *Resolver) ClientIP(remoteAddr string, forwardedFor []string) net.IP {
+	ip := parseIP(remoteAddr)
+
+	var hops []string
+	for _, v := range forwardedFor {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0 && r.Trusted(ip); i-- {
+		hop := parseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// the trusted proxy sent garbage, its own address is the best known one
+			break
+		}
+		ip = hop
+	}
+
+	return ip
+}
+
+// parseIP parses IP of an address with or without port
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,199 @@
+package realip_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/lib/realip"
+)
+
+func TestResolver_ClientIP(t *testing.T) {
+	r, err := realip.NewResolver([]string{"10.0.0.0/8", "192.0.2.10", "2001:db8::/32"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.5:1234",
+			want:       "203.0.113.5",
+		},
+		{
+			name:         "direct client spoofs forwarded ip",
+			remoteAddr:   "203.0.113.5:1234",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "203.0.113.5",
+		},
+		{
+			name:         "client behind trusted proxy",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "client behind chain of trusted proxies",
+			remoteAddr:   "192.0.2.10:1234",
+			forwardedFor: []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "client spoofs forwarded ip behind trusted proxy",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"10.0.0.5, 198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "trusted proxy sends garbage",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"unknown"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:         "ipv6 proxy",
+			remoteAddr:   "[2001:db8::1]:1234",
+			forwardedFor: []string{"[2001:db9::1]:4321"},
+			want:         "2001:db9::1",
+		},
+		{
+			name:       "trusted proxy without forwarded ip",
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.ClientIP(tt.remoteAddr, tt.forwardedFor).String())
+		})
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	_, err := realip.NewResolver([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	_, err = realip.NewResolver([]string{"localhost"})
+	assert.Error(t, err)
+
+	var r *realip.Resolver
+	assert.False(t, r.Trusted(net.ParseIP("10.0.0.1")), "nil resolver trusts nobody")
+}
+
+func TestResolver_Listener(t *testing.T) {
+	v2 := func(cmd byte, ip net.IP, port uint16) string {
+		b := []byte("\r\n\r\n\x00\r\nQUIT\n")
+		b = append(b, 0x20|cmd, 0x11, 0, 12)
+		b = append(b, ip.To4()...)
+		b = append(b, 192, 0, 2, 1)
+		b = binary.BigEndian.AppendUint16(b, port)
+		b = binary.BigEndian.AppendUint16(b, 443)
+		return string(b)
+	}
+
+	tests := []struct {
+		name     string
+		trusted  []string
+		header   string
+		wantAddr string
+		wantErr  bool
+		// wantPassed is set if the header must be passed to the server as is
+		wantPassed bool
+	}{
+		{
+			name:     "v1 header of trusted proxy",
+			trusted:  []string{"127.0.0.1"},
+			header:   "PROXY TCP4 198.51.100.1 192.0.2.1 56324 443\r\n",
+			wantAddr: "198.51.100.1:56324",
+		},
+		{
+			name:     "v1 header with unknown address",
+			trusted:  []string{"127.0.0.1"},
+			header:   "PROXY UNKNOWN\r\n",
+			wantAddr: "127.0.0.1",
+		},
+		{
+			name:     "v2 header of trusted proxy",
+			trusted:  []string{"127.0.0.1"},
+			header:   v2(0x1, net.ParseIP("198.51.100.1"), 56324),
+			wantAddr: "198.51.100.1:56324",
+		},
+		{
+			name:     "v2 local command",
+			trusted:  []string{"127.0.0.1"},
+			header:   v2(0x0, net.ParseIP("198.51.100.1"), 56324),
+			wantAddr: "127.0.0.1",
+		},
+		{
+			name:       "trusted proxy without header",
+			trusted:    []string{"127.0.0.1"},
+			wantAddr:   "127.0.0.1",
+			wantPassed: true,
+		},
+		{
+			name:       "header of untrusted hop isn't parsed",
+			header:     "PROXY TCP4 198.51.100.1 192.0.2.1 56324 443\r\n",
+			wantAddr:   "127.0.0.1",
+			wantPassed: true,
+		},
+		{
+			name:    "malformed v1 header",
+			trusted: []string{"127.0.0.1"},
+			header:  "PROXY TCP4 198.51.100.1\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := realip.NewResolver(tt.trusted)
+			require.NoError(t, err)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			l = r.Listener(l)
+			defer l.Close()
+
+			go func() {
+				c, err := net.Dial("tcp", l.Addr().String())
+				if err != nil {
+					return
+				}
+				defer c.Close()
+
+				io.WriteString(c, tt.header+"GET / HTTP/1.1\r\n")
+			}()
+
+			c, err := l.Accept()
+			require.NoError(t, err)
+			defer c.Close()
+
+			b, err := io.ReadAll(c)
+			if tt.wantErr {
+				require.ErrorIs(t, err, realip.ErrInvalidProxyHeader)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.wantPassed {
+				assert.Equal(t, tt.header+"GET / HTTP/1.1\r\n", string(b))
+			} else {
+				assert.Equal(t, "GET / HTTP/1.1\r\n", string(b))
+			}
+
+			host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+			require.NoError(t, err)
+			if _, _, err := net.SplitHostPort(tt.wantAddr); err == nil {
+				assert.Equal(t, tt.wantAddr, c.RemoteAddr().String())
+			} else {
+				assert.Equal(t, tt.wantAddr, host)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package useragent
+
+import "strings"
+
+// Families of browsers and operating systems
+const (
+	Other = "Other"
+	Bot   = "Bot"
+)
+
+var browsers = []struct {
+	token  string
+	family string
+}{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"yabrowser/", "Yandex Browser"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium/", "Chrome"},
+	{"safari/", "Safari"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+}
+
+var systems = []struct {
+	token  string
+	family string
+}{
+	{"android", "Android"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"windows", "Windows"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"cros", "Chrome OS"},
+	{"linux", "Linux"},
+}
+
+// Parse returns families of browser and operating system of the User-Agent header value
+// unknown families are reported as Other
+func Parse(ua string) (browser, os string) {
+	ua = strings.ToLower(ua)
+	browser, os = Other, Other
+
+	if ua == "" {
+		return browser, os
+	}
+
+	if strings.Contains(ua, "bot") || strings.Contains(ua, "spider") || strings.Contains(ua, "crawler") {
+		browser = Bot
+	} else {
+		for _, b := range browsers {
+			if strings.Contains(ua, b.token) {
+				browser = b.family
+				break
+			}
+		}
+	}
+
+	for _, s := range systems {
+		if strings.Contains(ua, s.token) {
+			os = s.family
+			break
+		}
+	}
+
+	return browser, os
+}
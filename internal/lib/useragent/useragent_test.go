@@ -0,0 +1,74 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lks-go/url-shortener/internal/lib/useragent"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		ua          string
+		wantBrowser string
+		wantOS      string
+	}{
+		{
+			name:        "chrome on windows",
+			ua:          "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			wantBrowser: "Chrome",
+			wantOS:      "Windows",
+		},
+		{
+			name:        "edge on windows",
+			ua:          "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			wantBrowser: "Edge",
+			wantOS:      "Windows",
+		},
+		{
+			name:        "safari on iphone",
+			ua:          "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			wantBrowser: "Safari",
+			wantOS:      "iOS",
+		},
+		{
+			name:        "firefox on linux",
+			ua:          "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			wantBrowser: "Firefox",
+			wantOS:      "Linux",
+		},
+		{
+			name:        "chrome on android",
+			ua:          "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			wantBrowser: "Chrome",
+			wantOS:      "Android",
+		},
+		{
+			name:        "bot",
+			ua:          "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			wantBrowser: useragent.Bot,
+			wantOS:      useragent.Other,
+		},
+		{
+			name:        "curl",
+			ua:          "curl/8.4.0",
+			wantBrowser: "curl",
+			wantOS:      useragent.Other,
+		},
+		{
+			name:        "empty",
+			ua:          "",
+			wantBrowser: useragent.Other,
+			wantOS:      useragent.Other,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			browser, os := useragent.Parse(tt.ua)
+			assert.Equal(t, tt.wantBrowser, browser)
+			assert.Equal(t, tt.wantOS, os)
+		})
+	}
+}
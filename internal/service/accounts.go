@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scopes of API keys
+const (
+	ScopeShorten = "shorten"
+	ScopeRead    = "read"
+	ScopeDelete  = "delete"
+	ScopeStats   = "stats"
+	// ScopeAccount allows managing of the account and its API keys, it's granted to cookie sessions only
+	ScopeAccount = "account"
+	// ScopeAdmin allows moderation to users having the admin role, it's granted to cookie sessions only
+	ScopeAdmin = "admin"
+)
+
+// APIKeyScopes are scopes which may be given to API keys
+var APIKeyScopes = []string{ScopeShorten, ScopeRead, ScopeDelete, ScopeStats}
+
+// SessionScopes are scopes of users authenticated by the cookie or the ID token
+var SessionScopes = []string{ScopeShorten, ScopeRead, ScopeDelete, ScopeStats, ScopeAccount, ScopeAdmin}
+
+// Restrictions of accounts
+const (
+	minLoginLength    = 3
+	maxLoginLength    = 64
+	minPasswordLength = 8
+	// maxPasswordLength is the limit of bcrypt
+	maxPasswordLength = 72
+	apiKeySize        = 32
+)
+
+// Account is a registered user, its ID is the user ID of its URLs
+type Account struct {
+	ID           string
+	Login        string
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+// APIKey is a long-lived key of the account limited by scopes
+type APIKey struct {
+	ID     string
+	UserID string
+	Name   string
+	Scopes []string
+	// Hash is sha256 of the key, the key itself is returned only on creation
+	Hash      string
+	CreatedAt time.Time
+}
+
+// AccountStorage is an interface of storage of accounts and their API keys
+type AccountStorage interface {
+	// CreateAccount returns ErrLoginTaken if the login belongs to another account
+	CreateAccount(ctx context.Context, a Account) error
+	Account(ctx context.Context, id string) (*Account, error)
+	AccountByLogin(ctx context.Context, login string) (*Account, error)
+	SaveAPIKey(ctx context.Context, k APIKey) error
+	APIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	// APIKeys returns API keys of the user in order of creation
+	APIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	// DeleteAPIKey returns ErrNotFound if the user has no key with the id
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+	// ClaimURLs moves URLs of the user to another user and returns count of moved URLs
+	ClaimURLs(ctx context.Context, fromUserID, toUserID string) (int, error)
+}
+
+// dummyPasswordHash is compared on login of unknown accounts, so they can't be found out by the response time
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// SignUp creates a new account
+// returns ErrInvalidLogin, ErrInvalidPassword or ErrLoginTaken
+func (s *Service) SignUp(ctx context.Context, login, password string) (*Account, error) {
+	if s.accountStorage == nil {
+		return nil, ErrAccountsNotSupported
+	}
+
+	if len(login) < minLoginLength || len(login) > maxLoginLength {
+		return nil, ErrInvalidLogin
+	}
+
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	a := Account{
+		ID:           uuid.NewString(),
+		Login:        login,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+
+	if err := s.accountStorage.CreateAccount(ctx, a); err != nil {
+		if errors.Is(err, ErrLoginTaken) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	return &a, nil
+}
+
+// Login returns the account if the password matches
+// returns ErrInvalidCredentials if the login is unknown or the password doesn't match
+func (s *Service) Login(ctx context.Context, login, password string) (*Account, error) {
+	if s.accountStorage == nil {
+		return nil, ErrAccountsNotSupported
+	}
+
+	a, err := s.accountStorage.AccountByLogin(ctx, login)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	case err != nil:
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a, nil
+}
+
+// ClaimURLs moves URLs of the anonymous user to the account and returns count of moved URLs
+// returns ErrForbidden if the anonymous user is another account
+func (s *Service) ClaimURLs(ctx context.Context, anonymousID, accountID string) (int, error) {
+	if s.accountStorage == nil {
+		return 0, ErrAccountsNotSupported
+	}
+
+	if anonymousID == accountID {
+		return 0, nil
+	}
+
+	_, err := s.accountStorage.Account(ctx, anonymousID)
+	switch {
+	case err == nil:
+		return 0, ErrForbidden
+	case !errors.Is(err, ErrNotFound):
+		return 0, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	cnt, err := s.accountStorage.ClaimURLs(ctx, anonymousID, accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim urls: %w", err)
+	}
+
+	return cnt, nil
+}
+
+// CreateAPIKey creates API key of the account and returns the key, only its hash is stored
+// returns ErrForbidden if the user isn't an account and ErrInvalidScope if scopes are empty or unknown
+func (s *Service) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (string, *APIKey, error) {
+	if s.accountStorage == nil {
+		return "", nil, ErrAccountsNotSupported
+	}
+
+	scopes, err := validateScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := s.checkAccount(ctx, userID); err != nil {
+		return "", nil, err
+	}
+
+	b := make([]byte, apiKeySize)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	key := base64.RawURLEncoding.EncodeToString(b)
+
+	k := APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hashAPIKey(key),
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.accountStorage.SaveAPIKey(ctx, k); err != nil {
+		return "", nil, fmt.Errorf("failed to save api key: %w", err)
+	}
+
+	return key, &k, nil
+}
+
+// APIKeys returns API keys of the account
+func (s *Service) APIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	if s.accountStorage == nil {
+		return nil, ErrAccountsNotSupported
+	}
+
+	keys, err := s.accountStorage.APIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey deletes API key of the account
+// returns ErrNotFound if the account has no key with the id
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	if s.accountStorage == nil {
+		return ErrAccountsNotSupported
+	}
+
+	err := s.accountStorage.DeleteAPIKey(ctx, userID, id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns API key by the key sent by the client
+// returns ErrInvalidAPIKey if the key is unknown or revoked
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	if s.accountStorage == nil {
+		return nil, ErrAccountsNotSupported
+	}
+
+	k, err := s.accountStorage.APIKeyByHash(ctx, hashAPIKey(key))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, ErrInvalidAPIKey
+	case err != nil:
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return k, nil
+}
+
+// checkAccount returns ErrForbidden if the user isn't a registered account
+func (s *Service) checkAccount(ctx context.Context, userID string) error {
+	_, err := s.accountStorage.Account(ctx, userID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return ErrForbidden
+	case err != nil:
+		return fmt.Errorf("failed to get account: %w", err)
+	}
+
+	return nil
+}
+
+// validateScopes returns scopes without duplicates
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	valid := make([]string, 0, len(scopes))
+	seen := make(map[string]struct{}, len(scopes))
+	for _, scope := range scopes {
+		if !HasScope(APIKeyScopes, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		valid = append(valid, scope)
+	}
+
+	return valid, nil
+}
+
+// HasScope checks if the scope is in scopes
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
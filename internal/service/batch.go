@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Statuses of items of a batch
+const (
+	// BatchStatusCreated means a new short URL is created
+	BatchStatusCreated = "created"
+	// BatchStatusExists means the URL is already shortened, the existing code is returned
+	BatchStatusExists = "exists"
+	// BatchStatusInvalid means the item is rejected, the reason is returned
+	BatchStatusInvalid = "invalid"
+)
+
+// maxBatchAttempts limits attempts to save a batch when codes or URLs are taken concurrently
+const maxBatchAttempts = 5
+
+// BatchResult is a result of shortening of a single URL of a batch
+// Code is set if Status is BatchStatusCreated or BatchStatusExists, Reason is set if Status is BatchStatusInvalid
+type BatchResult struct {
+	СorrelationID string
+	OriginalURL   string
+	Code          string
+	Status        string
+	Reason        string
+}
+
+// MakeBatchShortURL generates codes for batch of URLs and saves them with ownership of the user
+// returns a result for every item in order of the batch, invalid and already saved URLs don't fail the whole batch
+func (s *Service) MakeBatchShortURL(ctx context.Context, userID string, urls []URL) ([]BatchResult, error) {
+	results := make([]BatchResult, len(urls))
+
+	// pending keeps indexes of the batch items which are still to save
+	pending := make([]int, 0, len(urls))
+	for i, u := range urls {
+		results[i] = BatchResult{СorrelationID: u.СorrelationID, OriginalURL: u.OriginalURL}
+
+		expiresAt, err := validateBatchURL(u)
+		if err != nil {
+			results[i].Status, results[i].Reason = BatchStatusInvalid, err.Error()
+			continue
+		}
+
+		code, err := s.generateShort(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign short: %w", err)
+		}
+
+		urls[i].Code, urls[i].ExpiresAt, urls[i].TTL = code, expiresAt, 0
+		pending = append(pending, i)
+	}
+
+	existing := make([]int, 0)
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == maxBatchAttempts {
+			return nil, fmt.Errorf("failed to save batch of urls in %d attempts", maxBatchAttempts)
+		}
+
+		batch := make([]URL, 0, len(pending))
+		for _, i := range pending {
+			batch = append(batch, urls[i])
+		}
+
+		err := s.storage.SaveBatch(ctx, userID, batch)
+
+		var batchErr *BatchError
+		if err != nil && !errors.As(err, &batchErr) {
+			return nil, fmt.Errorf("failed to save batch of urls: %w", err)
+		}
+
+		if err == nil {
+			for _, i := range pending {
+				results[i].Code, results[i].Status = urls[i].Code, BatchStatusCreated
+			}
+			break
+		}
+
+		// conflicting URLs are dropped from the batch and taken codes are regenerated, the rest is saved again
+		dropped := make(map[int]struct{}, len(batchErr.Conflicts))
+		for _, c := range batchErr.Conflicts {
+			i := pending[c.Index]
+			if errors.Is(c.Err, ErrURLAlreadyExists) {
+				existing = append(existing, i)
+				dropped[i] = struct{}{}
+				continue
+			}
+
+			code, err := s.generateShort(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to assign short: %w", err)
+			}
+			urls[i].Code = code
+		}
+
+		rest := make([]int, 0, len(pending))
+		for _, i := range pending {
+			if _, ok := dropped[i]; !ok {
+				rest = append(rest, i)
+			}
+		}
+		pending = rest
+	}
+
+	// existing URLs are resolved after saving, so repeated URLs of the batch get the code of their first occurrence
+	for _, i := range existing {
+		code, err := s.storage.CodeByURL(ctx, urls[i].OriginalURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get code by URL: %w", err)
+		}
+
+		results[i].Code, results[i].Status = code, BatchStatusExists
+	}
+
+	return results, nil
+}
+
+// validateBatchURL checks the URL and returns its expiration time
+func validateBatchURL(u URL) (expiresAt time.Time, err error) {
+	if err := validateURL(u.OriginalURL); err != nil {
+		return time.Time{}, err
+	}
+
+	return expiration(u.ExpiresAt, u.TTL)
+}
+
+// validateURL checks that the URL is set and absolute
+func validateURL(u string) error {
+	if u == "" {
+		return fmt.Errorf("%w: original_url is required", ErrInvalidURL)
+	}
+
+	parsed, err := url.ParseRequestURI(u)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%w: %q must be an absolute URL", ErrInvalidURL, u)
+	}
+
+	return nil
+}
+
+// BatchConflict is a conflict of a single item of a batch
+// Err is ErrCodeAlreadyExists or ErrURLAlreadyExists
+type BatchConflict struct {
+	Index int
+	Code  string
+	Err   error
+}
+
+// BatchError is returned by URLStorage.SaveBatch if any of items conflicts with saved URLs or other items of the batch
+// nothing of the batch is saved in this case
+type BatchError struct {
+	Conflicts []BatchConflict
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Conflicts) == 1 {
+		return fmt.Sprintf("failed to save %s: %s", e.Conflicts[0].Code, e.Conflicts[0].Err)
+	}
+
+	return fmt.Sprintf("failed to save batch: %d items conflict", len(e.Conflicts))
+}
+
+// Unwrap returns errors of all conflicts, so errors.Is matches any of them
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		errs = append(errs, c.Err)
+	}
+
+	return errs
+}
+
+// CheckBatch returns *BatchError if codes or URLs of the batch are taken or repeated in the batch
+// codeTaken and urlTaken report if the code or the URL is already saved
+func CheckBatch(urls []URL, codeTaken func(code string) bool, urlTaken func(url string) bool) error {
+	batchCodes := make(map[string]struct{}, len(urls))
+	batchURLs := make(map[string]struct{}, len(urls))
+
+	conflicts := make([]BatchConflict, 0)
+	for i, u := range urls {
+		_, codeRepeated := batchCodes[u.Code]
+		_, urlRepeated := batchURLs[u.OriginalURL]
+
+		switch {
+		case codeRepeated || codeTaken(u.Code):
+			conflicts = append(conflicts, BatchConflict{Index: i, Code: u.Code, Err: ErrCodeAlreadyExists})
+		case urlRepeated || urlTaken(u.OriginalURL):
+			conflicts = append(conflicts, BatchConflict{Index: i, Code: u.Code, Err: ErrURLAlreadyExists})
+		}
+
+		batchCodes[u.Code] = struct{}{}
+		batchURLs[u.OriginalURL] = struct{}{}
+	}
+
+	if len(conflicts) > 0 {
+		return &BatchError{Conflicts: conflicts}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package of service for recording visits of short URLs
+package clickrecorder
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/lib/useragent"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// Config service config
+type Config struct {
+	StoppingTimeout  time.Duration
+	QueueSize        int
+	MaxBatchSize     int
+	BatchWaitingTime time.Duration
+}
+
+// Deps contains necessary service dependencies
+type Deps struct {
+	Storage service.ClickStorage
+}
+
+// NewRecorder service constructor
+// use only the constructor to declare ClickRecorder otherwise service will not work correctly
+func NewRecorder(cfg Config, d Deps) *ClickRecorder {
+
+	if cfg.StoppingTimeout <= 0 {
+		cfg.StoppingTimeout = time.Second * 1
+	}
+
+	if cfg.MaxBatchSize == 0 {
+		cfg.MaxBatchSize = 100
+	}
+
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = cfg.MaxBatchSize * 10
+	}
+
+	if cfg.BatchWaitingTime == 0 {
+		cfg.BatchWaitingTime = time.Second * 1
+	}
+
+	return &ClickRecorder{
+		cfg:     cfg,
+		storage: d.Storage,
+		queue:   make(chan service.Click, cfg.QueueSize),
+		stop:    make(chan struct{}),
+	}
+}
+
+// ClickRecorder service struct
+type ClickRecorder struct {
+	cfg     Config
+	storage service.ClickStorage
+	queue   chan service.Click
+	// stop is closed by Stop, the queue is never closed because handlers may still record clicks
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Start starts the worker
+func (r *ClickRecorder) Start() {
+	batch := make([]service.Click, 0, r.cfg.MaxBatchSize)
+	send, sendAndExit := false, false
+
+	ticker := time.NewTicker(r.cfg.BatchWaitingTime)
+	defer ticker.Stop()
+
+LOOP:
+	for {
+		select {
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+			send = true
+
+		case <-r.stop:
+			sendAndExit = true
+			batch = r.drain(batch)
+
+		case v := <-r.queue:
+			batch = append(batch, enrich(v))
+			if len(batch) == r.cfg.MaxBatchSize {
+				send = true
+			}
+		}
+
+		if (send || sendAndExit) && len(batch) > 0 {
+			r.save(batch)
+			batch = make([]service.Click, 0, r.cfg.MaxBatchSize)
+		}
+		send = false
+
+		if sendAndExit {
+			break LOOP
+		}
+	}
+}
+
+// drain takes the clicks left in the queue without waiting, batches are saved as soon as they are full
+func (r *ClickRecorder) drain(batch []service.Click) []service.Click {
+	for {
+		select {
+		case v := <-r.queue:
+			batch = append(batch, enrich(v))
+			if len(batch) == r.cfg.MaxBatchSize {
+				r.save(batch)
+				batch = make([]service.Click, 0, r.cfg.MaxBatchSize)
+			}
+		default:
+			return batch
+		}
+	}
+}
+
+func (r *ClickRecorder) save(batch []service.Click) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.storage.SaveClicks(ctx, batch); err != nil {
+		logrus.Errorf("failed to save clicks: %s", err)
+	}
+}
+
+// Stop stops the service, clicks recorded after the stop are rejected
+func (r *ClickRecorder) Stop() {
+	time.Sleep(r.cfg.StoppingTimeout)
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Record puts the click to the queue without waiting
+// returns service.ErrClickRecorderFull if the queue is full and the click is dropped
+// and service.ErrClickRecorderStopped if the recorder is stopped
+func (r *ClickRecorder) Record(click service.Click) error {
+	select {
+	case <-r.stop:
+		return service.ErrClickRecorderStopped
+	default:
+	}
+
+	select {
+	case r.queue <- click:
+		return nil
+	default:
+		return service.ErrClickRecorderFull
+	}
+}
+
+// enrich fills attributes of the click which are parsed from the raw request data
+func enrich(click service.Click) service.Click {
+	click.Browser, click.OS = useragent.Parse(click.UserAgent)
+	click.ReferrerDomain = referrerDomain(click.Referrer)
+
+	return click
+}
+
+func referrerDomain(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
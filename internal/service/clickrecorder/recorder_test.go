@@ -0,0 +1,41 @@
+package clickrecorder_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/service/clickrecorder"
+	"github.com/lks-go/url-shortener/internal/service/mocks"
+)
+
+func TestClickRecorder_Stop(t *testing.T) {
+	storage := mocks.NewClickStorage(t)
+	storage.On("SaveClicks", mock.Anything, mock.MatchedBy(func(clicks []service.Click) bool {
+		return len(clicks) == 2
+	})).Return(nil).Once()
+
+	r := clickrecorder.NewRecorder(clickrecorder.Config{
+		StoppingTimeout:  time.Millisecond,
+		BatchWaitingTime: time.Hour,
+	}, clickrecorder.Deps{Storage: storage})
+
+	assert.NoError(t, r.Record(service.Click{Code: "code"}))
+	assert.NoError(t, r.Record(service.Click{Code: "code"}))
+
+	done := make(chan struct{})
+	go func() {
+		r.Start()
+		close(done)
+	}()
+
+	r.Stop()
+	<-done
+
+	// clicks of redirects which are still in flight are rejected instead of panicking
+	assert.ErrorIs(t, r.Record(service.Click{Code: "code"}), service.ErrClickRecorderStopped)
+	r.Stop()
+}
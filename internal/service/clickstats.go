@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Intervals of click statistics buckets
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// Default settings of click statistics
+const (
+	DefaultStatsTop = 10
+	MaxStatsBuckets = 24 * 92
+)
+
+// ClickStatsQuery describes which clicks of the code are aggregated and how
+// the range is half-open [From, To)
+type ClickStatsQuery struct {
+	Code     string
+	From     time.Time
+	To       time.Time
+	Interval string
+	Top      int
+}
+
+// ClickBucket is a count of clicks started at Time and lasted for the query interval
+type ClickBucket struct {
+	Time   time.Time
+	Clicks int
+}
+
+// ClickGroup is a count of clicks having the same value of an attribute, e.g. the same browser
+type ClickGroup struct {
+	Name   string
+	Clicks int
+}
+
+// ClickStats contains aggregated click statistics of a short URL
+type ClickStats struct {
+	Code           string
+	Clicks         int
+	UniqueVisitors int
+	Buckets        []ClickBucket
+	Referrers      []ClickGroup
+	Browsers       []ClickGroup
+	OS             []ClickGroup
+}
+
+// ClickStats returns aggregated click statistics of the user's short URL
+// zero To means now, zero From means one day before To for hourly and thirty days for daily buckets
+// returns ErrNotFound if code doesn't exist and ErrForbidden if code belongs to another user
+func (s *Service) ClickStats(ctx context.Context, userID string, q ClickStatsQuery) (*ClickStats, error) {
+	q, err := normalizeClickStatsQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(ctx, userID, q.Code); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.clickStorage.ClickStats(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click stats: %w", err)
+	}
+
+	stats.Buckets = fillBuckets(stats.Buckets, q)
+
+	return stats, nil
+}
+
+// AggregateClicks builds click statistics from raw click events
+// it's used by storages which can't aggregate clicks by themselves
+func AggregateClicks(clicks []Click, q ClickStatsQuery) *ClickStats {
+	stats := &ClickStats{Code: q.Code}
+
+	buckets := make(map[time.Time]int)
+	referrers := make(map[string]int)
+	browsers := make(map[string]int)
+	systems := make(map[string]int)
+	visitors := make(map[string]struct{})
+
+	for _, c := range clicks {
+		if c.Code != q.Code || c.Time.Before(q.From) || !c.Time.Before(q.To) {
+			continue
+		}
+
+		stats.Clicks++
+		buckets[TruncateToInterval(c.Time, q.Interval)]++
+		visitors[c.IP+"|"+c.UserAgent] = struct{}{}
+
+		if c.ReferrerDomain != "" {
+			referrers[c.ReferrerDomain]++
+		}
+
+		if c.Browser != "" {
+			browsers[c.Browser]++
+		}
+
+		if c.OS != "" {
+			systems[c.OS]++
+		}
+	}
+
+	stats.UniqueVisitors = len(visitors)
+
+	stats.Buckets = make([]ClickBucket, 0, len(buckets))
+	for t, cnt := range buckets {
+		stats.Buckets = append(stats.Buckets, ClickBucket{Time: t, Clicks: cnt})
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		return stats.Buckets[i].Time.Before(stats.Buckets[j].Time)
+	})
+
+	stats.Referrers = topGroups(referrers, q.Top)
+	stats.Browsers = topGroups(browsers, q.Top)
+	stats.OS = topGroups(systems, q.Top)
+
+	return stats
+}
+
+// TruncateToInterval returns the start of the bucket which t belongs to
+// buckets are aligned in UTC
+func TruncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	if interval == IntervalDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	return t.Truncate(time.Hour)
+}
+
+func topGroups(groups map[string]int, top int) []ClickGroup {
+	list := make([]ClickGroup, 0, len(groups))
+	for name, cnt := range groups {
+		list = append(list, ClickGroup{Name: name, Clicks: cnt})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Clicks != list[j].Clicks {
+			return list[i].Clicks > list[j].Clicks
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	if top > 0 && top < len(list) {
+		list = list[:top]
+	}
+
+	return list
+}
+
+func normalizeClickStatsQuery(q ClickStatsQuery) (ClickStatsQuery, error) {
+	if q.Interval == "" {
+		q.Interval = IntervalDay
+	}
+
+	step, ok := intervalStep(q.Interval)
+	if !ok {
+		return q, fmt.Errorf("%w: interval must be %s or %s", ErrInvalidStatsQuery, IntervalHour, IntervalDay)
+	}
+
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+
+	if q.From.IsZero() {
+		if q.Interval == IntervalHour {
+			q.From = q.To.Add(-24 * time.Hour)
+		} else {
+			q.From = q.To.AddDate(0, 0, -30)
+		}
+	}
+
+	if !q.From.Before(q.To) {
+		return q, fmt.Errorf("%w: from must be before to", ErrInvalidStatsQuery)
+	}
+
+	if q.To.Sub(q.From)/step > MaxStatsBuckets {
+		return q, fmt.Errorf("%w: range is too wide for %s interval", ErrInvalidStatsQuery, q.Interval)
+	}
+
+	if q.Top <= 0 {
+		q.Top = DefaultStatsTop
+	}
+
+	return q, nil
+}
+
+func intervalStep(interval string) (time.Duration, bool) {
+	switch interval {
+	case IntervalHour:
+		return time.Hour, true
+	case IntervalDay:
+		return 24 * time.Hour, true
+	}
+
+	return 0, false
+}
+
+// fillBuckets adds empty buckets so that every interval of the range is present
+func fillBuckets(buckets []ClickBucket, q ClickStatsQuery) []ClickBucket {
+	counts := make(map[time.Time]int, len(buckets))
+	for _, b := range buckets {
+		counts[TruncateToInterval(b.Time, q.Interval)] += b.Clicks
+	}
+
+	filled := make([]ClickBucket, 0)
+	for t := TruncateToInterval(q.From, q.Interval); t.Before(q.To); t = nextBucket(t, q.Interval) {
+		filled = append(filled, ClickBucket{Time: t, Clicks: counts[t]})
+	}
+
+	return filled
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	if interval == IntervalDay {
+		return t.AddDate(0, 0, 1)
+	}
+
+	return t.Add(time.Hour)
+}
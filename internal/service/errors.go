@@ -4,9 +4,32 @@ import "errors"
 
 // Service domain errors
 var (
-	ErrNotFound            = errors.New("not found")
-	ErrRecordAlreadyExists = errors.New("record already exists")
-	ErrURLAlreadyExists    = errors.New("URL already exists")
-	ErrURLDeleterStopped   = errors.New("URL deleter stopped")
-	ErrDeleted             = errors.New("URL deleted")
+	ErrNotFound               = errors.New("not found")
+	ErrRecordAlreadyExists    = errors.New("record already exists")
+	ErrURLAlreadyExists       = errors.New("URL already exists")
+	ErrURLDeleterStopped      = errors.New("URL deleter stopped")
+	ErrDeleted                = errors.New("URL deleted")
+	ErrCodeAlreadyExists      = errors.New("code already exists")
+	ErrInvalidAlias           = errors.New("invalid alias")
+	ErrAliasTaken             = errors.New("alias already taken")
+	ErrExpired                = errors.New("URL expired")
+	ErrInvalidExpiration      = errors.New("invalid expiration")
+	ErrForbidden              = errors.New("forbidden")
+	ErrClickRecorderFull      = errors.New("click recorder queue is full")
+	ErrClickRecorderStopped   = errors.New("click recorder stopped")
+	ErrInvalidStatsQuery      = errors.New("invalid stats query")
+	ErrInvalidURL             = errors.New("invalid URL")
+	ErrInvalidImportRecord    = errors.New("invalid import record")
+	ErrInvalidUsersURLsQuery  = errors.New("invalid users URLs query")
+	ErrRevisionsNotSupported  = errors.New("revisions aren't supported by the storage")
+	ErrAccountsNotSupported   = errors.New("accounts aren't supported by the storage")
+	ErrInvalidLogin           = errors.New("invalid login")
+	ErrInvalidPassword        = errors.New("invalid password")
+	ErrLoginTaken             = errors.New("login already taken")
+	ErrInvalidCredentials     = errors.New("invalid login or password")
+	ErrInvalidAPIKey          = errors.New("invalid API key")
+	ErrInvalidScope           = errors.New("invalid scope")
+	ErrBlocked                = errors.New("URL blocked")
+	ErrUserBanned             = errors.New("user banned")
+	ErrModerationNotSupported = errors.New("moderation isn't supported by the storage")
 )
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ImportReader reads records of an import source one by one
+// Read returns io.EOF when the records are over and an error wrapping ErrInvalidImportRecord if a single record is malformed
+type ImportReader interface {
+	Read() (URL, error)
+}
+
+// ImportRejected is a record of an import source which isn't saved
+// Record is a zero based position of the record in the source
+type ImportRejected struct {
+	Record        int64
+	СorrelationID string
+	Reason        string
+}
+
+// ImportProgress describes a progress of an import
+// Checkpoint is a count of processed records of the source, an interrupted import is resumed by skipping them
+type ImportProgress struct {
+	Checkpoint int64
+	Created    int64
+	Exists     int64
+	Invalid    int64
+	// Rejected contains records of the last chunk which aren't saved
+	Rejected []ImportRejected
+}
+
+// ImportURLs saves records of the source with ownership of the user in chunks of Config.ImportChunkSize
+// malformed records count toward the chunk, so rejected records of a chunk are bounded as well
+// the first checkpoint records are skipped, progress is called after every saved chunk
+// returns the progress of the last saved chunk, so the import can be resumed from its checkpoint on error
+func (s *Service) ImportURLs(ctx context.Context, userID string, r ImportReader, checkpoint int64, progress func(ImportProgress) error) (ImportProgress, error) {
+	p := ImportProgress{}
+
+	for p.Checkpoint < checkpoint {
+		_, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return p, nil
+		}
+		if err != nil && !errors.Is(err, ErrInvalidImportRecord) {
+			return p, fmt.Errorf("failed to skip record %d: %w", p.Checkpoint, err)
+		}
+
+		p.Checkpoint++
+	}
+
+	for eof := false; !eof; {
+		if err := ctx.Err(); err != nil {
+			return p, err
+		}
+
+		chunk := make([]URL, 0, s.cfg.ImportChunkSize)
+		// positions keeps positions of the chunk records in the source
+		positions := make([]int64, 0, s.cfg.ImportChunkSize)
+		rejected := make([]ImportRejected, 0)
+
+		next := p.Checkpoint
+		for next-p.Checkpoint < int64(s.cfg.ImportChunkSize) {
+			u, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				eof = true
+				break
+			}
+			if errors.Is(err, ErrInvalidImportRecord) {
+				rejected = append(rejected, ImportRejected{Record: next, Reason: err.Error()})
+				next++
+				continue
+			}
+			if err != nil {
+				return p, fmt.Errorf("failed to read record %d: %w", next, err)
+			}
+
+			chunk = append(chunk, u)
+			positions = append(positions, next)
+			next++
+		}
+
+		if len(chunk) == 0 && len(rejected) == 0 {
+			break
+		}
+
+		results, err := s.MakeBatchShortURL(ctx, userID, chunk)
+		if err != nil {
+			return p, fmt.Errorf("failed to import records from %d: %w", p.Checkpoint, err)
+		}
+
+		for i, res := range results {
+			switch res.Status {
+			case BatchStatusCreated:
+				p.Created++
+			case BatchStatusExists:
+				p.Exists++
+			case BatchStatusInvalid:
+				rejected = append(rejected, ImportRejected{Record: positions[i], СorrelationID: res.СorrelationID, Reason: res.Reason})
+			}
+		}
+
+		sort.Slice(rejected, func(i, j int) bool {
+			return rejected[i].Record < rejected[j].Record
+		})
+
+		p.Invalid += int64(len(rejected))
+		p.Checkpoint = next
+		p.Rejected = rejected
+
+		if err := progress(p); err != nil {
+			return p, fmt.Errorf("failed to report progress: %w", err)
+		}
+	}
+
+	p.Rejected = nil
+
+	return p, nil
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	service "github.com/lks-go/url-shortener/internal/service"
 	mock "github.com/stretchr/testify/mock"
@@ -42,17 +43,45 @@ func (_m *URLStorage) CodeByURL(ctx context.Context, url string) (string, error)
 	return r0, r1
 }
 
-// DeleteURLs provides a mock function with given fields: ctx, codes
-func (_m *URLStorage) DeleteURLs(ctx context.Context, codes []string) error {
-	ret := _m.Called(ctx, codes)
+// DeleteExpiredURLs provides a mock function with given fields: ctx
+func (_m *URLStorage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiredURLs")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteURLs provides a mock function with given fields: ctx, userID, codes
+func (_m *URLStorage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	ret := _m.Called(ctx, userID, codes)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteURLs")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, []string) error); ok {
-		r0 = rf(ctx, codes)
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, userID, codes)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -88,17 +117,35 @@ func (_m *URLStorage) Exists(ctx context.Context, code string) (bool, error) {
 	return r0, r1
 }
 
-// Save provides a mock function with given fields: ctx, code, url
-func (_m *URLStorage) Save(ctx context.Context, code string, url string) error {
-	ret := _m.Called(ctx, code, url)
+// IterateUsersURLs provides a mock function with given fields: ctx, userID, fn
+func (_m *URLStorage) IterateUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	ret := _m.Called(ctx, userID, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IterateUsersURLs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, func(service.UsersURL) error) error); ok {
+		r0 = rf(ctx, userID, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Save provides a mock function with given fields: ctx, code, url, expiresAt
+func (_m *URLStorage) Save(ctx context.Context, code string, url string, expiresAt time.Time) error {
+	ret := _m.Called(ctx, code, url, expiresAt)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Save")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
-		r0 = rf(ctx, code, url)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) error); ok {
+		r0 = rf(ctx, code, url, expiresAt)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -106,17 +153,17 @@ func (_m *URLStorage) Save(ctx context.Context, code string, url string) error {
 	return r0
 }
 
-// SaveBatch provides a mock function with given fields: ctx, url
-func (_m *URLStorage) SaveBatch(ctx context.Context, url []service.URL) error {
-	ret := _m.Called(ctx, url)
+// SaveBatch provides a mock function with given fields: ctx, userID, urls
+func (_m *URLStorage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	ret := _m.Called(ctx, userID, urls)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SaveBatch")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, []service.URL) error); ok {
-		r0 = rf(ctx, url)
+	if rf, ok := ret.Get(0).(func(context.Context, string, []service.URL) error); ok {
+		r0 = rf(ctx, userID, urls)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -198,6 +245,24 @@ func (_m *URLStorage) URLCount(ctx context.Context) (int, error) {
 	return r0, r1
 }
 
+// UpdateURL provides a mock function with given fields: ctx, userID, code, url
+func (_m *URLStorage) UpdateURL(ctx context.Context, userID string, code string, url string) error {
+	ret := _m.Called(ctx, userID, code, url)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateURL")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, userID, code, url)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UserCount provides a mock function with given fields: ctx
 func (_m *URLStorage) UserCount(ctx context.Context) (int, error) {
 	ret := _m.Called(ctx)
@@ -256,29 +321,29 @@ func (_m *URLStorage) UsersURLCodes(ctx context.Context, userID string) ([]strin
 	return r0, r1
 }
 
-// UsersURLs provides a mock function with given fields: ctx, userID
-func (_m *URLStorage) UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error) {
-	ret := _m.Called(ctx, userID)
+// UsersURLs provides a mock function with given fields: ctx, userID, q
+func (_m *URLStorage) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	ret := _m.Called(ctx, userID, q)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UsersURLs")
 	}
 
-	var r0 []service.UsersURL
+	var r0 *service.UsersURLsPage
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]service.UsersURL, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.UsersURLsQuery) (*service.UsersURLsPage, error)); ok {
+		return rf(ctx, userID, q)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []service.UsersURL); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.UsersURLsQuery) *service.UsersURLsPage); ok {
+		r0 = rf(ctx, userID, q)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]service.UsersURL)
+			r0 = ret.Get(0).(*service.UsersURLsPage)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, service.UsersURLsQuery) error); ok {
+		r1 = rf(ctx, userID, q)
 	} else {
 		r1 = ret.Error(1)
 	}
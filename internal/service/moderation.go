@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lks-go/url-shortener/internal/service/policy"
+)
+
+// Actions of revisions made by moderation
+const (
+	RevisionBlocked   = "blocked"
+	RevisionUnblocked = "unblocked"
+)
+
+// URLInfo is a short URL with its owner and state, it's shown to admins
+type URLInfo struct {
+	Code        string
+	OriginalURL string
+	// OwnerID is the first user of the code, empty if the code has no users
+	OwnerID string
+	// ExpiresAt is zero if the URL never expires
+	ExpiresAt time.Time
+	Deleted   bool
+	Blocked   bool
+	CreatedAt time.Time
+}
+
+// ModerationStorage is an interface of storage of blocked codes and banned users
+type ModerationStorage interface {
+	// URLInfo returns ErrNotFound if the code doesn't exist
+	URLInfo(ctx context.Context, code string) (*URLInfo, error)
+	// SetURLBlocked blocks or unblocks the code, the user is the author of the change
+	// URLStorage.URL returns ErrBlocked for blocked codes
+	// returns ErrNotFound if the code doesn't exist
+	SetURLBlocked(ctx context.Context, userID, code string, blocked bool) error
+	SetUserBanned(ctx context.Context, userID string, banned bool) error
+	UserBanned(ctx context.Context, userID string) (bool, error)
+}
+
+// authorize returns ErrForbidden if the policy doesn't allow the action to the user
+func (s *Service) authorize(userID string, action policy.Action) error {
+	if !s.policy.Allowed(userID, action) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// IsAdmin checks if the user has the admin role
+func (s *Service) IsAdmin(userID string) bool {
+	return s.policy.HasRole(userID, policy.RoleAdmin)
+}
+
+// URLInfo returns any short URL with its owner to the admin
+// returns ErrForbidden if the user isn't allowed to look up URLs and ErrNotFound if the code doesn't exist
+func (s *Service) URLInfo(ctx context.Context, adminID, code string) (*URLInfo, error) {
+	if err := s.authorize(adminID, policy.ActionLookupURL); err != nil {
+		return nil, err
+	}
+
+	if s.moderationStorage == nil {
+		return nil, ErrModerationNotSupported
+	}
+
+	info, err := s.moderationStorage.URLInfo(ctx, code)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("failed to get url info: %w", err)
+	}
+
+	return info, nil
+}
+
+// ForceDeleteURL deletes the short URL of any user, the admin is the author of the deletion
+// returns ErrForbidden if the user isn't allowed to delete URLs and ErrNotFound if the code doesn't exist
+func (s *Service) ForceDeleteURL(ctx context.Context, adminID, code string) error {
+	if err := s.authorize(adminID, policy.ActionDeleteURL); err != nil {
+		return err
+	}
+
+	exists, err := s.storage.Exists(ctx, code)
+	if err != nil {
+		return fmt.Errorf("failed to check code: %w", err)
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	if err := s.storage.DeleteURLs(ctx, adminID, []string{code}); err != nil {
+		return fmt.Errorf("failed to delete url: %w", err)
+	}
+
+	return nil
+}
+
+// BlockURL blocks or unblocks the short URL of any user, blocked URLs aren't redirected
+// returns ErrForbidden if the user isn't allowed to block URLs and ErrNotFound if the code doesn't exist
+func (s *Service) BlockURL(ctx context.Context, adminID, code string, blocked bool) error {
+	if err := s.authorize(adminID, policy.ActionBlockURL); err != nil {
+		return err
+	}
+
+	if s.moderationStorage == nil {
+		return ErrModerationNotSupported
+	}
+
+	err := s.moderationStorage.SetURLBlocked(ctx, adminID, code, blocked)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to set url blocked: %w", err)
+	}
+
+	// the moderation storage isn't wrapped by the cache, so the code is dropped explicitly
+	if s.cache != nil {
+		s.cache.Invalidate(code)
+	}
+
+	return nil
+}
+
+// UserURLs returns a page of URLs of any user to the admin
+// returns ErrForbidden if the user isn't allowed to list URLs of other users
+func (s *Service) UserURLs(ctx context.Context, adminID, userID string, q UsersURLsQuery) (*UsersURLsPage, error) {
+	if err := s.authorize(adminID, policy.ActionListUserURLs); err != nil {
+		return nil, err
+	}
+
+	return s.UsersURLs(ctx, userID, q)
+}
+
+// BanUser bans or unbans the user, banned users are rejected by CheckBanned
+// returns ErrForbidden if the user isn't allowed to ban users or the banned user is an admin
+func (s *Service) BanUser(ctx context.Context, adminID, userID string, banned bool) error {
+	if err := s.authorize(adminID, policy.ActionBanUser); err != nil {
+		return err
+	}
+
+	// admins can't lock each other out, they are removed from the config instead
+	if s.IsAdmin(userID) {
+		return ErrForbidden
+	}
+
+	if s.moderationStorage == nil {
+		return ErrModerationNotSupported
+	}
+
+	if err := s.moderationStorage.SetUserBanned(ctx, userID, banned); err != nil {
+		return fmt.Errorf("failed to set user banned: %w", err)
+	}
+
+	return nil
+}
+
+// CheckBanned returns ErrUserBanned if the user is banned
+// nobody is banned if the storage doesn't support moderation
+func (s *Service) CheckBanned(ctx context.Context, userID string) error {
+	if s.moderationStorage == nil {
+		return nil
+	}
+
+	banned, err := s.moderationStorage.UserBanned(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check user ban: %w", err)
+	}
+
+	if banned {
+		return ErrUserBanned
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// Package policy decides which privileged actions users may perform by their roles
+// it's shared by all transports, so authorization rules live in one place
+package policy
+
+// Roles of users
+const (
+	// RoleAdmin moderates short URLs and users of the service
+	RoleAdmin = "admin"
+)
+
+// Action is a privileged action guarded by the policy
+type Action string
+
+// Actions of moderation
+const (
+	ActionLookupURL    Action = "url:lookup"
+	ActionDeleteURL    Action = "url:delete"
+	ActionBlockURL     Action = "url:block"
+	ActionListUserURLs Action = "user:urls"
+	ActionBanUser      Action = "user:ban"
+)
+
+// grants are actions allowed to roles
+var grants = map[string][]Action{
+	RoleAdmin: {ActionLookupURL, ActionDeleteURL, ActionBlockURL, ActionListUserURLs, ActionBanUser},
+}
+
+// Policy assigns roles to users, users without roles may perform no privileged actions
+type Policy struct {
+	roles map[string][]string
+}
+
+// New returns Policy giving RoleAdmin to the admins
+func New(admins []string) *Policy {
+	p := &Policy{roles: make(map[string][]string, len(admins))}
+	for _, userID := range admins {
+		if userID != "" && !p.HasRole(userID, RoleAdmin) {
+			p.roles[userID] = append(p.roles[userID], RoleAdmin)
+		}
+	}
+
+	return p
+}
+
+// Roles returns roles of the user
+func (p *Policy) Roles(userID string) []string {
+	if p == nil {
+		return nil
+	}
+
+	return p.roles[userID]
+}
+
+// HasRole checks if the user has the role
+func (p *Policy) HasRole(userID, role string) bool {
+	for _, r := range p.Roles(userID) {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allowed checks if any role of the user grants the action
+func (p *Policy) Allowed(userID string, action Action) bool {
+	for _, role := range p.Roles(userID) {
+		for _, a := range grants[role] {
+			if a == action {
+				return true
+			}
+		}
+	}
+
+	return false
+}
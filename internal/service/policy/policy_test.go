@@ -0,0 +1,36 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lks-go/url-shortener/internal/service/policy"
+)
+
+func TestPolicy_Allowed(t *testing.T) {
+	p := policy.New([]string{"admin", "admin", ""})
+
+	tests := []struct {
+		name   string
+		userID string
+		action policy.Action
+		want   bool
+	}{
+		{name: "admin looks up URLs", userID: "admin", action: policy.ActionLookupURL, want: true},
+		{name: "admin bans users", userID: "admin", action: policy.ActionBanUser, want: true},
+		{name: "user can't look up URLs", userID: "user", action: policy.ActionLookupURL},
+		{name: "user without ID has no roles", userID: "", action: policy.ActionDeleteURL},
+		{name: "unknown action", userID: "admin", action: "url:steal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Allowed(tt.userID, tt.action))
+		})
+	}
+
+	assert.Equal(t, []string{policy.RoleAdmin}, p.Roles("admin"), "roles aren't duplicated")
+
+	var nilPolicy *policy.Policy
+	assert.False(t, nilPolicy.Allowed("admin", policy.ActionLookupURL), "nil policy grants nothing")
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Actions of revisions
+const (
+	RevisionCreated  = "created"
+	RevisionUpdated  = "updated"
+	RevisionDeleted  = "deleted"
+	RevisionExpired  = "expired"
+	RevisionRestored = "restored"
+)
+
+// Revision is a single change of a short URL
+type Revision struct {
+	ID     int64
+	Code   string
+	Action string
+	// URL is the destination after the change and PreviousURL is the destination before it, empty for RevisionCreated
+	URL         string
+	PreviousURL string
+	// UserID is the author of the change, empty if the change is made by the service itself, e.g. on expiration
+	UserID string
+	Time   time.Time
+}
+
+// RevisionStorage is an interface of storage of the audit trail of short URLs
+type RevisionStorage interface {
+	// Revisions returns revisions of the code in order of making
+	Revisions(ctx context.Context, code string) ([]Revision, error)
+	// RestoreURL sets the destination of the revision to the code and undeletes it, zero revisionID only undeletes the code
+	// returns ErrNotFound if the code or its revision doesn't exist and ErrURLAlreadyExists if the URL is saved with another code
+	RestoreURL(ctx context.Context, userID, code string, revisionID int64) error
+}
+
+// URLHistory returns revisions of the user's short URL in order of making
+// returns ErrNotFound if code doesn't exist and ErrForbidden if code belongs to another user
+func (s *Service) URLHistory(ctx context.Context, userID, code string) ([]Revision, error) {
+	if s.revisionStorage == nil {
+		return nil, ErrRevisionsNotSupported
+	}
+
+	if err := s.checkOwner(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.revisionStorage.Revisions(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RestoreURL reverts the user's short URL to the destination of the revision and undeletes it
+// if revisionID is zero only the deletion is reverted, an expired URL stays expired
+func (s *Service) RestoreURL(ctx context.Context, userID, code string, revisionID int64) error {
+	if s.revisionStorage == nil {
+		return ErrRevisionsNotSupported
+	}
+
+	if err := s.checkOwner(ctx, userID, code); err != nil {
+		return err
+	}
+
+	err := s.revisionStorage.RestoreURL(ctx, userID, code, revisionID)
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrURLAlreadyExists):
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to restore url: %w", err)
+	}
+
+	// the revision storage isn't wrapped by the cache, so the code is dropped explicitly
+	if s.cache != nil {
+		s.cache.Invalidate(code)
+	}
+
+	return nil
+}
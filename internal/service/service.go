@@ -5,6 +5,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lks-go/url-shortener/internal/service/policy"
 )
 
 // URL a main domain struct of URL
@@ -12,82 +16,227 @@ type URL struct {
 	СorrelationID string
 	OriginalURL   string
 	Code          string
+	// ExpiresAt is a time after which the URL stops working, zero value means never
+	ExpiresAt time.Time
+	// TTL is an alternative to ExpiresAt, it's counted from the moment of shortening
+	TTL time.Duration
 }
 
 // UsersURL a domain struct describes which shorten code belongs to URL
 type UsersURL struct {
 	Code        string
 	OriginalURL string
+	// ExpiresAt is zero if the URL never expires
+	ExpiresAt time.Time
+	Deleted   bool
+	// Clicks is set by URLStorage.UsersURLs only
+	Clicks int
 }
 
 // URLStorage is an interface of URL storage
 type URLStorage interface {
-	Save(ctx context.Context, code, url string) error
-	SaveBatch(ctx context.Context, url []URL) error
+	Save(ctx context.Context, code, url string, expiresAt time.Time) error
+	// SaveBatch saves URLs and their ownership by the user in one transaction, the user is optional
+	// returns *BatchError if any of URLs conflicts
+	SaveBatch(ctx context.Context, userID string, urls []URL) error
 	Exists(ctx context.Context, code string) (bool, error)
 	URL(ctx context.Context, id string) (string, error)
 	CodeByURL(ctx context.Context, url string) (string, error)
 	SaveUsersCode(ctx context.Context, userID string, code string) error
 	UsersURLCodes(ctx context.Context, userID string) ([]string, error)
-	DeleteURLs(ctx context.Context, codes []string) error
-	UsersURLs(ctx context.Context, userID string) ([]UsersURL, error)
+	// DeleteURLs marks URLs as deleted, the user is the author of the deletion
+	DeleteURLs(ctx context.Context, userID string, codes []string) error
+	// UpdateURL replaces URL of the code by the user, the previous URL becomes free for shortening
+	// returns ErrNotFound, ErrDeleted or ErrURLAlreadyExists if the URL is saved with another code
+	UpdateURL(ctx context.Context, userID, code, url string) error
+	// UsersURLs returns a page of user's URLs by the query
+	UsersURLs(ctx context.Context, userID string, q UsersURLsQuery) (*UsersURLsPage, error)
+	// IterateUsersURLs calls fn for every URL of the user in order of saving without loading all of them into memory
+	// iteration stops on the first error of fn and the error is returned
+	IterateUsersURLs(ctx context.Context, userID string, fn func(UsersURL) error) error
 	URLCount(ctx context.Context) (int, error)
 	UserCount(ctx context.Context) (int, error)
+	DeleteExpiredURLs(ctx context.Context) (int, error)
+}
+
+// Default settings of custom aliases
+const (
+	DefaultAliasAlphabet  = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	DefaultAliasMinLength = 3
+	DefaultAliasMaxLength = 32
+)
+
+// DefaultImportChunkSize is a default count of records saved by a single SaveBatch call while importing
+const DefaultImportChunkSize = 1000
+
+// routeAliases are words which conflict with the HTTP routes and can't be used as alias
+var routeAliases = []string{"api", "ping"}
+
+// Click describes a single visit of a short URL
+type Click struct {
+	Code      string
+	Time      time.Time
+	Referrer  string
+	UserAgent string
+	IP        string
+	// ReferrerDomain, Browser and OS are parsed from Referrer and UserAgent before saving
+	ReferrerDomain string
+	Browser        string
+	OS             string
+}
+
+// ClickStorage is an interface of storage of short URL visits
+type ClickStorage interface {
+	SaveClicks(ctx context.Context, clicks []Click) error
+	ClickCount(ctx context.Context, code string) (int, error)
+	Clicks(ctx context.Context, code string, limit int) ([]Click, error)
+	ClickStats(ctx context.Context, q ClickStatsQuery) (*ClickStats, error)
+}
+
+// CacheStats contains counters of the URL storage cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// URLCache is an interface of cache of URL storage
+type URLCache interface {
+	CacheStats() CacheStats
+	// Invalidate drops the cached codes, it's used when codes are changed bypassing the cache
+	Invalidate(codes ...string)
 }
 
 // Config is a service config
 type Config struct {
-	IDSize int
+	IDSize          int
+	AliasAlphabet   string
+	AliasMinLength  int
+	AliasMaxLength  int
+	ReservedAliases []string
+	// ImportChunkSize is a count of source records, including malformed ones, processed by a single SaveBatch call while importing
+	ImportChunkSize int
 }
 
 // Dependencies is a struct contains main service dependencies
 type Dependencies struct {
 	Storage      URLStorage
+	ClickStorage ClickStorage
+	// RevisionStorage is optional, history and restoring of URLs aren't supported without it
+	RevisionStorage RevisionStorage
+	// AccountStorage is optional, accounts and API keys aren't supported without it
+	AccountStorage AccountStorage
+	// ModerationStorage is optional, blocking of URLs and banning of users aren't supported without it
+	ModerationStorage ModerationStorage
+	// Policy grants privileged actions by roles of users, nobody has roles if it's nil
+	Policy       *policy.Policy
 	RandomString func(size int) string
+	// Cache is optional, it's set if Storage is wrapped by a cache
+	Cache URLCache
 }
 
 // New is a service constructor
 // to declare Service use only the constructor recommended
 func New(cfg Config, deps Dependencies) *Service {
+	if cfg.AliasAlphabet == "" {
+		cfg.AliasAlphabet = DefaultAliasAlphabet
+	}
+
+	if cfg.AliasMinLength <= 0 {
+		cfg.AliasMinLength = DefaultAliasMinLength
+	}
+
+	if cfg.AliasMaxLength <= 0 {
+		cfg.AliasMaxLength = DefaultAliasMaxLength
+	}
+
+	if cfg.ImportChunkSize <= 0 {
+		cfg.ImportChunkSize = DefaultImportChunkSize
+	}
+
+	cfg.ReservedAliases = append(append([]string{}, routeAliases...), cfg.ReservedAliases...)
+
 	return &Service{
-		cfg:          cfg,
-		storage:      deps.Storage,
-		randomString: deps.RandomString,
+		cfg:               cfg,
+		storage:           deps.Storage,
+		clickStorage:      deps.ClickStorage,
+		revisionStorage:   deps.RevisionStorage,
+		accountStorage:    deps.AccountStorage,
+		moderationStorage: deps.ModerationStorage,
+		policy:            deps.Policy,
+		randomString:      deps.RandomString,
+		cache:             deps.Cache,
 	}
 }
 
 // Service is a main service structure
 type Service struct {
-	cfg          Config
-	storage      URLStorage
-	randomString func(size int) string
+	cfg               Config
+	storage           URLStorage
+	clickStorage      ClickStorage
+	revisionStorage   RevisionStorage
+	accountStorage    AccountStorage
+	moderationStorage ModerationStorage
+	policy            *policy.Policy
+	randomString      func(size int) string
+	cache             URLCache
+}
+
+// ShortenOptions contains optional parameters of a short URL
+type ShortenOptions struct {
+	// Alias is a custom code chosen by the user instead of the random one
+	Alias string
+	// ExpiresAt is a time after which the short URL stops working
+	ExpiresAt time.Time
+	// TTL is an alternative to ExpiresAt, it's counted from the moment of shortening
+	TTL time.Duration
 }
 
 // MakeShortURL generates code and save generated code with URL
+// if opts.Alias is set it is used as the code instead of the generated one
 // returns generated code
-// if code or URL already exist returns the error ErrURLAlreadyExists
-func (s *Service) MakeShortURL(ctx context.Context, userID, url string) (string, error) {
-	code, err := s.generateShort(ctx)
+// if URL already exists returns the existing code and the error ErrURLAlreadyExists
+// if the alias is held by another URL returns the error ErrAliasTaken
+func (s *Service) MakeShortURL(ctx context.Context, userID, url string, opts ShortenOptions) (string, error) {
+	expiresAt, err := expiration(opts.ExpiresAt, opts.TTL)
 	if err != nil {
-		return "", fmt.Errorf("failed to assign short: %w", err)
+		return "", err
 	}
 
-	err = s.storage.Save(ctx, code, url)
-	if err != nil && !errors.Is(err, ErrURLAlreadyExists) {
-		return "", fmt.Errorf("filed to save url: %w", err)
+	code := opts.Alias
+	if code != "" {
+		if err := s.validateAlias(code); err != nil {
+			return "", err
+		}
+	} else {
+		code, err = s.generateShort(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to assign short: %w", err)
+		}
 	}
 
-	if err := s.storage.SaveUsersCode(ctx, userID, code); err != nil {
-		return "", fmt.Errorf("failed to save user code: %w", err)
-	}
+	err = s.storage.Save(ctx, code, url, expiresAt)
+	switch {
+	case errors.Is(err, ErrCodeAlreadyExists) && opts.Alias != "":
+		existingCode, err := s.storage.CodeByURL(ctx, url)
+		if err == nil && existingCode == code {
+			return code, ErrURLAlreadyExists
+		}
 
-	if errors.Is(err, ErrURLAlreadyExists) {
+		return "", ErrAliasTaken
+	case errors.Is(err, ErrURLAlreadyExists):
 		code, err = s.storage.CodeByURL(ctx, url)
 		if err != nil {
 			return "", fmt.Errorf("failed to get ID by URL: %w", err)
 		}
 
 		return code, ErrURLAlreadyExists
+	case err != nil:
+		return "", fmt.Errorf("filed to save url: %w", err)
+	}
+
+	if err := s.storage.SaveUsersCode(ctx, userID, code); err != nil {
+		return "", fmt.Errorf("failed to save user code: %w", err)
 	}
 
 	return code, nil
@@ -103,45 +252,49 @@ func (s *Service) URL(ctx context.Context, id string) (string, error) {
 	return url, nil
 }
 
-// MakeBatchShortURL generates codes for batch of URLs
-func (s *Service) MakeBatchShortURL(ctx context.Context, userID string, urls []URL) ([]URL, error) {
-
-	for i := range urls {
-		code, err := s.generateShort(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to assign short: %w", err)
-		}
-
-		urls[i].Code = code
+// UpdateURL retargets the user's short URL to another URL, the URL is validated as a new one
+// returns ErrForbidden if code belongs to another user and ErrDeleted if the short URL is deleted
+// if the URL is already shortened with another code returns the code and the error ErrURLAlreadyExists
+func (s *Service) UpdateURL(ctx context.Context, userID, code, url string) (string, error) {
+	if err := validateURL(url); err != nil {
+		return "", err
 	}
 
-	if err := s.storage.SaveBatch(ctx, urls); err != nil {
-		return nil, fmt.Errorf("failed to save batch of urls: %w", err)
+	if err := s.checkOwner(ctx, userID, code); err != nil {
+		return "", err
 	}
 
-	for _, u := range urls {
-		if err := s.storage.SaveUsersCode(ctx, userID, u.Code); err != nil {
-			return nil, fmt.Errorf("failed to save user code: %w", err)
+	err := s.storage.UpdateURL(ctx, userID, code, url)
+	switch {
+	case errors.Is(err, ErrURLAlreadyExists):
+		existingCode, err := s.storage.CodeByURL(ctx, url)
+		if err != nil {
+			return "", fmt.Errorf("failed to get ID by URL: %w", err)
 		}
+
+		return existingCode, ErrURLAlreadyExists
+	case err != nil:
+		return "", fmt.Errorf("failed to update url: %w", err)
 	}
 
-	return urls, nil
+	return code, nil
 }
 
-// UsersURLs reruns list of URLs added by user
-func (s *Service) UsersURLs(ctx context.Context, userID string) ([]UsersURL, error) {
-	userURLs, err := s.storage.UsersURLs(ctx, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get users urls from storage: %w", err)
+// ExportUsersURLs calls fn for every URL added by user, URLs are read from the storage one by one
+func (s *Service) ExportUsersURLs(ctx context.Context, userID string, fn func(UsersURL) error) error {
+	if err := s.storage.IterateUsersURLs(ctx, userID, fn); err != nil {
+		return fmt.Errorf("failed to iterate users urls: %w", err)
 	}
 
-	return userURLs, nil
+	return nil
 }
 
 // StatsInfo contains stats data about URLS and users count
+// Cache is nil if the storage isn't cached
 type StatsInfo struct {
 	URLCount  int
 	UserCount int
+	Cache     *CacheStats
 }
 
 // Stats gets user and URL count from DB
@@ -156,7 +309,101 @@ func (s *Service) Stats(ctx context.Context) (*StatsInfo, error) {
 		return nil, fmt.Errorf("failed to get user count: %w", err)
 	}
 
-	return &StatsInfo{URLCount: urlCount, UserCount: userCount}, nil
+	info := &StatsInfo{URLCount: urlCount, UserCount: userCount}
+	if s.cache != nil {
+		cacheStats := s.cache.CacheStats()
+		info.Cache = &cacheStats
+	}
+
+	return info, nil
+}
+
+// URLStats contains visits statistics of a short URL
+type URLStats struct {
+	Code   string
+	Clicks int
+	Events []Click
+}
+
+// URLStats returns count of clicks and the latest click events of the user's short URL
+// returns ErrNotFound if code doesn't exist and ErrForbidden if code belongs to another user
+func (s *Service) URLStats(ctx context.Context, userID, code string, limit int) (*URLStats, error) {
+	if err := s.checkOwner(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	cnt, err := s.clickStorage.ClickCount(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click count: %w", err)
+	}
+
+	events, err := s.clickStorage.Clicks(ctx, code, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clicks: %w", err)
+	}
+
+	return &URLStats{Code: code, Clicks: cnt, Events: events}, nil
+}
+
+func (s *Service) checkOwner(ctx context.Context, userID, code string) error {
+	codes, err := s.storage.UsersURLCodes(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user codes: %w", err)
+	}
+
+	for _, c := range codes {
+		if c == code {
+			return nil
+		}
+	}
+
+	exists, err := s.storage.Exists(ctx, code)
+	if err != nil {
+		return fmt.Errorf("failed to check code: %w", err)
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	return ErrForbidden
+}
+
+// expiration resolves the moment when URL expires from an absolute time or TTL
+// returns zero time if URL never expires
+func expiration(expiresAt time.Time, ttl time.Duration) (time.Time, error) {
+	switch {
+	case !expiresAt.IsZero() && ttl != 0:
+		return time.Time{}, fmt.Errorf("%w: expires_at and ttl can't be set together", ErrInvalidExpiration)
+	case ttl < 0:
+		return time.Time{}, fmt.Errorf("%w: ttl must be positive", ErrInvalidExpiration)
+	case ttl > 0:
+		return time.Now().Add(ttl), nil
+	case !expiresAt.IsZero() && !expiresAt.After(time.Now()):
+		return time.Time{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiration)
+	}
+
+	return expiresAt, nil
+}
+
+func (s *Service) validateAlias(alias string) error {
+	if len(alias) < s.cfg.AliasMinLength || len(alias) > s.cfg.AliasMaxLength {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, s.cfg.AliasMinLength, s.cfg.AliasMaxLength)
+	}
+
+	for _, r := range alias {
+		if !strings.ContainsRune(s.cfg.AliasAlphabet, r) {
+			return fmt.Errorf("%w: character %q is not allowed", ErrInvalidAlias, r)
+		}
+	}
+
+	for _, reserved := range s.cfg.ReservedAliases {
+		if strings.EqualFold(alias, reserved) {
+			return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+		}
+	}
+
+	return nil
 }
 
 func (s *Service) generateShort(ctx context.Context) (string, error) {
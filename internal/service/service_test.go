@@ -2,13 +2,20 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/lks-go/url-shortener/internal/lib/random"
 	"github.com/lks-go/url-shortener/internal/service"
 	"github.com/lks-go/url-shortener/internal/service/mocks"
+	"github.com/lks-go/url-shortener/internal/service/policy"
 	"github.com/lks-go/url-shortener/internal/transport/inmemstorage"
 )
 
@@ -40,7 +47,7 @@ func TestService_MakeShortURL(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := s.MakeShortURL(context.Background(), "", tt.url)
+			got, err := s.MakeShortURL(context.Background(), "", tt.url, service.ShortenOptions{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("MakeShortURL() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -52,6 +59,511 @@ func TestService_MakeShortURL(t *testing.T) {
 	}
 }
 
+func TestService_MakeShortURLWithAlias(t *testing.T) {
+	deps := service.Dependencies{
+		Storage:      inmemstorage.MustNew(map[string]string{"taken": "http://taken.ru"}),
+		RandomString: random.NewString,
+	}
+
+	s := service.New(service.Config{IDSize: 6, ReservedAliases: []string{"admin"}}, deps)
+
+	tests := []struct {
+		name    string
+		url     string
+		alias   string
+		wantID  string
+		wantErr error
+	}{
+		{
+			name:   "free alias",
+			url:    "http://ya.ru",
+			alias:  "launch-2026",
+			wantID: "launch-2026",
+		},
+		{
+			name:    "alias held by another URL",
+			url:     "http://google.com",
+			alias:   "taken",
+			wantErr: service.ErrAliasTaken,
+		},
+		{
+			name:    "too short alias",
+			url:     "http://google.com",
+			alias:   "ab",
+			wantErr: service.ErrInvalidAlias,
+		},
+		{
+			name:    "not allowed character",
+			url:     "http://google.com",
+			alias:   "launch/2026",
+			wantErr: service.ErrInvalidAlias,
+		},
+		{
+			name:    "route word",
+			url:     "http://google.com",
+			alias:   "API",
+			wantErr: service.ErrInvalidAlias,
+		},
+		{
+			name:    "configured reserved word",
+			url:     "http://google.com",
+			alias:   "admin",
+			wantErr: service.ErrInvalidAlias,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.MakeShortURL(context.Background(), "", tt.url, service.ShortenOptions{Alias: tt.alias})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantID, got)
+		})
+	}
+}
+
+func TestService_MakeShortURLWithExpiration(t *testing.T) {
+	deps := service.Dependencies{
+		Storage:      inmemstorage.MustNew(map[string]string{}),
+		RandomString: random.NewString,
+	}
+
+	s := service.New(service.Config{IDSize: 6}, deps)
+
+	tests := []struct {
+		name    string
+		url     string
+		opts    service.ShortenOptions
+		wantErr error
+	}{
+		{
+			name: "ttl",
+			url:  "http://ya.ru",
+			opts: service.ShortenOptions{TTL: time.Hour},
+		},
+		{
+			name: "expires at",
+			url:  "http://google.com",
+			opts: service.ShortenOptions{ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name:    "expires at in the past",
+			url:     "http://yandex.ru",
+			opts:    service.ShortenOptions{ExpiresAt: time.Now().Add(-time.Hour)},
+			wantErr: service.ErrInvalidExpiration,
+		},
+		{
+			name:    "negative ttl",
+			url:     "http://yandex.ru",
+			opts:    service.ShortenOptions{TTL: -time.Hour},
+			wantErr: service.ErrInvalidExpiration,
+		},
+		{
+			name:    "both ttl and expires at",
+			url:     "http://yandex.ru",
+			opts:    service.ShortenOptions{TTL: time.Hour, ExpiresAt: time.Now().Add(time.Hour)},
+			wantErr: service.ErrInvalidExpiration,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := s.MakeShortURL(context.Background(), "", tt.url, tt.opts)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			url, err := s.URL(context.Background(), code)
+			require.NoError(t, err)
+			assert.Equal(t, tt.url, url)
+		})
+	}
+}
+
+func TestService_URLExpired(t *testing.T) {
+	storage := inmemstorage.MustNew(map[string]string{})
+	require.NoError(t, storage.Save(context.Background(), "abcdef", "http://ya.ru", time.Now().Add(-time.Second)))
+
+	s := service.New(service.Config{}, service.Dependencies{Storage: storage})
+
+	_, err := s.URL(context.Background(), "abcdef")
+	require.ErrorIs(t, err, service.ErrExpired)
+}
+
+func TestService_URLStats(t *testing.T) {
+	storageMock := mocks.NewURLStorage(t)
+	clickStorageMock := mocks.NewClickStorage(t)
+
+	s := service.New(service.Config{}, service.Dependencies{
+		Storage:      storageMock,
+		ClickStorage: clickStorageMock,
+	})
+
+	clicks := []service.Click{{Code: "abc", Time: time.Now(), IP: "127.0.0.1"}}
+
+	tests := []struct {
+		name      string
+		userID    string
+		code      string
+		want      *service.URLStats
+		wantErr   error
+		callMocks func()
+	}{
+		{
+			name:   "owner gets stats",
+			userID: "owner",
+			code:   "abc",
+			want:   &service.URLStats{Code: "abc", Clicks: 1, Events: clicks},
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				clickStorageMock.On("ClickCount", mock.Anything, "abc").Return(1, nil).Once()
+				clickStorageMock.On("Clicks", mock.Anything, "abc", 10).Return(clicks, nil).Once()
+			},
+		},
+		{
+			name:    "another user is forbidden",
+			userID:  "stranger",
+			code:    "abc",
+			wantErr: service.ErrForbidden,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "stranger").Return([]string{"xyz"}, nil).Once()
+				storageMock.On("Exists", mock.Anything, "abc").Return(true, nil).Once()
+			},
+		},
+		{
+			name:    "unknown code",
+			userID:  "stranger",
+			code:    "nope",
+			wantErr: service.ErrNotFound,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "stranger").Return([]string{}, nil).Once()
+				storageMock.On("Exists", mock.Anything, "nope").Return(false, nil).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			got, err := s.URLStats(context.Background(), tt.userID, tt.code, 10)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_UpdateURL(t *testing.T) {
+	storageMock := mocks.NewURLStorage(t)
+
+	s := service.New(service.Config{}, service.Dependencies{Storage: storageMock})
+
+	tests := []struct {
+		name      string
+		userID    string
+		code      string
+		url       string
+		want      string
+		wantErr   error
+		callMocks func()
+	}{
+		{
+			name:   "owner retargets code",
+			userID: "owner",
+			code:   "abc",
+			url:    "https://ya.com",
+			want:   "abc",
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				storageMock.On("UpdateURL", mock.Anything, "owner", "abc", "https://ya.com").Return(nil).Once()
+			},
+		},
+		{
+			name:      "invalid URL",
+			userID:    "owner",
+			code:      "abc",
+			url:       "ya.com",
+			wantErr:   service.ErrInvalidURL,
+			callMocks: func() {},
+		},
+		{
+			name:    "another user is forbidden",
+			userID:  "stranger",
+			code:    "abc",
+			url:     "https://ya.com",
+			wantErr: service.ErrForbidden,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "stranger").Return([]string{}, nil).Once()
+				storageMock.On("Exists", mock.Anything, "abc").Return(true, nil).Once()
+			},
+		},
+		{
+			name:    "URL is shortened with another code",
+			userID:  "owner",
+			code:    "abc",
+			url:     "https://google.com",
+			want:    "xyz",
+			wantErr: service.ErrURLAlreadyExists,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				storageMock.On("UpdateURL", mock.Anything, "owner", "abc", "https://google.com").Return(service.ErrURLAlreadyExists).Once()
+				storageMock.On("CodeByURL", mock.Anything, "https://google.com").Return("xyz", nil).Once()
+			},
+		},
+		{
+			name:    "deleted code",
+			userID:  "owner",
+			code:    "abc",
+			url:     "https://ya.com",
+			wantErr: service.ErrDeleted,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				storageMock.On("UpdateURL", mock.Anything, "owner", "abc", "https://ya.com").Return(service.ErrDeleted).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			got, err := s.UpdateURL(context.Background(), tt.userID, tt.code, tt.url)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_URLHistory(t *testing.T) {
+	storageMock := mocks.NewURLStorage(t)
+	revisionStorageMock := mocks.NewRevisionStorage(t)
+
+	s := service.New(service.Config{}, service.Dependencies{Storage: storageMock, RevisionStorage: revisionStorageMock})
+
+	revisions := []service.Revision{
+		{ID: 1, Code: "abc", Action: service.RevisionCreated, URL: "https://ya.ru", UserID: "owner"},
+		{ID: 2, Code: "abc", Action: service.RevisionUpdated, URL: "https://ya.com", PreviousURL: "https://ya.ru", UserID: "owner"},
+	}
+
+	storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+	revisionStorageMock.On("Revisions", mock.Anything, "abc").Return(revisions, nil).Once()
+
+	got, err := s.URLHistory(context.Background(), "owner", "abc")
+	require.NoError(t, err)
+	assert.Equal(t, revisions, got)
+
+	storageMock.On("UsersURLCodes", mock.Anything, "stranger").Return([]string{}, nil).Once()
+	storageMock.On("Exists", mock.Anything, "abc").Return(true, nil).Once()
+
+	_, err = s.URLHistory(context.Background(), "stranger", "abc")
+	require.ErrorIs(t, err, service.ErrForbidden)
+
+	s = service.New(service.Config{}, service.Dependencies{Storage: storageMock})
+	_, err = s.URLHistory(context.Background(), "owner", "abc")
+	require.ErrorIs(t, err, service.ErrRevisionsNotSupported)
+}
+
+func TestService_RestoreURL(t *testing.T) {
+	storageMock := mocks.NewURLStorage(t)
+	revisionStorageMock := mocks.NewRevisionStorage(t)
+	cacheMock := mocks.NewURLCache(t)
+
+	s := service.New(service.Config{}, service.Dependencies{
+		Storage:         storageMock,
+		RevisionStorage: revisionStorageMock,
+		Cache:           cacheMock,
+	})
+
+	tests := []struct {
+		name       string
+		userID     string
+		code       string
+		revisionID int64
+		wantErr    error
+		callMocks  func()
+	}{
+		{
+			name:       "owner restores revision",
+			userID:     "owner",
+			code:       "abc",
+			revisionID: 1,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				revisionStorageMock.On("RestoreURL", mock.Anything, "owner", "abc", int64(1)).Return(nil).Once()
+				cacheMock.On("Invalidate", "abc").Once()
+			},
+		},
+		{
+			name:    "another user is forbidden",
+			userID:  "stranger",
+			code:    "abc",
+			wantErr: service.ErrForbidden,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "stranger").Return([]string{}, nil).Once()
+				storageMock.On("Exists", mock.Anything, "abc").Return(true, nil).Once()
+			},
+		},
+		{
+			name:       "unknown revision",
+			userID:     "owner",
+			code:       "abc",
+			revisionID: 100,
+			wantErr:    service.ErrNotFound,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				revisionStorageMock.On("RestoreURL", mock.Anything, "owner", "abc", int64(100)).Return(service.ErrNotFound).Once()
+			},
+		},
+		{
+			name:       "URL is shortened with another code",
+			userID:     "owner",
+			code:       "abc",
+			revisionID: 2,
+			wantErr:    service.ErrURLAlreadyExists,
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				revisionStorageMock.On("RestoreURL", mock.Anything, "owner", "abc", int64(2)).Return(service.ErrURLAlreadyExists).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			err := s.RestoreURL(context.Background(), tt.userID, tt.code, tt.revisionID)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_ClickStats(t *testing.T) {
+	storageMock := mocks.NewURLStorage(t)
+	clickStorageMock := mocks.NewClickStorage(t)
+
+	s := service.New(service.Config{}, service.Dependencies{
+		Storage:      storageMock,
+		ClickStorage: clickStorageMock,
+	})
+
+	from := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     service.ClickStatsQuery
+		want      *service.ClickStats
+		wantErr   error
+		callMocks func()
+	}{
+		{
+			name:  "empty buckets are filled",
+			query: service.ClickStatsQuery{Code: "abc", From: from, To: to, Interval: service.IntervalHour},
+			want: &service.ClickStats{
+				Code:   "abc",
+				Clicks: 2,
+				Buckets: []service.ClickBucket{
+					{Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Clicks: 0},
+					{Time: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), Clicks: 2},
+					{Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Clicks: 0},
+				},
+			},
+			callMocks: func() {
+				storageMock.On("UsersURLCodes", mock.Anything, "owner").Return([]string{"abc"}, nil).Once()
+				clickStorageMock.On("ClickStats", mock.Anything, service.ClickStatsQuery{
+					Code: "abc", From: from, To: to, Interval: service.IntervalHour, Top: service.DefaultStatsTop,
+				}).Return(&service.ClickStats{
+					Code:    "abc",
+					Clicks:  2,
+					Buckets: []service.ClickBucket{{Time: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), Clicks: 2}},
+				}, nil).Once()
+			},
+		},
+		{
+			name:      "unknown interval",
+			query:     service.ClickStatsQuery{Code: "abc", Interval: "week"},
+			wantErr:   service.ErrInvalidStatsQuery,
+			callMocks: func() {},
+		},
+		{
+			name:      "from after to",
+			query:     service.ClickStatsQuery{Code: "abc", From: to, To: from},
+			wantErr:   service.ErrInvalidStatsQuery,
+			callMocks: func() {},
+		},
+		{
+			name:      "too many buckets",
+			query:     service.ClickStatsQuery{Code: "abc", From: from.AddDate(-1, 0, 0), To: to, Interval: service.IntervalHour},
+			wantErr:   service.ErrInvalidStatsQuery,
+			callMocks: func() {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			got, err := s.ClickStats(context.Background(), "owner", tt.query)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAggregateClicks(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	clicks := []service.Click{
+		{Code: "abc", Time: day.Add(time.Hour), IP: "1.1.1.1", UserAgent: "ua1", ReferrerDomain: "google.com", Browser: "Chrome", OS: "Windows"},
+		{Code: "abc", Time: day.Add(2 * time.Hour), IP: "1.1.1.1", UserAgent: "ua1", ReferrerDomain: "google.com", Browser: "Chrome", OS: "Windows"},
+		{Code: "abc", Time: day.Add(26 * time.Hour), IP: "2.2.2.2", UserAgent: "ua2", ReferrerDomain: "t.co", Browser: "Firefox", OS: "Linux"},
+		{Code: "abc", Time: day.Add(-time.Hour), IP: "3.3.3.3"},
+		{Code: "xyz", Time: day.Add(time.Hour), IP: "4.4.4.4"},
+	}
+
+	got := service.AggregateClicks(clicks, service.ClickStatsQuery{
+		Code:     "abc",
+		From:     day,
+		To:       day.AddDate(0, 0, 2),
+		Interval: service.IntervalDay,
+		Top:      1,
+	})
+
+	want := &service.ClickStats{
+		Code:           "abc",
+		Clicks:         3,
+		UniqueVisitors: 2,
+		Buckets: []service.ClickBucket{
+			{Time: day, Clicks: 2},
+			{Time: day.AddDate(0, 0, 1), Clicks: 1},
+		},
+		Referrers: []service.ClickGroup{{Name: "google.com", Clicks: 2}},
+		Browsers:  []service.ClickGroup{{Name: "Chrome", Clicks: 2}},
+		OS:        []service.ClickGroup{{Name: "Windows", Clicks: 2}},
+	}
+
+	assert.Equal(t, want, got)
+}
+
 func TestService_URL(t *testing.T) {
 
 	id := "abcdef"
@@ -99,7 +611,7 @@ func BenchmarkService_MakeShortURL(b *testing.B) {
 	cfg := service.Config{IDSize: 6}
 	URLStorageMock := mocks.NewURLStorage(b)
 	URLStorageMock.On("Exists", mock.Anything, mock.Anything).Return(false, nil)
-	URLStorageMock.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	URLStorageMock.On("Save", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	URLStorageMock.On("SaveUsersCode", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	deps := service.Dependencies{
@@ -109,7 +621,7 @@ func BenchmarkService_MakeShortURL(b *testing.B) {
 
 	s := service.New(cfg, deps)
 	for i := 0; i < b.N; i++ {
-		s.MakeShortURL(context.Background(), "", "")
+		s.MakeShortURL(context.Background(), "", "", service.ShortenOptions{})
 	}
 }
 
@@ -127,3 +639,367 @@ func BenchmarkService_URL(b *testing.B) {
 		s.URL(context.Background(), "")
 	}
 }
+
+func TestService_MakeBatchShortURL(t *testing.T) {
+	codes := []string{"taken1", "code01", "code02", "code03"}
+	deps := service.Dependencies{
+		Storage: inmemstorage.MustNew(map[string]string{"taken1": "http://taken.ru"}),
+		RandomString: func(size int) string {
+			code := codes[0]
+			codes = codes[1:]
+			return code
+		},
+	}
+
+	s := service.New(service.Config{IDSize: 6}, deps)
+
+	urls := []service.URL{
+		{СorrelationID: "1", OriginalURL: "http://ya.ru"},
+		{СorrelationID: "2", OriginalURL: "http://taken.ru"},
+		{СorrelationID: "3", OriginalURL: "not a url"},
+		{СorrelationID: "4", OriginalURL: "http://google.com", TTL: -time.Minute},
+		{СorrelationID: "5", OriginalURL: "http://ya.ru"},
+	}
+
+	got, err := s.MakeBatchShortURL(context.Background(), "user", urls)
+	require.NoError(t, err)
+	require.Len(t, got, len(urls))
+
+	assert.Equal(t, "1", got[0].СorrelationID)
+	assert.Equal(t, service.BatchStatusCreated, got[0].Status)
+	assert.NotEqual(t, "taken1", got[0].Code, "taken code must be regenerated")
+
+	assert.Equal(t, service.BatchStatusExists, got[1].Status)
+	assert.Equal(t, "taken1", got[1].Code)
+
+	assert.Equal(t, service.BatchStatusInvalid, got[2].Status)
+	assert.Empty(t, got[2].Code)
+	assert.NotEmpty(t, got[2].Reason)
+
+	assert.Equal(t, service.BatchStatusInvalid, got[3].Status)
+	assert.NotEmpty(t, got[3].Reason)
+
+	assert.Equal(t, service.BatchStatusExists, got[4].Status)
+	assert.Equal(t, got[0].Code, got[4].Code, "repeated URL gets the code of its first occurrence")
+
+	url, err := s.URL(context.Background(), got[0].Code)
+	require.NoError(t, err)
+	assert.Equal(t, "http://ya.ru", url)
+}
+
+// sliceImportReader returns records and errors in order, then io.EOF
+type sliceImportReader struct {
+	records []service.URL
+	errs    []error
+}
+
+func (r *sliceImportReader) Read() (service.URL, error) {
+	if len(r.records) == 0 {
+		return service.URL{}, io.EOF
+	}
+
+	u, err := r.records[0], r.errs[0]
+	r.records, r.errs = r.records[1:], r.errs[1:]
+
+	return u, err
+}
+
+func TestService_ImportURLs(t *testing.T) {
+	newReader := func() *sliceImportReader {
+		return &sliceImportReader{
+			records: []service.URL{
+				{СorrelationID: "1", OriginalURL: "http://a.ru"},
+				{},
+				{СorrelationID: "3", OriginalURL: "http://b.ru"},
+				{СorrelationID: "4", OriginalURL: "bad"},
+				{СorrelationID: "5", OriginalURL: "http://a.ru"},
+			},
+			errs: []error{nil, fmt.Errorf("%w: line 2", service.ErrInvalidImportRecord), nil, nil, nil},
+		}
+	}
+
+	t.Run("import in chunks", func(t *testing.T) {
+		s := service.New(service.Config{IDSize: 6, ImportChunkSize: 2}, service.Dependencies{
+			Storage:      inmemstorage.MustNew(map[string]string{}),
+			RandomString: random.NewString,
+		})
+
+		reported := make([]service.ImportProgress, 0)
+		got, err := s.ImportURLs(context.Background(), "user", newReader(), 0, func(p service.ImportProgress) error {
+			reported = append(reported, p)
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, service.ImportProgress{Checkpoint: 5, Created: 2, Exists: 1, Invalid: 2}, got)
+
+		require.Len(t, reported, 3)
+		assert.Equal(t, int64(2), reported[0].Checkpoint, "invalid records fill the chunk")
+		require.Len(t, reported[0].Rejected, 1)
+		assert.Equal(t, int64(1), reported[0].Rejected[0].Record)
+		assert.Equal(t, int64(4), reported[1].Checkpoint)
+		require.Len(t, reported[1].Rejected, 1)
+		assert.Equal(t, int64(3), reported[1].Rejected[0].Record)
+		assert.Equal(t, "4", reported[1].Rejected[0].СorrelationID)
+		assert.Equal(t, int64(5), reported[2].Checkpoint)
+		assert.Empty(t, reported[2].Rejected)
+	})
+
+	t.Run("resume from checkpoint", func(t *testing.T) {
+		storage := inmemstorage.MustNew(map[string]string{})
+		s := service.New(service.Config{IDSize: 6, ImportChunkSize: 2}, service.Dependencies{
+			Storage:      storage,
+			RandomString: random.NewString,
+		})
+
+		got, err := s.ImportURLs(context.Background(), "user", newReader(), 3, func(p service.ImportProgress) error {
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, service.ImportProgress{Checkpoint: 5, Created: 1, Invalid: 1}, got)
+
+		_, err = storage.CodeByURL(context.Background(), "http://b.ru")
+		assert.ErrorIs(t, err, service.ErrNotFound, "skipped records must not be saved")
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		s := service.New(service.Config{IDSize: 6, ImportChunkSize: 2}, service.Dependencies{
+			Storage:      inmemstorage.MustNew(map[string]string{}),
+			RandomString: random.NewString,
+		})
+
+		r := newReader()
+		r.errs[2] = errors.New("connection reset")
+
+		got, err := s.ImportURLs(context.Background(), "user", r, 0, func(p service.ImportProgress) error {
+			return nil
+		})
+		require.Error(t, err)
+		assert.Equal(t, int64(2), got.Checkpoint, "the import is resumed after the last saved chunk")
+	})
+}
+
+func TestService_ExportUsersURLs(t *testing.T) {
+	storage := inmemstorage.MustNew(map[string]string{})
+	s := service.New(service.Config{IDSize: 6}, service.Dependencies{Storage: storage, RandomString: random.NewString})
+
+	ctx := context.Background()
+	_, err := s.MakeBatchShortURL(ctx, "user", []service.URL{
+		{СorrelationID: "1", OriginalURL: "http://a.ru"},
+		{СorrelationID: "2", OriginalURL: "http://b.ru"},
+	})
+	require.NoError(t, err)
+
+	urls := make([]string, 0)
+	err = s.ExportUsersURLs(ctx, "user", func(u service.UsersURL) error {
+		urls = append(urls, u.OriginalURL)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://a.ru", "http://b.ru"}, urls)
+
+	errStop := errors.New("stop")
+	err = s.ExportUsersURLs(ctx, "user", func(u service.UsersURL) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestService_UsersURLs(t *testing.T) {
+	storage := mocks.NewURLStorage(t)
+	s := service.New(service.Config{}, service.Dependencies{Storage: storage})
+
+	storage.On("UsersURLs", mock.Anything, "user", service.UsersURLsQuery{Sort: service.UsersURLsSortCreated, Limit: service.DefaultUsersURLsLimit}).
+		Return(&service.UsersURLsPage{}, nil).Once()
+
+	_, err := s.UsersURLs(context.Background(), "user", service.UsersURLsQuery{})
+	require.NoError(t, err)
+
+	for _, q := range []service.UsersURLsQuery{
+		{Sort: "name"},
+		{Limit: -1},
+		{Limit: service.MaxUsersURLsLimit + 1},
+	} {
+		_, err := s.UsersURLs(context.Background(), "user", q)
+		assert.ErrorIs(t, err, service.ErrInvalidUsersURLsQuery)
+	}
+}
+
+func TestService_Accounts(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemstorage.MustNew(map[string]string{})
+	s := service.New(service.Config{IDSize: 8}, service.Dependencies{
+		Storage:        storage,
+		AccountStorage: storage,
+		RandomString:   random.NewString,
+	})
+
+	_, err := s.SignUp(ctx, "al", "password")
+	require.ErrorIs(t, err, service.ErrInvalidLogin)
+
+	_, err = s.SignUp(ctx, "alice", "short")
+	require.ErrorIs(t, err, service.ErrInvalidPassword)
+
+	account, err := s.SignUp(ctx, "alice", "password")
+	require.NoError(t, err)
+	assert.NotEqual(t, "password", account.PasswordHash)
+
+	_, err = s.SignUp(ctx, "alice", "another password")
+	require.ErrorIs(t, err, service.ErrLoginTaken)
+
+	logged, err := s.Login(ctx, "alice", "password")
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, logged.ID)
+
+	_, err = s.Login(ctx, "alice", "wrong password")
+	require.ErrorIs(t, err, service.ErrInvalidCredentials)
+
+	_, err = s.Login(ctx, "bob", "password")
+	require.ErrorIs(t, err, service.ErrInvalidCredentials)
+
+	// links of the anonymous user are moved to the account
+	code, err := s.MakeShortURL(ctx, "anonymous", "https://ya.ru", service.ShortenOptions{})
+	require.NoError(t, err)
+
+	cnt, err := s.ClaimURLs(ctx, "anonymous", account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	codes, err := storage.UsersURLCodes(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{code}, codes)
+
+	other, err := s.SignUp(ctx, "bob", "password")
+	require.NoError(t, err)
+
+	_, err = s.ClaimURLs(ctx, other.ID, account.ID)
+	require.ErrorIs(t, err, service.ErrForbidden, "another account can't be claimed")
+}
+
+func TestService_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemstorage.MustNew(map[string]string{})
+	s := service.New(service.Config{}, service.Dependencies{Storage: storage, AccountStorage: storage})
+
+	account, err := s.SignUp(ctx, "alice", "password")
+	require.NoError(t, err)
+
+	_, _, err = s.CreateAPIKey(ctx, "anonymous", "ci", []string{service.ScopeRead})
+	require.ErrorIs(t, err, service.ErrForbidden)
+
+	_, _, err = s.CreateAPIKey(ctx, account.ID, "ci", nil)
+	require.ErrorIs(t, err, service.ErrInvalidScope)
+
+	_, _, err = s.CreateAPIKey(ctx, account.ID, "ci", []string{service.ScopeAccount})
+	require.ErrorIs(t, err, service.ErrInvalidScope)
+
+	key, k, err := s.CreateAPIKey(ctx, account.ID, "ci", []string{service.ScopeRead, service.ScopeStats, service.ScopeRead})
+	require.NoError(t, err)
+	assert.Equal(t, []string{service.ScopeRead, service.ScopeStats}, k.Scopes)
+	assert.NotContains(t, k.Hash, key)
+
+	authenticated, err := s.AuthenticateAPIKey(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, authenticated.UserID)
+	assert.Equal(t, k.ID, authenticated.ID)
+
+	keys, err := s.APIKeys(ctx, account.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	require.ErrorIs(t, s.RevokeAPIKey(ctx, "anonymous", k.ID), service.ErrNotFound)
+	require.NoError(t, s.RevokeAPIKey(ctx, account.ID, k.ID))
+
+	_, err = s.AuthenticateAPIKey(ctx, key)
+	require.ErrorIs(t, err, service.ErrInvalidAPIKey)
+
+	s = service.New(service.Config{}, service.Dependencies{Storage: storage})
+	_, err = s.AuthenticateAPIKey(ctx, key)
+	require.ErrorIs(t, err, service.ErrAccountsNotSupported)
+}
+
+func TestService_Moderation(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemstorage.MustNew(map[string]string{})
+	s := service.New(service.Config{IDSize: 8}, service.Dependencies{
+		Storage:           storage,
+		ModerationStorage: storage,
+		Policy:            policy.New([]string{"admin"}),
+		RandomString:      random.NewString,
+	})
+
+	code, err := s.MakeShortURL(ctx, "user", "https://ya.ru", service.ShortenOptions{})
+	require.NoError(t, err)
+
+	// only admins pass the policy
+	_, err = s.URLInfo(ctx, "user", code)
+	require.ErrorIs(t, err, service.ErrForbidden)
+	require.ErrorIs(t, s.ForceDeleteURL(ctx, "user", code), service.ErrForbidden)
+	require.ErrorIs(t, s.BlockURL(ctx, "user", code, true), service.ErrForbidden)
+	require.ErrorIs(t, s.BanUser(ctx, "user", "another", true), service.ErrForbidden)
+	_, err = s.UserURLs(ctx, "user", "another", service.UsersURLsQuery{})
+	require.ErrorIs(t, err, service.ErrForbidden)
+
+	info, err := s.URLInfo(ctx, "admin", code)
+	require.NoError(t, err)
+	assert.Equal(t, "user", info.OwnerID)
+	assert.Equal(t, "https://ya.ru", info.OriginalURL)
+
+	_, err = s.URLInfo(ctx, "admin", "unknown")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	page, err := s.UserURLs(ctx, "admin", "user", service.UsersURLsQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, code, page.URLs[0].Code)
+
+	require.NoError(t, s.BlockURL(ctx, "admin", code, true))
+	_, err = s.URL(ctx, code)
+	require.ErrorIs(t, err, service.ErrBlocked)
+
+	require.NoError(t, s.BlockURL(ctx, "admin", code, false))
+	_, err = s.URL(ctx, code)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, s.ForceDeleteURL(ctx, "admin", "unknown"), service.ErrNotFound)
+	require.NoError(t, s.ForceDeleteURL(ctx, "admin", code))
+	_, err = s.URL(ctx, code)
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	require.NoError(t, s.CheckBanned(ctx, "user"))
+	require.NoError(t, s.BanUser(ctx, "admin", "user", true))
+	require.ErrorIs(t, s.CheckBanned(ctx, "user"), service.ErrUserBanned)
+	require.NoError(t, s.BanUser(ctx, "admin", "user", false))
+	require.NoError(t, s.CheckBanned(ctx, "user"))
+
+	require.ErrorIs(t, s.BanUser(ctx, "admin", "admin", true), service.ErrForbidden, "admins can't be banned")
+}
+
+func TestService_ModerationNotSupported(t *testing.T) {
+	ctx := context.Background()
+	storageMock := mocks.NewURLStorage(t)
+	s := service.New(service.Config{}, service.Dependencies{Storage: storageMock, Policy: policy.New([]string{"admin"})})
+
+	_, err := s.URLInfo(ctx, "admin", "abc")
+	require.ErrorIs(t, err, service.ErrModerationNotSupported)
+	require.ErrorIs(t, s.BlockURL(ctx, "admin", "abc", true), service.ErrModerationNotSupported)
+	require.ErrorIs(t, s.BanUser(ctx, "admin", "user", true), service.ErrModerationNotSupported)
+	require.NoError(t, s.CheckBanned(ctx, "user"), "nobody is banned without the storage")
+}
+
+func TestService_BlockURLInvalidatesCache(t *testing.T) {
+	moderationStorageMock := mocks.NewModerationStorage(t)
+	cacheMock := mocks.NewURLCache(t)
+	s := service.New(service.Config{}, service.Dependencies{
+		ModerationStorage: moderationStorageMock,
+		Cache:             cacheMock,
+		Policy:            policy.New([]string{"admin"}),
+	})
+
+	moderationStorageMock.On("SetURLBlocked", mock.Anything, "admin", "abc", true).Return(nil).Once()
+	cacheMock.On("Invalidate", "abc").Once()
+	require.NoError(t, s.BlockURL(context.Background(), "admin", "abc", true))
+
+	moderationStorageMock.On("SetURLBlocked", mock.Anything, "admin", "xyz", true).Return(service.ErrNotFound).Once()
+	require.ErrorIs(t, s.BlockURL(context.Background(), "admin", "xyz", true), service.ErrNotFound)
+}
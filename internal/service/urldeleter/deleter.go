@@ -42,20 +42,26 @@ func NewDeleter(cfg Config, d Deps) *URLDeleter {
 	return &URLDeleter{
 		cfg:     cfg,
 		storage: d.Storage,
-		queue:   make(chan string, cfg.MaxBatchSize),
+		queue:   make(chan deleteTask, cfg.MaxBatchSize),
 	}
 }
 
+// deleteTask is a code to delete and the user who requested it
+type deleteTask struct {
+	userID string
+	code   string
+}
+
 // URLDeleter service struct
 type URLDeleter struct {
 	cfg     Config
 	storage service.URLStorage
-	queue   chan string
+	queue   chan deleteTask
 }
 
 // Start starts the worker
 func (d *URLDeleter) Start() {
-	listToDelete := make([]string, 0, d.cfg.MaxBatchSize)
+	listToDelete := make([]deleteTask, 0, d.cfg.MaxBatchSize)
 	send, sendAndExit := false, false
 
 	ticker := time.NewTicker(d.cfg.BatchWaitingTime)
@@ -85,13 +91,9 @@ LOOP:
 		if send || sendAndExit {
 			send = false
 
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			if err := d.storage.DeleteURLs(ctx, listToDelete); err != nil {
-				logrus.Errorf("filed to delete urls: %s", err)
-			}
-			cancel()
+			d.deleteURLs(listToDelete)
 
-			listToDelete = make([]string, 0, d.cfg.MaxBatchSize)
+			listToDelete = make([]deleteTask, 0, d.cfg.MaxBatchSize)
 		}
 
 		if sendAndExit {
@@ -100,6 +102,26 @@ LOOP:
 	}
 }
 
+// deleteURLs deletes codes of the batch by one call for every user, so the storage knows the author of the deletion
+func (d *URLDeleter) deleteURLs(tasks []deleteTask) {
+	users := make([]string, 0)
+	usersCodes := make(map[string][]string)
+	for _, t := range tasks {
+		if _, ok := usersCodes[t.userID]; !ok {
+			users = append(users, t.userID)
+		}
+		usersCodes[t.userID] = append(usersCodes[t.userID], t.code)
+	}
+
+	for _, userID := range users {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := d.storage.DeleteURLs(ctx, userID, usersCodes[userID]); err != nil {
+			logrus.Errorf("filed to delete urls: %s", err)
+		}
+		cancel()
+	}
+}
+
 // Stop stops the service
 func (d *URLDeleter) Stop() {
 	time.Sleep(d.cfg.StoppingTimeout)
@@ -115,7 +137,7 @@ func (d *URLDeleter) Delete(ctx context.Context, userID string, codes []string)
 
 	for _, code := range codes {
 		if isBelong(belongCodes, code) {
-			d.queue <- code
+			d.queue <- deleteTask{userID: userID, code: code}
 		}
 	}
 
@@ -0,0 +1,84 @@
+// Package of service for removing expired URLs
+package urlsweeper
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// Config service config
+type Config struct {
+	Interval       time.Duration
+	RequestTimeout time.Duration
+}
+
+// Deps contains necessary service dependencies
+type Deps struct {
+	Storage service.URLStorage
+}
+
+// NewSweeper service constructor
+// use only the constructor to declare URLSweeper otherwise service will not work correctly
+func NewSweeper(cfg Config, d Deps) *URLSweeper {
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = time.Second * 5
+	}
+
+	return &URLSweeper{
+		cfg:     cfg,
+		storage: d.Storage,
+		stop:    make(chan struct{}),
+	}
+}
+
+// URLSweeper service struct
+// periodically marks expired URLs as deleted
+type URLSweeper struct {
+	cfg     Config
+	storage service.URLStorage
+	stop    chan struct{}
+}
+
+// Start starts the worker
+func (s *URLSweeper) Start() {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+// Stop stops the service
+func (s *URLSweeper) Stop() {
+	close(s.stop)
+}
+
+func (s *URLSweeper) sweep() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.RequestTimeout)
+	defer cancel()
+
+	cnt, err := s.storage.DeleteExpiredURLs(ctx)
+	if err != nil {
+		logrus.Errorf("failed to delete expired urls: %s", err)
+		return
+	}
+
+	if cnt > 0 {
+		logrus.Infof("%d expired urls deleted", cnt)
+	}
+}
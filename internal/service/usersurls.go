@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sort orders of user's URLs
+const (
+	UsersURLsSortCreated = "created"
+	UsersURLsSortClicks  = "clicks"
+)
+
+// Limits of a page of user's URLs
+const (
+	DefaultUsersURLsLimit = 100
+	MaxUsersURLsLimit     = 1000
+)
+
+// UsersURLsQuery describes a page of user's URLs
+type UsersURLsQuery struct {
+	// Search keeps only URLs containing the substring, case insensitive
+	Search string
+	// Sort is UsersURLsSortCreated or UsersURLsSortClicks, URLs with equal clicks are ordered by code
+	Sort string
+	Desc bool
+	// Limit is a size of the page, all URLs are returned if it isn't positive
+	Limit int
+	// After is URLsCursor.Next of the previous page, nil for the first page
+	After *URLsCursor
+}
+
+// UsersURLsPage is a page of user's URLs
+// Next is nil on the last page
+type UsersURLsPage struct {
+	URLs []UsersURL
+	Next *URLsCursor
+}
+
+// URLsCursor is a position of the last URL of a page
+// Key is a value of the sort key of the URL, its meaning is up to the storage
+type URLsCursor struct {
+	Key  int64  `json:"k"`
+	Code string `json:"c"`
+}
+
+// String encodes the cursor to an opaque string for clients
+func (c *URLsCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseURLsCursor decodes the cursor made by URLsCursor.String
+// returns ErrInvalidUsersURLsQuery if the cursor is malformed
+func ParseURLsCursor(s string) (*URLsCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidUsersURLsQuery)
+	}
+
+	c := URLsCursor{}
+	if err := json.Unmarshal(b, &c); err != nil || c.Code == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidUsersURLsQuery)
+	}
+
+	return &c, nil
+}
+
+// UsersURLs returns a page of URLs added by user
+func (s *Service) UsersURLs(ctx context.Context, userID string, q UsersURLsQuery) (*UsersURLsPage, error) {
+	switch q.Sort {
+	case "":
+		q.Sort = UsersURLsSortCreated
+	case UsersURLsSortCreated, UsersURLsSortClicks:
+	default:
+		return nil, fmt.Errorf("%w: sort must be %s or %s", ErrInvalidUsersURLsQuery, UsersURLsSortCreated, UsersURLsSortClicks)
+	}
+
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultUsersURLsLimit
+	case q.Limit < 0 || q.Limit > MaxUsersURLsLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidUsersURLsQuery, MaxUsersURLsLimit)
+	}
+
+	page, err := s.storage.UsersURLs(ctx, userID, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users urls from storage: %w", err)
+	}
+
+	return page, nil
+}
+
+// PageUsersURLs filters, sorts and pages URLs in memory, it's used by storages which keep user's URLs in memory
+// urls must be in order of saving, the position in urls is the key of UsersURLsSortCreated
+func PageUsersURLs(urls []UsersURL, q UsersURLsQuery) *UsersURLsPage {
+	type entry struct {
+		key int64
+		url UsersURL
+	}
+
+	search := strings.ToLower(q.Search)
+	entries := make([]entry, 0, len(urls))
+	for i, u := range urls {
+		if search != "" && !strings.Contains(strings.ToLower(u.OriginalURL), search) {
+			continue
+		}
+
+		key := int64(i)
+		if q.Sort == UsersURLsSortClicks {
+			key = int64(u.Clicks)
+		}
+
+		entries = append(entries, entry{key: key, url: u})
+	}
+
+	// less reports if a goes before b in the order of the query
+	less := func(aKey int64, aCode string, bKey int64, bCode string) bool {
+		if q.Desc {
+			aKey, aCode, bKey, bCode = bKey, bCode, aKey, aCode
+		}
+		if aKey != bKey {
+			return aKey < bKey
+		}
+		return aCode < bCode
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i].key, entries[i].url.Code, entries[j].key, entries[j].url.Code)
+	})
+
+	start := 0
+	if q.After != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return less(q.After.Key, q.After.Code, entries[i].key, entries[i].url.Code)
+		})
+	}
+
+	page := &UsersURLsPage{URLs: make([]UsersURL, 0)}
+	for i := start; i < len(entries); i++ {
+		if q.Limit > 0 && len(page.URLs) == q.Limit {
+			last := entries[i-1]
+			page.Next = &URLsCursor{Key: last.key, Code: last.url.Code}
+			break
+		}
+
+		page.URLs = append(page.URLs, entries[i].url)
+	}
+
+	return page
+}
@@ -0,0 +1,282 @@
+// Package cachestorage implements a read-through LRU cache over any service.URLStorage
+package cachestorage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// Default settings of the cache
+const (
+	DefaultSize = 10000
+	DefaultTTL  = time.Minute
+)
+
+// Config contains settings of the cache
+// TTL limits how long a cached entry may stay stale, e.g. when a link is deleted by another instance
+type Config struct {
+	Size int
+	TTL  time.Duration
+}
+
+// ExpiryStorage is implemented by storages which return the expiration of URL with the URL
+// URLs of such storages aren't served from the cache after they expire, others are cached for the whole TTL
+type ExpiryStorage interface {
+	URLWithExpiry(ctx context.Context, code string) (string, time.Time, error)
+}
+
+// New returns storage which caches URL and Exists lookups of the wrapped storage
+func New(cfg Config, storage service.URLStorage) *Storage {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultSize
+	}
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+
+	return &Storage{
+		URLStorage: storage,
+		cfg:        cfg,
+		entries:    make(map[string]*list.Element, cfg.Size),
+		lru:        list.New(),
+	}
+}
+
+// Storage is the caching decorator, methods which aren't cached are passed to the wrapped storage
+type Storage struct {
+	service.URLStorage
+	cfg Config
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// gen is incremented on every invalidation, lookups started before it don't fill the cache
+	gen uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// entry keeps the results of URL and Exists lookups of a code
+type entry struct {
+	code string
+
+	urlKnown bool
+	url      string
+	err      error
+	urlUntil time.Time
+
+	existsKnown bool
+	exists      bool
+	existsUntil time.Time
+}
+
+// URL returns URL by code from the cache or from the wrapped storage
+// misses of the storage are cached as well
+func (s *Storage) URL(ctx context.Context, code string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	if e := s.get(code); e != nil && e.urlKnown && time.Now().Before(e.urlUntil) {
+		s.mu.Unlock()
+		s.hits.Add(1)
+		return e.url, e.err
+	}
+	gen := s.gen
+	s.mu.Unlock()
+
+	s.misses.Add(1)
+
+	url, expiresAt, err := s.urlWithExpiry(ctx, code)
+	if err != nil && !cacheable(err) {
+		return "", err
+	}
+
+	until := time.Now().Add(s.cfg.TTL)
+	if !expiresAt.IsZero() && expiresAt.Before(until) {
+		until = expiresAt
+	}
+
+	s.mu.Lock()
+	if gen == s.gen {
+		e := s.getOrAdd(code)
+		e.urlKnown, e.url, e.err, e.urlUntil = true, url, err, until
+	}
+	s.mu.Unlock()
+
+	return url, err
+}
+
+// urlWithExpiry returns URL by code from the wrapped storage with the expiration if the storage knows it
+func (s *Storage) urlWithExpiry(ctx context.Context, code string) (string, time.Time, error) {
+	if es, ok := s.URLStorage.(ExpiryStorage); ok {
+		return es.URLWithExpiry(ctx, code)
+	}
+
+	url, err := s.URLStorage.URL(ctx, code)
+	return url, time.Time{}, err
+}
+
+// Exists checks if the code exists using the cache or the wrapped storage
+func (s *Storage) Exists(ctx context.Context, code string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if e := s.get(code); e != nil {
+		now := time.Now()
+		if e.existsKnown && now.Before(e.existsUntil) {
+			s.mu.Unlock()
+			s.hits.Add(1)
+			return e.exists, nil
+		}
+
+		if e.urlKnown && now.Before(e.urlUntil) {
+			s.mu.Unlock()
+			s.hits.Add(1)
+			return !errors.Is(e.err, service.ErrNotFound), nil
+		}
+	}
+	gen := s.gen
+	s.mu.Unlock()
+
+	s.misses.Add(1)
+
+	exists, err := s.URLStorage.Exists(ctx, code)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if gen == s.gen {
+		e := s.getOrAdd(code)
+		e.existsKnown, e.exists, e.existsUntil = true, exists, time.Now().Add(s.cfg.TTL)
+	}
+	s.mu.Unlock()
+
+	return exists, nil
+}
+
+// Save stores URL in the wrapped storage and drops the cached miss of the code
+func (s *Storage) Save(ctx context.Context, code, url string, expiresAt time.Time) error {
+	defer s.Invalidate(code)
+
+	return s.URLStorage.Save(ctx, code, url, expiresAt)
+}
+
+// SaveBatch stores URLs in the wrapped storage and drops the cached misses of the codes
+func (s *Storage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	codes := make([]string, 0, len(urls))
+	for _, u := range urls {
+		codes = append(codes, u.Code)
+	}
+	defer s.Invalidate(codes...)
+
+	return s.URLStorage.SaveBatch(ctx, userID, urls)
+}
+
+// DeleteURLs marks URLs as deleted in the wrapped storage and drops the cached codes
+func (s *Storage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	defer s.Invalidate(codes...)
+
+	return s.URLStorage.DeleteURLs(ctx, userID, codes)
+}
+
+// UpdateURL replaces URL of the code in the wrapped storage and drops the cached code
+func (s *Storage) UpdateURL(ctx context.Context, userID, code, url string) error {
+	defer s.Invalidate(code)
+
+	return s.URLStorage.UpdateURL(ctx, userID, code, url)
+}
+
+// DeleteExpiredURLs deletes expired URLs in the wrapped storage
+// the deleted codes are unknown, so the whole cache is dropped if any URL is deleted
+func (s *Storage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	cnt, err := s.URLStorage.DeleteExpiredURLs(ctx)
+	if cnt > 0 {
+		s.purge()
+	}
+
+	return cnt, err
+}
+
+// CacheStats returns counters of the cache
+func (s *Storage) CacheStats() service.CacheStats {
+	s.mu.Lock()
+	size := s.lru.Len()
+	s.mu.Unlock()
+
+	return service.CacheStats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+		Size:   size,
+	}
+}
+
+// get returns the entry of the code and marks it as recently used
+func (s *Storage) get(code string) *entry {
+	el, ok := s.entries[code]
+	if !ok {
+		return nil
+	}
+
+	s.lru.MoveToFront(el)
+	return el.Value.(*entry)
+}
+
+// getOrAdd returns the entry of the code, a new entry evicts the least recently used one if the cache is full
+func (s *Storage) getOrAdd(code string) *entry {
+	if e := s.get(code); e != nil {
+		return e
+	}
+
+	if s.lru.Len() >= s.cfg.Size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*entry).code)
+	}
+
+	e := &entry{code: code}
+	s.entries[code] = s.lru.PushFront(e)
+
+	return e
+}
+
+// Invalidate drops the cached codes
+func (s *Storage) Invalidate(codes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for _, code := range codes {
+		if el, ok := s.entries[code]; ok {
+			s.lru.Remove(el)
+			delete(s.entries, code)
+		}
+	}
+}
+
+func (s *Storage) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	s.entries = make(map[string]*list.Element, s.cfg.Size)
+	s.lru.Init()
+}
+
+// cacheable reports if the error is a result of the lookup rather than a failure of the storage
+func cacheable(err error) bool {
+	return errors.Is(err, service.ErrNotFound) || errors.Is(err, service.ErrDeleted) || errors.Is(err, service.ErrExpired) ||
+		errors.Is(err, service.ErrBlocked)
+}
@@ -0,0 +1,139 @@
+package cachestorage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/service/mocks"
+	"github.com/lks-go/url-shortener/internal/transport/cachestorage"
+	"github.com/lks-go/url-shortener/internal/transport/inmemstorage"
+	"github.com/lks-go/url-shortener/internal/transport/storagetest"
+)
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.RunURLStorage(t, func(t *testing.T) service.URLStorage {
+		return cachestorage.New(cachestorage.Config{}, inmemstorage.MustNew(make(map[string]string)))
+	})
+}
+
+func TestStorage_URL(t *testing.T) {
+	ctx := context.Background()
+	storageMock := mocks.NewURLStorage(t)
+	s := cachestorage.New(cachestorage.Config{}, storageMock)
+
+	storageMock.On("URL", mock.Anything, "abc").Return("https://ya.ru", nil).Once()
+	storageMock.On("URL", mock.Anything, "xyz").Return("", service.ErrNotFound).Once()
+
+	for i := 0; i < 3; i++ {
+		url, err := s.URL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://ya.ru", url)
+
+		_, err = s.URL(ctx, "xyz")
+		require.ErrorIs(t, err, service.ErrNotFound)
+	}
+
+	exists, err := s.Exists(ctx, "xyz")
+	require.NoError(t, err)
+	assert.False(t, exists, "exists must be answered by the cached miss")
+
+	assert.Equal(t, service.CacheStats{Hits: 5, Misses: 2, Size: 2}, s.CacheStats())
+}
+
+func TestStorage_StorageError(t *testing.T) {
+	ctx := context.Background()
+	storageMock := mocks.NewURLStorage(t)
+	s := cachestorage.New(cachestorage.Config{}, storageMock)
+
+	storageMock.On("URL", mock.Anything, "abc").Return("", assert.AnError).Twice()
+
+	for i := 0; i < 2; i++ {
+		_, err := s.URL(ctx, "abc")
+		require.ErrorIs(t, err, assert.AnError)
+	}
+
+	assert.Equal(t, 0, s.CacheStats().Size, "failures of the storage must not be cached")
+}
+
+func TestStorage_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	s := cachestorage.New(cachestorage.Config{}, inmemstorage.MustNew(make(map[string]string)))
+
+	_, err := s.URL(ctx, "abc")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	require.NoError(t, s.UpdateURL(ctx, "user1", "abc", "https://ya.com"))
+
+	url, err = s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.com", url)
+
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"abc"}))
+
+	_, err = s.URL(ctx, "abc")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	require.NoError(t, s.Save(ctx, "exp", "https://google.com", time.Now().Add(50*time.Millisecond)))
+
+	url, err = s.URL(ctx, "exp")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+
+	time.Sleep(60 * time.Millisecond)
+
+	cnt, err := s.DeleteExpiredURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	_, err = s.URL(ctx, "exp")
+	require.ErrorIs(t, err, service.ErrDeleted)
+}
+
+func TestStorage_Expiration(t *testing.T) {
+	ctx := context.Background()
+	s := cachestorage.New(cachestorage.Config{TTL: time.Hour}, inmemstorage.MustNew(make(map[string]string)))
+
+	require.NoError(t, s.Save(ctx, "exp", "https://google.com", time.Now().Add(50*time.Millisecond)))
+
+	url, err := s.URL(ctx, "exp")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = s.URL(ctx, "exp")
+	require.ErrorIs(t, err, service.ErrExpired, "expired URL must not be served from the cache before the sweeper deletes it")
+}
+
+func TestStorage_Eviction(t *testing.T) {
+	ctx := context.Background()
+	storageMock := mocks.NewURLStorage(t)
+	s := cachestorage.New(cachestorage.Config{Size: 2, TTL: 50 * time.Millisecond}, storageMock)
+
+	storageMock.On("URL", mock.Anything, "a").Return("https://a.com", nil).Once()
+	storageMock.On("URL", mock.Anything, "b").Return("https://b.com", nil).Times(3)
+	storageMock.On("URL", mock.Anything, "c").Return("https://c.com", nil).Once()
+
+	for _, code := range []string{"a", "b", "a", "c", "b"} {
+		_, err := s.URL(ctx, code)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, s.CacheStats().Size)
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err := s.URL(ctx, "b")
+	require.NoError(t, err)
+}
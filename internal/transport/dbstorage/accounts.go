@@ -0,0 +1,163 @@
+package dbstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// CreateAccount stores a new account
+// returns service.ErrLoginTaken if the login belongs to another account
+func (s *Storage) CreateAccount(ctx context.Context, a service.Account) error {
+	q := `INSERT INTO accounts (id, login, password_hash, created_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := s.pool.Exec(ctx, q, a.ID, a.Login, a.PasswordHash, a.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return service.ErrLoginTaken
+		}
+
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	return nil
+}
+
+// Account returns account by ID
+func (s *Storage) Account(ctx context.Context, id string) (*service.Account, error) {
+	q := `SELECT id, login, password_hash, created_at FROM accounts WHERE id = $1`
+
+	return s.account(ctx, q, id)
+}
+
+// AccountByLogin returns account by login
+func (s *Storage) AccountByLogin(ctx context.Context, login string) (*service.Account, error) {
+	q := `SELECT id, login, password_hash, created_at FROM accounts WHERE login = $1`
+
+	return s.account(ctx, q, login)
+}
+
+func (s *Storage) account(ctx context.Context, q string, arg string) (*service.Account, error) {
+	a := service.Account{}
+	if err := s.pool.QueryRow(ctx, q, arg).Scan(&a.ID, &a.Login, &a.PasswordHash, &a.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	return &a, nil
+}
+
+// SaveAPIKey stores API key
+func (s *Storage) SaveAPIKey(ctx context.Context, k service.APIKey) error {
+	q := `INSERT INTO api_keys (id, user_id, name, scopes, key_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if _, err := s.pool.Exec(ctx, q, k.ID, k.UserID, k.Name, k.Scopes, k.Hash, k.CreatedAt); err != nil {
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	return nil
+}
+
+// APIKeyByHash returns API key by hash of the key
+func (s *Storage) APIKeyByHash(ctx context.Context, hash string) (*service.APIKey, error) {
+	q := `SELECT id, user_id, name, scopes, key_hash, created_at FROM api_keys WHERE key_hash = $1`
+
+	rows, err := s.pool.Query(ctx, q, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make query: %w", err)
+	}
+
+	k, err := pgx.CollectOneRow(rows, scanAPIKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to scan api key: %w", err)
+	}
+
+	return &k, nil
+}
+
+// APIKeys returns API keys of the user in order of creation
+func (s *Storage) APIKeys(ctx context.Context, userID string) ([]service.APIKey, error) {
+	q := `SELECT id, user_id, name, scopes, key_hash, created_at FROM api_keys WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := s.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make query: %w", err)
+	}
+
+	keys, err := pgx.CollectRows(rows, scanAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func scanAPIKey(row pgx.CollectableRow) (service.APIKey, error) {
+	k := service.APIKey{}
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Scopes, &k.Hash, &k.CreatedAt)
+	return k, err
+}
+
+// DeleteAPIKey deletes API key of the user
+func (s *Storage) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+// ClaimURLs moves codes, ownership and revisions of the user to another user in one transaction
+// codes already owned by the target are skipped
+func (s *Storage) ClaimURLs(ctx context.Context, fromUserID, toUserID string) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := `INSERT INTO user_codes (user_id, code, created_at)
+		SELECT $2, code, created_at FROM user_codes WHERE user_id = $1
+		ON CONFLICT (user_id, code) DO NOTHING`
+	if _, err := tx.Exec(ctx, q, fromUserID, toUserID); err != nil {
+		return 0, fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM user_codes WHERE user_id = $1`, fromUserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE shorten SET owner_id = $2 WHERE owner_id = $1`, fromUserID, toUserID); err != nil {
+		return 0, fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE shorten_revisions SET user_id = $2 WHERE user_id = $1`, fromUserID, toUserID); err != nil {
+		return 0, fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,105 @@
+package dbstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// URLInfo returns the code with its owner
+// returns service.ErrNotFound if the code doesn't exist
+func (s *Storage) URLInfo(ctx context.Context, code string) (*service.URLInfo, error) {
+	q := `SELECT code, url, COALESCE(owner_id::text, ''), expires_at, deleted_at IS NOT NULL, blocked_at IS NOT NULL, created_at
+		FROM shorten WHERE code = $1`
+
+	info := service.URLInfo{}
+	var expiresAt *time.Time
+	err := s.pool.QueryRow(ctx, q, code).
+		Scan(&info.Code, &info.OriginalURL, &info.OwnerID, &expiresAt, &info.Deleted, &info.Blocked, &info.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	if expiresAt != nil {
+		info.ExpiresAt = *expiresAt
+	}
+
+	return &info, nil
+}
+
+// SetURLBlocked blocks or unblocks the code and saves the revision in one transaction if the state is changed
+// returns service.ErrNotFound if the code doesn't exist
+func (s *Storage) SetURLBlocked(ctx context.Context, userID, code string, blocked bool) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var url string
+	var wasBlocked bool
+	q := `SELECT url, blocked_at IS NOT NULL FROM shorten WHERE code = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, q, code).Scan(&url, &wasBlocked); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return service.ErrNotFound
+		}
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	if wasBlocked == blocked {
+		return nil
+	}
+
+	q, action := `UPDATE shorten SET blocked_at = NULL WHERE code = $1`, service.RevisionUnblocked
+	if blocked {
+		q, action = `UPDATE shorten SET blocked_at = now() WHERE code = $1`, service.RevisionBlocked
+	}
+
+	if _, err := tx.Exec(ctx, q, code); err != nil {
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if err := saveRevision(ctx, tx, service.Revision{Code: code, Action: action, URL: url, PreviousURL: url, UserID: userID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserBanned bans or unbans the user
+func (s *Storage) SetUserBanned(ctx context.Context, userID string, banned bool) error {
+	q := `DELETE FROM banned_users WHERE user_id = $1`
+	if banned {
+		q = `INSERT INTO banned_users (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`
+	}
+
+	if _, err := s.pool.Exec(ctx, q, userID); err != nil {
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	return nil
+}
+
+// UserBanned checks if the user is banned
+func (s *Storage) UserBanned(ctx context.Context, userID string) (bool, error) {
+	q := `SELECT EXISTS (SELECT 1 FROM banned_users WHERE user_id = $1)`
+
+	banned := false
+	if err := s.pool.QueryRow(ctx, q, userID).Scan(&banned); err != nil {
+		return false, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	return banned, nil
+}
@@ -2,65 +2,161 @@ package dbstorage
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/lks-go/url-shortener/internal/service"
 )
 
+// shortenCodeKey is a name of the unique constraint of the column shorten.code
+const shortenCodeKey = "shorten_code_key"
+
+// userCodesCodeFkey is a name of the foreign key of the column user_codes.code
+const userCodesCodeFkey = "user_codes_code_fkey"
+
 // New is Storage constructor
-func New(db *sql.DB) *Storage {
+func New(pool *pgxpool.Pool) *Storage {
 	return &Storage{
-		db: db,
+		pool: pool,
 	}
 }
 
 // Storage is storage main struct
 type Storage struct {
-	db *sql.DB
+	pool *pgxpool.Pool
 }
 
-// SaveBatch accepts array of service.URL and saves them in one transaction
-func (s *Storage) SaveBatch(ctx context.Context, urls []service.URL) error {
+// SaveBatch saves URLs with a single multi-row insert and makes the user their owner if the user is set
+// everything is saved in one transaction
+// returns *service.BatchError if any of codes or URLs is already saved
+func (s *Storage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	if len(urls) == 0 {
+		return nil
+	}
 
-	tx, err := s.db.Begin()
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer tx.Rollback()
+	defer tx.Rollback(ctx)
 
-	stmt, err := tx.Prepare(`INSERT INTO shorten (code, url) VALUES($1, $2)`)
+	codes := make([]string, 0, len(urls))
+	originalURLs := make([]string, 0, len(urls))
+	expiresAt := make([]*time.Time, 0, len(urls))
+	for _, u := range urls {
+		codes = append(codes, u.Code)
+		originalURLs = append(originalURLs, u.OriginalURL)
+		expiresAt = append(expiresAt, timePtr(u.ExpiresAt))
+	}
+
+	// rows are inserted in order of the batch, so the first of repeated codes or URLs wins
+	q := `WITH inserted AS (
+			INSERT INTO shorten (code, url, expires_at, owner_id)
+			SELECT code, url, expires_at, $4::uuid
+			FROM unnest($1::varchar[], $2::varchar[], $3::timestamptz[]) AS b(code, url, expires_at)
+			ON CONFLICT DO NOTHING
+			RETURNING code, url, owner_id
+		)
+		INSERT INTO shorten_revisions (code, action, url, user_id)
+		SELECT code, $5::varchar, url, owner_id FROM inserted
+		RETURNING code`
+
+	rows, err := tx.Query(ctx, q, codes, originalURLs, expiresAt, stringPtr(userID), service.RevisionCreated)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return fmt.Errorf("failed to make query: %w", err)
 	}
 
-	for _, u := range urls {
-		_, err = stmt.ExecContext(ctx, u.Code, u.OriginalURL)
-		if err != nil {
-			return fmt.Errorf("failed to exec query: %w", err)
+	inserted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to insert urls: %w", err)
+	}
+
+	if len(inserted) < len(urls) {
+		return s.batchConflicts(ctx, tx, urls, inserted)
+	}
+
+	if userID != "" {
+		q := `INSERT INTO user_codes (user_id, code) SELECT $1, unnest($2::varchar[])`
+		if _, err := tx.Exec(ctx, q, userID, codes); err != nil {
+			return fmt.Errorf("failed to save user's codes: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
+// batchConflicts finds out which URLs of the batch aren't inserted and why
+// rows inserted by the transaction itself aren't conflicts
+func (s *Storage) batchConflicts(ctx context.Context, tx pgx.Tx, urls []service.URL, inserted []string) error {
+	own := make(map[string]struct{}, len(inserted))
+	for _, code := range inserted {
+		own[code] = struct{}{}
+	}
+
+	codes := make([]string, 0, len(urls))
+	originalURLs := make([]string, 0, len(urls))
+	for _, u := range urls {
+		codes = append(codes, u.Code)
+		originalURLs = append(originalURLs, u.OriginalURL)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT code, url FROM shorten WHERE code = ANY($1) OR url = ANY($2)`, codes, originalURLs)
+	if err != nil {
+		return fmt.Errorf("failed to make query: %w", err)
+	}
+	defer rows.Close()
+
+	takenCodes := make(map[string]struct{})
+	takenURLs := make(map[string]struct{})
+	for rows.Next() {
+		var code, url string
+		if err := rows.Scan(&code, &url); err != nil {
+			return fmt.Errorf("failed to scan code and url: %w", err)
+		}
+
+		if _, ok := own[code]; ok {
+			continue
+		}
+
+		takenCodes[code] = struct{}{}
+		takenURLs[url] = struct{}{}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	err = service.CheckBatch(urls,
+		func(code string) bool { _, ok := takenCodes[code]; return ok },
+		func(url string) bool { _, ok := takenURLs[url]; return ok },
+	)
+	if err == nil {
+		return fmt.Errorf("failed to save batch: %d of %d urls aren't inserted", len(urls)-len(inserted), len(urls))
+	}
+
+	return err
+}
+
 // Save saves code with URL
-func (s *Storage) Save(ctx context.Context, code, url string) error {
-	q := `INSERT INTO shorten (code, url) VALUES($1, $2)`
+// returns service.ErrCodeAlreadyExists if the code is taken and service.ErrURLAlreadyExists if the URL is already saved
+func (s *Storage) Save(ctx context.Context, code, url string, expiresAt time.Time) error {
+	q := `WITH inserted AS (INSERT INTO shorten (code, url, expires_at) VALUES($1, $2, $3) RETURNING code, url)
+		INSERT INTO shorten_revisions (code, action, url) SELECT code, $4::varchar, url FROM inserted`
 
-	_, err := s.db.ExecContext(ctx, q, code, url)
+	_, err := s.pool.Exec(ctx, q, code, url, timePtr(expiresAt), service.RevisionCreated)
 	if err != nil {
-		if err, ok := err.(*pgconn.PgError); ok {
-			if err.Code == pgerrcode.UniqueViolation {
-				return service.ErrURLAlreadyExists
-			}
+		if err := uniqueViolation(err); err != nil {
+			return err
 		}
 
 		return fmt.Errorf("failed to exec query: %w", err)
@@ -69,159 +165,340 @@ func (s *Storage) Save(ctx context.Context, code, url string) error {
 	return nil
 }
 
+// uniqueViolation maps a unique violation of the table shorten to the service error
+// returns nil if err isn't a unique violation
+func uniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgerrcode.UniqueViolation {
+		return nil
+	}
+
+	if pgErr.ConstraintName == shortenCodeKey {
+		return service.ErrCodeAlreadyExists
+	}
+
+	return service.ErrURLAlreadyExists
+}
+
 // Exists seek code and returns true if it exists otherwise false
 func (s *Storage) Exists(ctx context.Context, code string) (bool, error) {
-	q := "SELECT url FROM shorten WHERE code = $1"
+	q := "SELECT EXISTS (SELECT 1 FROM shorten WHERE code = $1)"
 
-	row := s.db.QueryRowContext(ctx, q, code)
-	url := ""
-	if err := row.Scan(&url); err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
+	exists := false
+	if err := s.pool.QueryRow(ctx, q, code).Scan(&exists); err != nil {
 		return false, fmt.Errorf("failed to scan row: %w", err)
 	}
 
-	if err := row.Err(); err != nil {
-		return false, fmt.Errorf("rows error: %w", err)
-	}
-
-	return true, nil
+	return exists, nil
 }
 
 // URL returns URL by code
 func (s *Storage) URL(ctx context.Context, code string) (string, error) {
-	q := "SELECT url, deleted FROM shorten WHERE code = $1"
+	url, _, err := s.URLWithExpiry(ctx, code)
+	return url, err
+}
+
+// URLWithExpiry returns URL by code with the time when it expires, zero time means never
+func (s *Storage) URLWithExpiry(ctx context.Context, code string) (string, time.Time, error) {
+	q := "SELECT url, deleted_at IS NOT NULL, blocked_at IS NOT NULL, expires_at FROM shorten WHERE code = $1"
 
 	var url string
-	var deleted sql.NullBool
-	row := s.db.QueryRowContext(ctx, q, code)
-	if err := row.Scan(&url, &deleted); err != nil {
-		if err == sql.ErrNoRows {
-			return "", service.ErrNotFound
+	var deleted, blocked bool
+	var expiresAt *time.Time
+	if err := s.pool.QueryRow(ctx, q, code).Scan(&url, &deleted, &blocked, &expiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", time.Time{}, service.ErrNotFound
 		}
-		return "", fmt.Errorf("failed to scan row: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to scan row: %w", err)
 	}
 
-	if err := row.Err(); err != nil {
-		return "", fmt.Errorf("row error: %w", err)
+	if deleted {
+		return "", time.Time{}, service.ErrDeleted
 	}
 
-	if deleted.Valid && deleted.Bool {
-		return "", service.ErrDeleted
+	if blocked {
+		return "", time.Time{}, service.ErrBlocked
 	}
 
-	return url, nil
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", time.Time{}, service.ErrExpired
+	}
+
+	if expiresAt == nil {
+		return url, time.Time{}, nil
+	}
+
+	return url, *expiresAt, nil
 }
 
 // CodeByURL returns code by URL
+// returns service.ErrNotFound if the URL isn't saved
 func (s *Storage) CodeByURL(ctx context.Context, url string) (string, error) {
 	q := "SELECT code FROM shorten WHERE url = $1"
 
 	code := ""
-	row := s.db.QueryRowContext(ctx, q, url)
-	if err := row.Scan(&code); err != nil {
+	if err := s.pool.QueryRow(ctx, q, url).Scan(&code); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", service.ErrNotFound
+		}
 		return "", fmt.Errorf("failed to scan row: %w", err)
 	}
 
-	if err := row.Err(); err != nil {
-		return "", fmt.Errorf("row error: %w", err)
+	return code, nil
+}
+
+// UpdateURL replaces URL of the code and saves the revision in one transaction
+// the row is locked, so concurrent updates of the code are recorded in order
+func (s *Storage) UpdateURL(ctx context.Context, userID, code, url string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var prev string
+	var deleted bool
+	q := `SELECT url, deleted_at IS NOT NULL FROM shorten WHERE code = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, q, code).Scan(&prev, &deleted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return service.ErrNotFound
+		}
+		return fmt.Errorf("failed to scan row: %w", err)
 	}
 
-	return code, nil
+	switch {
+	case deleted:
+		return service.ErrDeleted
+	case prev == url:
+		return nil
+	}
+
+	// the unique index of shorten.url rejects the URL if it's saved with another code
+	if _, err := tx.Exec(ctx, `UPDATE shorten SET url = $1 WHERE code = $2`, url, code); err != nil {
+		if err := uniqueViolation(err); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if err := saveRevision(ctx, tx, service.Revision{Code: code, Action: service.RevisionUpdated, URL: url, PreviousURL: prev, UserID: userID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
 }
 
 // SaveUsersCode saves codes belong to the user
+// the first user of the code becomes its owner
+// returns service.ErrRecordAlreadyExists if the code already belongs to the user and service.ErrNotFound if the code isn't saved
 func (s *Storage) SaveUsersCode(ctx context.Context, userID string, code string) error {
-	q := `INSERT INTO USER_CODES (USER_ID, CODE) VALUES ($1, $2);`
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	_, err := s.db.ExecContext(ctx, q, userID, code)
+	_, err = tx.Exec(ctx, `INSERT INTO user_codes (user_id, code) VALUES ($1, $2)`, userID, code)
 	if err != nil {
-		if err, ok := err.(*pgconn.PgError); ok {
-			if err.Code == pgerrcode.UniqueViolation {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch {
+			case pgErr.Code == pgerrcode.UniqueViolation:
 				return service.ErrRecordAlreadyExists
+			case pgErr.Code == pgerrcode.ForeignKeyViolation && pgErr.ConstraintName == userCodesCodeFkey:
+				return service.ErrNotFound
 			}
 		}
 
 		return fmt.Errorf("failed to exec query: %w", err)
 	}
 
+	tag, err := tx.Exec(ctx, `UPDATE shorten SET owner_id = $1 WHERE code = $2 AND owner_id IS NULL`, userID, code)
+	if err != nil {
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	// the owner is the author of the creation
+	if tag.RowsAffected() > 0 {
+		q := `UPDATE shorten_revisions SET user_id = $1 WHERE code = $2 AND action = $3 AND user_id IS NULL`
+		if _, err := tx.Exec(ctx, q, userID, code, service.RevisionCreated); err != nil {
+			return fmt.Errorf("failed to exec query: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
 // UsersURLCodes return all users URL codes
 func (s *Storage) UsersURLCodes(ctx context.Context, userID string) ([]string, error) {
-	q := `SELECT CODE FROM user_codes WHERE USER_ID = $1;`
+	q := `SELECT code FROM user_codes WHERE user_id = $1 ORDER BY created_at`
 
-	rows, err := s.db.QueryContext(ctx, q, userID)
+	rows, err := s.pool.Query(ctx, q, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make query: %w", err)
 	}
-	defer rows.Close()
 
-	codes := make([]string, 0)
-	for rows.Next() {
-		var code string
-		if err := rows.Scan(&code); err != nil {
-			return nil, fmt.Errorf("failed to scan code: %w", err)
-		}
-
-		codes = append(codes, code)
-	}
-
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+	codes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan codes: %w", err)
 	}
 
 	return codes, nil
 }
 
-// DeleteURLs remove list of URLs from DB
-func (s *Storage) DeleteURLs(ctx context.Context, codes []string) error {
-	q := `UPDATE shorten SET deleted = true WHERE code = ANY($1);`
+// DeleteURLs marks URLs as deleted by codes and saves revisions of the deletion
+func (s *Storage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	q := `WITH deleted AS (
+			UPDATE shorten SET deleted_at = now() WHERE code = ANY($1) AND deleted_at IS NULL
+			RETURNING code, url, deleted_at
+		)
+		INSERT INTO shorten_revisions (code, action, url, previous_url, user_id, created_at)
+		SELECT code, $2::varchar, url, url, $3::uuid, deleted_at FROM deleted`
 
-	_, err := s.db.ExecContext(ctx, q, codes)
-	if err != nil {
+	if _, err := s.pool.Exec(ctx, q, codes, service.RevisionDeleted, stringPtr(userID)); err != nil {
 		return fmt.Errorf("failed to exec query: %w", err)
 	}
 
 	return nil
 }
 
-// UsersURLs returns users list of service.UsersURL
-func (s *Storage) UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error) {
-	q := `SELECT uc.code, s.url FROM user_codes uc LEFT JOIN shorten s on uc.code = s.code WHERE user_id = $1 AND s.url IS NOT NULL;`
+// UsersURLs returns a page of user's URLs
+// pages are read by the keyset of the sort column and the code, the key of the cursor is
+// microseconds of user_codes.created_at or shorten.clicks
+func (s *Storage) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	sortColumn, keyType := "uc.created_at", "timestamptz"
+	sortKey := func(createdAt time.Time, clicks int64) int64 { return createdAt.UnixMicro() }
+	var cursorKey any
+	if q.After != nil {
+		cursorKey = time.UnixMicro(q.After.Key)
+	}
 
-	rows, err := s.db.QueryContext(ctx, q, userID)
+	if q.Sort == service.UsersURLsSortClicks {
+		sortColumn, keyType = "s.clicks", "bigint"
+		sortKey = func(createdAt time.Time, clicks int64) int64 { return clicks }
+		if q.After != nil {
+			cursorKey = q.After.Key
+		}
+	}
+
+	direction, compare := "ASC", ">"
+	if q.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	args := []any{userID, q.Search}
+	where := `uc.user_id = $1 AND ($2 = '' OR strpos(lower(s.url), lower($2)) > 0)`
+	if q.After != nil {
+		args = append(args, cursorKey, q.After.Code)
+		where += fmt.Sprintf(` AND (%s, uc.code) %s ($3::%s, $4::varchar)`, sortColumn, compare, keyType)
+	}
+
+	limit := ""
+	if q.Limit > 0 {
+		// one more row is read to know if there is a next page
+		limit = fmt.Sprintf("LIMIT %d", q.Limit+1)
+	}
+
+	query := fmt.Sprintf(`SELECT uc.code, s.url, s.expires_at, s.deleted_at IS NOT NULL, s.clicks, uc.created_at
+		FROM user_codes uc JOIN shorten s ON uc.code = s.code
+		WHERE %s
+		ORDER BY %s %s, uc.code %s
+		%s`, where, sortColumn, direction, direction, limit)
+
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make query: %w", err)
 	}
+
 	defer rows.Close()
 
-	urls := make([]service.UsersURL, 0)
+	page := &service.UsersURLsPage{URLs: make([]service.UsersURL, 0)}
+	var prevKey int64
 	for rows.Next() {
-		var code, url string
-		if err := rows.Scan(&code, &url); err != nil {
+		u := service.UsersURL{}
+		var (
+			expiresAt *time.Time
+			clicks    int64
+			createdAt time.Time
+		)
+		if err := rows.Scan(&u.Code, &u.OriginalURL, &expiresAt, &u.Deleted, &clicks, &createdAt); err != nil {
 			return nil, fmt.Errorf("failed to scan code and url: %w", err)
 		}
 
-		urls = append(urls, service.UsersURL{Code: code, OriginalURL: url})
+		if q.Limit > 0 && len(page.URLs) == q.Limit {
+			prev := page.URLs[len(page.URLs)-1]
+			page.Next = &service.URLsCursor{Key: prevKey, Code: prev.Code}
+			break
+		}
+
+		if expiresAt != nil {
+			u.ExpiresAt = *expiresAt
+		}
+		u.Clicks = int(clicks)
+
+		prevKey = sortKey(createdAt, clicks)
+		page.URLs = append(page.URLs, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return page, nil
+}
+
+// IterateUsersURLs calls fn for every row of user's URLs while the rows are read from the connection
+func (s *Storage) IterateUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	q := `SELECT uc.code, s.url, s.expires_at, s.deleted_at IS NOT NULL
+		FROM user_codes uc JOIN shorten s ON uc.code = s.code
+		WHERE uc.user_id = $1
+		ORDER BY uc.created_at`
+
+	rows, err := s.pool.Query(ctx, q, userID)
+	if err != nil {
+		return fmt.Errorf("failed to make query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := service.UsersURL{}
+		var expiresAt *time.Time
+		if err := rows.Scan(&u.Code, &u.OriginalURL, &expiresAt, &u.Deleted); err != nil {
+			return fmt.Errorf("failed to scan code and url: %w", err)
+		}
+
+		if expiresAt != nil {
+			u.ExpiresAt = *expiresAt
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
 	}
 
-	return urls, nil
+	return nil
 }
 
-// URLCount returns count of URLs which field delete is null or is not equal true
+// URLCount returns count of URLs which are not deleted
 func (s *Storage) URLCount(ctx context.Context) (int, error) {
-	q := `SELECT count(*) FROM shorten WHERE deleted != true OR deleted IS NULL`
+	q := `SELECT count(*) FROM shorten WHERE deleted_at IS NULL`
 
 	cnt := 0
-	if err := s.db.QueryRowContext(ctx, q).Scan(&cnt); err != nil {
-		return 0, err
+	if err := s.pool.QueryRow(ctx, q).Scan(&cnt); err != nil {
+		return 0, fmt.Errorf("failed to scan row: %w", err)
 	}
 
 	return cnt, nil
@@ -232,9 +509,294 @@ func (s *Storage) UserCount(ctx context.Context) (int, error) {
 	q := `SELECT count(DISTINCT user_id) FROM user_codes`
 
 	cnt := 0
-	if err := s.db.QueryRowContext(ctx, q).Scan(&cnt); err != nil {
-		return 0, err
+	if err := s.pool.QueryRow(ctx, q).Scan(&cnt); err != nil {
+		return 0, fmt.Errorf("failed to scan row: %w", err)
 	}
 
 	return cnt, nil
 }
+
+// DeleteExpiredURLs marks as deleted all URLs which expiration time has come and saves revisions of the expiration
+// returns count of deleted URLs
+func (s *Storage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	q := `WITH deleted AS (
+			UPDATE shorten SET deleted_at = now() WHERE expires_at <= now() AND deleted_at IS NULL
+			RETURNING code, url, deleted_at
+		)
+		INSERT INTO shorten_revisions (code, action, url, previous_url, created_at)
+		SELECT code, $1::varchar, url, url, deleted_at FROM deleted`
+
+	tag, err := s.pool.Exec(ctx, q, service.RevisionExpired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// Revisions returns revisions of the code in order of making
+func (s *Storage) Revisions(ctx context.Context, code string) ([]service.Revision, error) {
+	q := `SELECT id, code, action, url, COALESCE(previous_url, ''), COALESCE(user_id::text, ''), created_at
+		FROM shorten_revisions WHERE code = $1 ORDER BY id`
+
+	rows, err := s.pool.Query(ctx, q, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make query: %w", err)
+	}
+
+	revisions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.Revision, error) {
+		r := service.Revision{}
+		err := row.Scan(&r.ID, &r.Code, &r.Action, &r.URL, &r.PreviousURL, &r.UserID, &r.Time)
+		return r, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RestoreURL sets the destination of the revision to the code and undeletes it in one transaction
+// zero revisionID keeps the current destination
+func (s *Storage) RestoreURL(ctx context.Context, userID, code string, revisionID int64) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var prev string
+	var deleted bool
+	q := `SELECT url, deleted_at IS NOT NULL FROM shorten WHERE code = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, q, code).Scan(&prev, &deleted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return service.ErrNotFound
+		}
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	url := prev
+	if revisionID != 0 {
+		q := `SELECT url FROM shorten_revisions WHERE id = $1 AND code = $2`
+		if err := tx.QueryRow(ctx, q, revisionID, code).Scan(&url); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return service.ErrNotFound
+			}
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+	}
+
+	if url == prev && !deleted {
+		return nil
+	}
+
+	// the unique index of shorten.url rejects the URL if it's saved with another code
+	if _, err := tx.Exec(ctx, `UPDATE shorten SET url = $1, deleted_at = NULL WHERE code = $2`, url, code); err != nil {
+		if err := uniqueViolation(err); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if err := saveRevision(ctx, tx, service.Revision{Code: code, Action: service.RevisionRestored, URL: url, PreviousURL: prev, UserID: userID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func saveRevision(ctx context.Context, tx pgx.Tx, r service.Revision) error {
+	q := `INSERT INTO shorten_revisions (code, action, url, previous_url, user_id) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(ctx, q, r.Code, r.Action, r.URL, stringPtr(r.PreviousURL), stringPtr(r.UserID)); err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+
+	return nil
+}
+
+// SaveClicks copies click events and increases click counters of codes in one transaction
+func (s *Storage) SaveClicks(ctx context.Context, clicks []service.Click) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	columns := []string{"code", "clicked_at", "referrer", "user_agent", "ip", "referrer_domain", "browser", "os"}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"click_events"}, columns, pgx.CopyFromSlice(len(clicks), func(i int) ([]any, error) {
+		c := clicks[i]
+		return []any{c.Code, c.Time, c.Referrer, c.UserAgent, c.IP, c.ReferrerDomain, c.Browser, c.OS}, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to copy clicks: %w", err)
+	}
+
+	counters := make(map[string]int64)
+	for _, c := range clicks {
+		counters[c.Code]++
+	}
+
+	codes := make([]string, 0, len(counters))
+	counts := make([]int64, 0, len(counters))
+	for code, cnt := range counters {
+		codes = append(codes, code)
+		counts = append(counts, cnt)
+	}
+
+	q := `UPDATE shorten s SET clicks = s.clicks + c.cnt
+		FROM unnest($1::varchar[], $2::bigint[]) AS c(code, cnt)
+		WHERE s.code = c.code`
+	if _, err = tx.Exec(ctx, q, codes, counts); err != nil {
+		return fmt.Errorf("failed to exec query: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ClickCount returns click counter of the code
+func (s *Storage) ClickCount(ctx context.Context, code string) (int, error) {
+	q := `SELECT clicks FROM shorten WHERE code = $1`
+
+	cnt := 0
+	if err := s.pool.QueryRow(ctx, q, code).Scan(&cnt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, service.ErrNotFound
+		}
+		return 0, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	return cnt, nil
+}
+
+// Clicks returns the latest click events of the code
+// if limit isn't positive returns all events
+func (s *Storage) Clicks(ctx context.Context, code string, limit int) ([]service.Click, error) {
+	q := `SELECT code, clicked_at, referrer, user_agent, ip, referrer_domain, browser, os
+		FROM click_events WHERE code = $1 ORDER BY clicked_at DESC LIMIT $2`
+
+	var limitArg *int
+	if limit > 0 {
+		limitArg = &limit
+	}
+
+	rows, err := s.pool.Query(ctx, q, code, limitArg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make query: %w", err)
+	}
+
+	clicks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.Click, error) {
+		c := service.Click{}
+		err := row.Scan(&c.Code, &c.Time, &c.Referrer, &c.UserAgent, &c.IP, &c.ReferrerDomain, &c.Browser, &c.OS)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan clicks: %w", err)
+	}
+
+	return clicks, nil
+}
+
+// ClickStats aggregates clicks of the code in the range of the query
+func (s *Storage) ClickStats(ctx context.Context, q service.ClickStatsQuery) (*service.ClickStats, error) {
+	stats := &service.ClickStats{Code: q.Code}
+
+	totalsQuery := `SELECT count(*), count(DISTINCT (ip, user_agent)) FROM click_events
+		WHERE code = $1 AND clicked_at >= $2 AND clicked_at < $3`
+
+	err := s.pool.QueryRow(ctx, totalsQuery, q.Code, q.From, q.To).Scan(&stats.Clicks, &stats.UniqueVisitors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan totals: %w", err)
+	}
+
+	stats.Buckets, err = s.clickBuckets(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get buckets: %w", err)
+	}
+
+	stats.Referrers, err = s.clickGroups(ctx, "referrer_domain", q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get referrers: %w", err)
+	}
+
+	stats.Browsers, err = s.clickGroups(ctx, "browser", q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get browsers: %w", err)
+	}
+
+	stats.OS, err = s.clickGroups(ctx, "os", q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get os: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) clickBuckets(ctx context.Context, q service.ClickStatsQuery) ([]service.ClickBucket, error) {
+	query := `SELECT date_trunc($2, clicked_at AT TIME ZONE 'UTC') AS bucket, count(*) FROM click_events
+		WHERE code = $1 AND clicked_at >= $3 AND clicked_at < $4 GROUP BY bucket ORDER BY bucket`
+
+	rows, err := s.pool.Query(ctx, query, q.Code, q.Interval, q.From, q.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make query: %w", err)
+	}
+
+	buckets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.ClickBucket, error) {
+		b := service.ClickBucket{}
+		err := row.Scan(&b.Time, &b.Clicks)
+		return b, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan buckets: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// clickGroups counts clicks grouped by the column
+// column must be one of the trusted column names and never a user input
+func (s *Storage) clickGroups(ctx context.Context, column string, q service.ClickStatsQuery) ([]service.ClickGroup, error) {
+	query := fmt.Sprintf(`SELECT %[1]s, count(*) AS cnt FROM click_events
+		WHERE code = $1 AND clicked_at >= $2 AND clicked_at < $3 AND %[1]s <> ''
+		GROUP BY %[1]s ORDER BY cnt DESC, %[1]s LIMIT $4`, column)
+
+	rows, err := s.pool.Query(ctx, query, q.Code, q.From, q.To, q.Top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make query: %w", err)
+	}
+
+	groups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.ClickGroup, error) {
+		g := service.ClickGroup{}
+		err := row.Scan(&g.Name, &g.Clicks)
+		return g, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan groups: %w", err)
+	}
+
+	return groups, nil
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+func stringPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
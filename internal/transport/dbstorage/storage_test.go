@@ -0,0 +1,47 @@
+package dbstorage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/transport/dbstorage"
+	"github.com/lks-go/url-shortener/internal/transport/pgtest"
+	"github.com/lks-go/url-shortener/internal/transport/storagetest"
+	"github.com/lks-go/url-shortener/migrations"
+)
+
+// testDSNEnv is a connection string of a throwaway database, all its data is removed by the tests
+// if it isn't set an embedded Postgres is started
+const testDSNEnv = "TEST_DATABASE_DSN"
+
+func TestStorage_Conformance(t *testing.T) {
+	pool := testPool(t)
+
+	storagetest.RunURLStorage(t, func(t *testing.T) service.URLStorage {
+		_, err := pool.Exec(context.Background(), `TRUNCATE shorten, user_codes, click_events, shorten_revisions`)
+		require.NoError(t, err)
+
+		return dbstorage.New(pool)
+	})
+}
+
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), pgtest.DSN(t, testDSNEnv))
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	require.NoError(t, pool.Ping(context.Background()))
+
+	db := stdlib.OpenDBFromPool(pool)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, migrations.RunUp(db))
+
+	return pool
+}
@@ -0,0 +1,145 @@
+package grpchandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/pkg/proto"
+)
+
+// AdminService это интерфейс сервиса модерации, права администратора проверяет сам сервис
+type AdminService interface {
+	URLInfo(ctx context.Context, adminID, code string) (*service.URLInfo, error)
+	ForceDeleteURL(ctx context.Context, adminID, code string) error
+	BlockURL(ctx context.Context, adminID, code string, blocked bool) error
+	UserURLs(ctx context.Context, adminID, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error)
+	BanUser(ctx context.Context, adminID, userID string, banned bool) error
+}
+
+type AdminDeps struct {
+	Service AdminService
+}
+
+func NewAdmin(cfg Config, d *AdminDeps) *AdminHandler {
+	return &AdminHandler{
+		redirectBasePath: cfg.RedirectBasePath,
+		service:          d.Service,
+	}
+}
+
+type AdminHandler struct {
+	redirectBasePath string
+	service          AdminService
+
+	proto.UnimplementedAdminServiceServer
+}
+
+func (h *AdminHandler) URL(ctx context.Context, request *proto.AdminURLRequest) (*proto.AdminURLResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	info, err := h.service.URLInfo(ctx, user.UserID, request.Code)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	resp := &proto.AdminURLResponse{
+		Code:        info.Code,
+		OriginalUrl: info.OriginalURL,
+		ShortUrl:    fmt.Sprintf("%s/%s", h.redirectBasePath, info.Code),
+		OwnerId:     info.OwnerID,
+		Deleted:     info.Deleted,
+		Blocked:     info.Blocked,
+		CreatedAt:   timestamppb.New(info.CreatedAt),
+	}
+	if !info.ExpiresAt.IsZero() {
+		resp.ExpiresAt = timestamppb.New(info.ExpiresAt)
+	}
+
+	return resp, nil
+}
+
+func (h *AdminHandler) DeleteURL(ctx context.Context, request *proto.AdminDeleteURLRequest) (*proto.AdminDeleteURLResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	if err := h.service.ForceDeleteURL(ctx, user.UserID, request.Code); err != nil {
+		return nil, adminError(err)
+	}
+
+	return &proto.AdminDeleteURLResponse{}, nil
+}
+
+func (h *AdminHandler) BlockURL(ctx context.Context, request *proto.AdminBlockURLRequest) (*proto.AdminBlockURLResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	if err := h.service.BlockURL(ctx, user.UserID, request.Code, request.Blocked); err != nil {
+		return nil, adminError(err)
+	}
+
+	return &proto.AdminBlockURLResponse{}, nil
+}
+
+func (h *AdminHandler) UserURLs(ctx context.Context, request *proto.AdminUserURLsRequest) (*proto.UsersURLsResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	q, err := usersURLsQuery(request.Query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := h.service.UserURLs(ctx, user.UserID, request.UserId, q)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	return usersURLsResponse(h.redirectBasePath, page), nil
+}
+
+func (h *AdminHandler) BanUser(ctx context.Context, request *proto.AdminBanUserRequest) (*proto.AdminBanUserResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	if err := h.service.BanUser(ctx, user.UserID, request.UserId, request.Banned); err != nil {
+		return nil, adminError(err)
+	}
+
+	return &proto.AdminBanUserResponse{}, nil
+}
+
+// adminError maps an error of moderation to the status
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return status.Error(codes.PermissionDenied, (codes.PermissionDenied).String())
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, (codes.NotFound).String())
+	case errors.Is(err, service.ErrInvalidUsersURLsQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrModerationNotSupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+
+	logrus.Errorf("failed to moderate: %s", err)
+	return status.Error(codes.Internal, (codes.Internal).String())
+}
@@ -4,14 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"regexp"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/lks-go/url-shortener/internal/entity"
 	"github.com/lks-go/url-shortener/internal/service"
@@ -20,11 +22,17 @@ import (
 
 // Service это интерфейс сервиса отвечающего за обратоку входящих http запросов
 type Service interface {
-	MakeBatchShortURL(ctx context.Context, userID string, urls []service.URL) ([]service.URL, error)
-	MakeShortURL(ctx context.Context, userID, url string) (string, error)
+	MakeBatchShortURL(ctx context.Context, userID string, urls []service.URL) ([]service.BatchResult, error)
+	MakeShortURL(ctx context.Context, userID, url string, opts service.ShortenOptions) (string, error)
 	URL(ctx context.Context, id string) (string, error)
-	UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error)
+	UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error)
+	ExportUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error
+	UpdateURL(ctx context.Context, userID, code, url string) (string, error)
+	URLHistory(ctx context.Context, userID, code string) ([]service.Revision, error)
+	RestoreURL(ctx context.Context, userID, code string, revisionID int64) error
 	Stats(ctx context.Context) (*service.StatsInfo, error)
+	ClickStats(ctx context.Context, userID string, q service.ClickStatsQuery) (*service.ClickStats, error)
+	ImportURLs(ctx context.Context, userID string, r service.ImportReader, checkpoint int64, progress func(service.ImportProgress) error) (service.ImportProgress, error)
 }
 
 // Deleter это интерфейс сервиса отвечающего за получение запроса на удаление
@@ -71,13 +79,12 @@ type Handler struct {
 }
 
 func (h *Handler) ShortURL(ctx context.Context, request *proto.ShortURLRequest) (*proto.ShortURLResponse, error) {
-	userID, err := outgoingMetaData(ctx, entity.UserIDHeaderName)
-	if err != nil {
-		logrus.Errorf("failed to get metadata: %s", err)
-		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
 	}
 
-	id, err := h.service.MakeShortURL(ctx, userID[0], request.Url)
+	id, err := h.service.MakeShortURL(ctx, user.UserID, request.Url, service.ShortenOptions{})
 	if err != nil && !errors.Is(err, service.ErrURLAlreadyExists) {
 		logrus.Errorf("failed to make short url: %s", err)
 		return nil, status.Error(codes.Internal, (codes.Internal).String())
@@ -97,7 +104,7 @@ func (h *Handler) Redirect(ctx context.Context, request *proto.RedirectRequest)
 		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
 	}
 
-	matches := regexp.MustCompile(`/(\w+)`).FindStringSubmatch(parsedURL.Path)
+	matches := regexp.MustCompile(`^/([^/]+)`).FindStringSubmatch(parsedURL.Path)
 	if len(matches) < 1 {
 		logrus.Errorf("failed to compile shorten url: %s", request.ShortenUrl)
 		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
@@ -109,8 +116,10 @@ func (h *Handler) Redirect(ctx context.Context, request *proto.RedirectRequest)
 		switch {
 		case errors.Is(err, service.ErrNotFound):
 			return nil, status.Error(codes.NotFound, (codes.NotFound).String())
-		case errors.Is(err, service.ErrDeleted):
+		case errors.Is(err, service.ErrDeleted), errors.Is(err, service.ErrExpired):
 			return nil, status.Error(codes.NotFound, (codes.NotFound).String())
+		case errors.Is(err, service.ErrBlocked):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
 		default:
 			logrus.Errorf("failed to get url by code [%s]: %s", code, err)
 			return nil, status.Error(codes.Internal, (codes.Internal).String())
@@ -121,14 +130,26 @@ func (h *Handler) Redirect(ctx context.Context, request *proto.RedirectRequest)
 }
 
 func (h *Handler) ShortenURL(ctx context.Context, request *proto.ShortenURLRequest) (*proto.ShortenURLResponse, error) {
-	userID, err := outgoingMetaData(ctx, entity.UserIDHeaderName)
-	if err != nil {
-		logrus.Errorf("failed to get metadata: %s", err)
-		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
 	}
 
-	id, err := h.service.MakeShortURL(ctx, userID[0], request.Url)
-	if err != nil && !errors.Is(err, service.ErrURLAlreadyExists) {
+	opts := service.ShortenOptions{
+		Alias: request.Alias,
+		TTL:   time.Duration(request.Ttl) * time.Second,
+	}
+	if request.ExpiresAt != nil {
+		opts.ExpiresAt = request.ExpiresAt.AsTime()
+	}
+
+	id, err := h.service.MakeShortURL(ctx, user.UserID, request.Url, opts)
+	switch {
+	case errors.Is(err, service.ErrInvalidAlias), errors.Is(err, service.ErrInvalidExpiration):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrAliasTaken):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil && !errors.Is(err, service.ErrURLAlreadyExists):
 		logrus.Errorf("failed to make short url: %s", err)
 		return nil, status.Error(codes.Internal, (codes.Internal).String())
 	}
@@ -140,66 +161,246 @@ func (h *Handler) ShortenURL(ctx context.Context, request *proto.ShortenURLReque
 	return &proto.ShortenURLResponse{Result: fmt.Sprintf("%s/%s", h.redirectBasePath, id)}, nil
 }
 
+// batchStatuses maps statuses of batch items to their proto values
+var batchStatuses = map[string]proto.BatchStatus{
+	service.BatchStatusCreated: proto.BatchStatus_BATCH_STATUS_CREATED,
+	service.BatchStatusExists:  proto.BatchStatus_BATCH_STATUS_EXISTS,
+	service.BatchStatusInvalid: proto.BatchStatus_BATCH_STATUS_INVALID,
+}
+
 func (h *Handler) ShortenBatchURL(ctx context.Context, request *proto.ShortenBatchURLRequest) (*proto.ShortenBatchURLResponse, error) {
-	userID, err := outgoingMetaData(ctx, entity.UserIDHeaderName)
-	if err != nil {
-		logrus.Errorf("failed to get metadata: %s", err)
-		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
 	}
 
 	urlList := make([]service.URL, 0, len(request.Urls))
 	for _, u := range request.Urls {
-		urlList = append(urlList, service.URL{
+		item := service.URL{
 			СorrelationID: u.CorrelationId,
 			OriginalURL:   u.OriginalUrl,
-		})
+			TTL:           time.Duration(u.Ttl) * time.Second,
+		}
+		if u.ExpiresAt != nil {
+			item.ExpiresAt = u.ExpiresAt.AsTime()
+		}
+
+		urlList = append(urlList, item)
 	}
 
-	shortURLList, err := h.service.MakeBatchShortURL(ctx, userID[0], urlList)
+	results, err := h.service.MakeBatchShortURL(ctx, user.UserID, urlList)
 	if err != nil {
 		logrus.Errorf("failed to make batch short urls: %s", err)
 		return nil, status.Error(codes.Internal, (codes.Internal).String())
 	}
 
-	urls := make([]*proto.ShortenBatchURLResponse_URL, 0, len(shortURLList))
-	for _, u := range shortURLList {
-		urls = append(urls, &proto.ShortenBatchURLResponse_URL{
-			CorrelationId: u.СorrelationID,
-			ShortUrl:      u.OriginalURL,
-		})
+	urls := make([]*proto.ShortenBatchURLResponse_URL, 0, len(results))
+	for _, r := range results {
+		item := &proto.ShortenBatchURLResponse_URL{
+			CorrelationId: r.СorrelationID,
+			Status:        batchStatuses[r.Status],
+			Reason:        r.Reason,
+		}
+		if r.Code != "" {
+			item.ShortUrl = fmt.Sprintf("%s/%s", h.redirectBasePath, r.Code)
+		}
+
+		urls = append(urls, item)
 	}
 
 	return &proto.ShortenBatchURLResponse{Urls: urls}, nil
 }
 
+func (h *Handler) ImportURLs(stream proto.URLShortener_ImportURLsServer) error {
+	user, ok := entity.IdentityFromContext(stream.Context())
+	if !ok {
+		return status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return stream.Send(&proto.ImportURLsResponse{Done: true})
+	}
+	if err != nil {
+		return err
+	}
+
+	send := func(p service.ImportProgress, done bool) error {
+		resp := &proto.ImportURLsResponse{
+			Checkpoint: p.Checkpoint,
+			Created:    p.Created,
+			Exists:     p.Exists,
+			Invalid:    p.Invalid,
+			Rejected:   make([]*proto.ImportURLsResponse_Rejected, 0, len(p.Rejected)),
+			Done:       done,
+		}
+		for _, r := range p.Rejected {
+			resp.Rejected = append(resp.Rejected, &proto.ImportURLsResponse_Rejected{
+				Record:        r.Record,
+				CorrelationId: r.СorrelationID,
+				Reason:        r.Reason,
+			})
+		}
+
+		return stream.Send(resp)
+	}
+
+	r := &importStreamReader{stream: stream, urls: first.Urls}
+	p, err := h.service.ImportURLs(stream.Context(), user.UserID, r, first.Checkpoint, func(p service.ImportProgress) error {
+		return send(p, false)
+	})
+	if err != nil {
+		logrus.Errorf("failed to import urls (checkpoint = %d): %s", p.Checkpoint, err)
+		return status.Errorf(codes.Aborted, "import interrupted at checkpoint %d", p.Checkpoint)
+	}
+
+	return send(p, true)
+}
+
+// importStreamReader reads records of an import from messages of the stream
+type importStreamReader struct {
+	stream proto.URLShortener_ImportURLsServer
+	urls   []*proto.ShortenBatchURLRequest_URL
+}
+
+func (r *importStreamReader) Read() (service.URL, error) {
+	for len(r.urls) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return service.URL{}, err
+		}
+
+		r.urls = req.Urls
+	}
+
+	u := r.urls[0]
+	r.urls = r.urls[1:]
+
+	item := service.URL{
+		СorrelationID: u.CorrelationId,
+		OriginalURL:   u.OriginalUrl,
+		TTL:           time.Duration(u.Ttl) * time.Second,
+	}
+	if u.ExpiresAt != nil {
+		item.ExpiresAt = u.ExpiresAt.AsTime()
+	}
+
+	return item, nil
+}
+
 func (h *Handler) UsersURLs(ctx context.Context, request *proto.UsersURLsRequest) (*proto.UsersURLsResponse, error) {
-	userID, err := outgoingMetaData(ctx, entity.UserIDHeaderName)
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	q, err := usersURLsQuery(request)
 	if err != nil {
-		logrus.Errorf("failed to get metadata: %s", err)
-		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	usersUrls, err := h.service.UsersURLs(ctx, userID[0])
+	page, err := h.service.UsersURLs(ctx, user.UserID, q)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidUsersURLsQuery) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
 		logrus.Errorf("failed to get users urls: %s", err)
 		return nil, status.Error(codes.Internal, (codes.Internal).String())
 	}
 
-	urls := make([]*proto.UsersURLsResponse_URL, 0, len(usersUrls))
+	return usersURLsResponse(h.redirectBasePath, page), nil
+}
+
+// usersURLsQuery returns the query of a page of user's URLs, request may be nil
+func usersURLsQuery(request *proto.UsersURLsRequest) (service.UsersURLsQuery, error) {
+	q := service.UsersURLsQuery{
+		Search: request.GetSearch(),
+		Sort:   request.GetSort(),
+		Desc:   request.GetDesc(),
+		Limit:  int(request.GetLimit()),
+	}
+	if request.GetCursor() != "" {
+		var err error
+		q.After, err = service.ParseURLsCursor(request.GetCursor())
+		if err != nil {
+			return service.UsersURLsQuery{}, err
+		}
+	}
+
+	return q, nil
+}
 
-	return &proto.UsersURLsResponse{Urls: urls}, nil
+func usersURLsResponse(redirectBasePath string, page *service.UsersURLsPage) *proto.UsersURLsResponse {
+	resp := &proto.UsersURLsResponse{Urls: make([]*proto.UsersURLsResponse_URL, 0, len(page.URLs))}
+	for _, u := range page.URLs {
+		resp.Urls = append(resp.Urls, &proto.UsersURLsResponse_URL{
+			OriginalUrl: u.OriginalURL,
+			ShortUrl:    fmt.Sprintf("%s/%s", redirectBasePath, u.Code),
+			Clicks:      int64(u.Clicks),
+		})
+	}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.String()
+	}
+
+	return resp
 }
 
-func (h *Handler) Delete(ctx context.Context, request *proto.DeleteRequest) (*proto.DeleteResponse, error) {
-	userID, err := outgoingMetaData(ctx, entity.UserIDHeaderName)
+// exportMessageSize is a count of URLs sent in a single message of the export stream
+const exportMessageSize = 100
+
+func (h *Handler) ExportURLs(request *proto.ExportURLsRequest, stream proto.URLShortener_ExportURLsServer) error {
+	user, ok := entity.IdentityFromContext(stream.Context())
+	if !ok {
+		return status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	urls := make([]*proto.ExportURLsResponse_URL, 0, exportMessageSize)
+	err := h.service.ExportUsersURLs(stream.Context(), user.UserID, func(u service.UsersURL) error {
+		item := &proto.ExportURLsResponse_URL{
+			Code:        u.Code,
+			ShortUrl:    fmt.Sprintf("%s/%s", h.redirectBasePath, u.Code),
+			OriginalUrl: u.OriginalURL,
+			Deleted:     u.Deleted,
+		}
+		if !u.ExpiresAt.IsZero() {
+			item.ExpiresAt = timestamppb.New(u.ExpiresAt)
+		}
+
+		urls = append(urls, item)
+		if len(urls) < exportMessageSize {
+			return nil
+		}
+
+		if err := stream.Send(&proto.ExportURLsResponse{Urls: urls}); err != nil {
+			return err
+		}
+		urls = make([]*proto.ExportURLsResponse_URL, 0, exportMessageSize)
+
+		return nil
+	})
 	if err != nil {
-		logrus.Errorf("failed to get metadata: %s", err)
-		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
+		logrus.Errorf("failed to export users urls: %s", err)
+		return status.Error(codes.Internal, (codes.Internal).String())
+	}
+
+	if len(urls) > 0 {
+		return stream.Send(&proto.ExportURLsResponse{Urls: urls})
+	}
+
+	return nil
+}
+
+func (h *Handler) Delete(ctx context.Context, request *proto.DeleteRequest) (*proto.DeleteResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
 	}
 
 	go func() {
-		if err := h.deleter.Delete(ctx, userID[0], request.Codes); err != nil {
-			logrus.Errorf("failed to delete urls (userId = %s, codes = [%v]): %s", userID, request.Codes, err)
+		if err := h.deleter.Delete(ctx, user.UserID, request.Codes); err != nil {
+			logrus.Errorf("failed to delete urls (userId = %s, codes = [%v]): %s", user.UserID, request.Codes, err)
 		}
 	}()
 
@@ -207,17 +408,10 @@ func (h *Handler) Delete(ctx context.Context, request *proto.DeleteRequest) (*pr
 }
 
 func (h *Handler) Stats(ctx context.Context, _ *proto.StatsRequest) (*proto.StatsResponse, error) {
-	ips, err := incomingMetaData(ctx, "X-Real-IP")
-	if err != nil {
-		logrus.Errorf("failed to get metadata: %s", err)
-		return nil, status.Error(codes.InvalidArgument, (codes.InvalidArgument).String())
-	}
-
-	ip := ips[0]
-	if h.ipNet != nil && !h.ipNet.Contains(net.ParseIP(ip)) {
+	ip, _ := entity.ClientIPFromContext(ctx)
+	if h.ipNet != nil && !h.ipNet.Contains(ip) {
 		logrus.Errorf("ip %s is not in trusted subnet", ip)
 		return nil, status.Error(codes.PermissionDenied, (codes.PermissionDenied).String())
-
 	}
 
 	statsInfo, err := h.service.Stats(ctx)
@@ -229,30 +423,148 @@ func (h *Handler) Stats(ctx context.Context, _ *proto.StatsRequest) (*proto.Stat
 	return &proto.StatsResponse{Urls: int64(statsInfo.URLCount), Users: int64(statsInfo.UserCount)}, nil
 }
 
-func outgoingMetaData(ctx context.Context, key string) ([]string, error) {
-	md, ok := metadata.FromOutgoingContext(ctx)
+func (h *Handler) UpdateURL(ctx context.Context, request *proto.UpdateURLRequest) (*proto.UpdateURLResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	code, err := h.service.UpdateURL(ctx, user.UserID, request.Code, request.Url)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidURL):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, service.ErrURLAlreadyExists):
+			return nil, status.Errorf(codes.AlreadyExists, "URL is already shortened: %s/%s", h.redirectBasePath, code)
+		case errors.Is(err, service.ErrNotFound):
+			return nil, status.Error(codes.NotFound, (codes.NotFound).String())
+		case errors.Is(err, service.ErrDeleted):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, service.ErrForbidden):
+			return nil, status.Error(codes.PermissionDenied, (codes.PermissionDenied).String())
+		}
+
+		logrus.Errorf("failed to update url of code [%s]: %s", request.Code, err)
+		return nil, status.Error(codes.Internal, (codes.Internal).String())
+	}
+
+	return &proto.UpdateURLResponse{ShortUrl: fmt.Sprintf("%s/%s", h.redirectBasePath, code)}, nil
+}
+
+func (h *Handler) URLHistory(ctx context.Context, request *proto.URLHistoryRequest) (*proto.URLHistoryResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
 	if !ok {
-		return nil, fmt.Errorf("missing metadata")
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	revisions, err := h.service.URLHistory(ctx, user.UserID, request.Code)
+	if err != nil {
+		return nil, revisionError(request.Code, err)
 	}
 
-	value := md.Get(key)
-	if len(value) == 0 {
-		return nil, fmt.Errorf("%s not supplied", key)
+	resp := &proto.URLHistoryResponse{Revisions: make([]*proto.URLHistoryResponse_Revision, 0, len(revisions))}
+	for _, r := range revisions {
+		resp.Revisions = append(resp.Revisions, &proto.URLHistoryResponse_Revision{
+			Id:          r.ID,
+			Action:      r.Action,
+			Url:         r.URL,
+			PreviousUrl: r.PreviousURL,
+			UserId:      r.UserID,
+			Time:        timestamppb.New(r.Time),
+		})
 	}
 
-	return value, nil
+	return resp, nil
 }
 
-func incomingMetaData(ctx context.Context, key string) ([]string, error) {
-	md, ok := metadata.FromIncomingContext(ctx)
+func (h *Handler) RestoreURL(ctx context.Context, request *proto.RestoreURLRequest) (*proto.RestoreURLResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
 	if !ok {
-		return nil, fmt.Errorf("missing metadata")
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	if err := h.service.RestoreURL(ctx, user.UserID, request.Code, request.Revision); err != nil {
+		return nil, revisionError(request.Code, err)
+	}
+
+	return &proto.RestoreURLResponse{ShortUrl: fmt.Sprintf("%s/%s", h.redirectBasePath, request.Code)}, nil
+}
+
+// revisionError maps an error of history or restoring of the code to the status
+func revisionError(code string, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, (codes.NotFound).String())
+	case errors.Is(err, service.ErrForbidden):
+		return status.Error(codes.PermissionDenied, (codes.PermissionDenied).String())
+	case errors.Is(err, service.ErrURLAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrRevisionsNotSupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+
+	logrus.Errorf("failed to handle revisions of code [%s]: %s", code, err)
+	return status.Error(codes.Internal, (codes.Internal).String())
+}
+
+func (h *Handler) ClickStats(ctx context.Context, request *proto.ClickStatsRequest) (*proto.ClickStatsResponse, error) {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	q := service.ClickStatsQuery{
+		Code:     request.Code,
+		Interval: request.Interval,
+		Top:      int(request.Top),
 	}
+	if request.From != nil {
+		q.From = request.From.AsTime()
+	}
+	if request.To != nil {
+		q.To = request.To.AsTime()
+	}
+
+	stats, err := h.service.ClickStats(ctx, user.UserID, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStatsQuery):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, service.ErrNotFound):
+			return nil, status.Error(codes.NotFound, (codes.NotFound).String())
+		case errors.Is(err, service.ErrForbidden):
+			return nil, status.Error(codes.PermissionDenied, (codes.PermissionDenied).String())
+		}
+
+		logrus.Errorf("failed to get click stats: %s", err)
+		return nil, status.Error(codes.Internal, (codes.Internal).String())
+	}
+
+	resp := &proto.ClickStatsResponse{
+		Code:           stats.Code,
+		Clicks:         int64(stats.Clicks),
+		UniqueVisitors: int64(stats.UniqueVisitors),
+		Buckets:        make([]*proto.ClickStatsResponse_Bucket, 0, len(stats.Buckets)),
+		Referrers:      clickGroups(stats.Referrers),
+		Browsers:       clickGroups(stats.Browsers),
+		Os:             clickGroups(stats.OS),
+	}
+
+	for _, b := range stats.Buckets {
+		resp.Buckets = append(resp.Buckets, &proto.ClickStatsResponse_Bucket{
+			Time:   timestamppb.New(b.Time),
+			Clicks: int64(b.Clicks),
+		})
+	}
+
+	return resp, nil
+}
 
-	value := md.Get(key)
-	if len(value) == 0 {
-		return nil, fmt.Errorf("%s not supplied", key)
+func clickGroups(groups []service.ClickGroup) []*proto.ClickStatsResponse_Group {
+	res := make([]*proto.ClickStatsResponse_Group, 0, len(groups))
+	for _, g := range groups {
+		res = append(res, &proto.ClickStatsResponse_Group{Name: g.Name, Clicks: int64(g.Clicks)})
 	}
 
-	return value, nil
+	return res
 }
@@ -0,0 +1,243 @@
+package httphandlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// credentials тело запросов регистрации и входа
+// если claim установлен, то ссылки текущего анонимного пользователя переносятся в аккаунт
+type credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Claim    bool   `json:"claim"`
+}
+
+// SignUp регистрирует аккаунт и выставляет cookie с его токеном
+func (h *Handlers) SignUp(w http.ResponseWriter, req *http.Request) {
+	c := credentials{}
+	if err := json.NewDecoder(req.Body).Decode(&c); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.service.SignUp(req.Context(), c.Login, c.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLogin), errors.Is(err, service.ErrInvalidPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrLoginTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrAccountsNotSupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			logrus.Errorf("failed to sign up: %s", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.startSession(w, req, account, c.Claim, http.StatusCreated)
+}
+
+// Login проверяет логин и пароль аккаунта и выставляет cookie с его токеном
+func (h *Handlers) Login(w http.ResponseWriter, req *http.Request) {
+	c := credentials{}
+	if err := json.NewDecoder(req.Body).Decode(&c); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.service.Login(req.Context(), c.Login, c.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, service.ErrAccountsNotSupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			logrus.Errorf("failed to login: %s", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.startSession(w, req, account, c.Claim, http.StatusOK)
+}
+
+// startSession переносит ссылки текущего пользователя в аккаунт, если он анонимный, и выставляет cookie аккаунта
+// переносятся только ссылки пользователя cookie сессии, ссылки пользователей OIDC и API ключей остаются у них
+func (h *Handlers) startSession(w http.ResponseWriter, req *http.Request, account *service.Account, claim bool, status int) {
+	var claimed int
+	if user, ok := entity.IdentityFromContext(req.Context()); claim && ok && user.Kind == entity.KindSession {
+		var err error
+		claimed, err = h.service.ClaimURLs(req.Context(), user.UserID, account.ID)
+		if err != nil && !errors.Is(err, service.ErrForbidden) {
+			logrus.Errorf("failed to claim urls of user %s: %s", user.UserID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !h.setAuthCookie(w, account.ID) {
+		return
+	}
+
+	writeJSON(w, status, struct {
+		UserID  string `json:"user_id"`
+		Login   string `json:"login"`
+		Claimed int    `json:"claimed"`
+	}{
+		UserID:  account.ID,
+		Login:   account.Login,
+		Claimed: claimed,
+	})
+}
+
+// setAuthCookie выставляет cookie с токеном пользователя, при ошибке отвечает 500 и возвращает false
+func (h *Handlers) setAuthCookie(w http.ResponseWriter, userID string) bool {
+	token, err := h.tokenBuilder.BuildNewJWTToken(userID)
+	if err != nil {
+		logrus.Errorf("failed to build token: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:    entity.AuthTokenHeader,
+		Value:   token,
+		Expires: time.Now().Add(entity.CookieExpires),
+	})
+
+	return true
+}
+
+// apiKeyResponse описание API ключа в ответе, сам ключ возвращается только при создании
+type apiKeyResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Key       string    `json:"key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateAPIKey создаёт API ключ аккаунта с указанными в теле запроса именем и списком scopes
+// ключ возвращается в ответе один раз и передаётся в заголовке Authorization: Bearer
+func (h *Handlers) CreateAPIKey(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body := struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	key, k, err := h.service.CreateAPIKey(req.Context(), user.UserID, body.Name, body.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidScope):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		case errors.Is(err, service.ErrAccountsNotSupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			logrus.Errorf("failed to create api key: %s", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, apiKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Scopes:    k.Scopes,
+		Key:       key,
+		CreatedAt: k.CreatedAt,
+	})
+}
+
+// APIKeys возвращает API ключи аккаунта без самих ключей
+func (h *Handlers) APIKeys(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.service.APIKeys(req.Context(), user.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountsNotSupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		logrus.Errorf("failed to get api keys: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, apiKeyResponse{ID: k.ID, Name: k.Name, Scopes: k.Scopes, CreatedAt: k.CreatedAt})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeAPIKey удаляет API ключ аккаунта, после чего запросы с ним не авторизуются
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err := h.service.RevokeAPIKey(req.Context(), user.UserID, chi.URLParam(req, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		case errors.Is(err, service.ErrAccountsNotSupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			logrus.Errorf("failed to revoke api key: %s", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON пишет в ответ статус и тело в json
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		logrus.Errorf("failed encode response to json: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logrus.Errorf("failed write response: %s", err)
+	}
+}
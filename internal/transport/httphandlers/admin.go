@@ -0,0 +1,163 @@
+package httphandlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// AdminURL возвращает администратору любую короткую ссылку с её владельцем и состоянием
+// права администратора проверяет сервис, остальным пользователям отвечает 403
+func (h *Handlers) AdminURL(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := h.service.URLInfo(req.Context(), user.UserID, chi.URLParam(req, "code"))
+	if err != nil {
+		h.adminError(w, err)
+		return
+	}
+
+	resp := struct {
+		Code        string     `json:"code"`
+		ShortURL    string     `json:"short_url"`
+		OriginalURL string     `json:"original_url"`
+		OwnerID     string     `json:"owner_id,omitempty"`
+		ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+		Deleted     bool       `json:"deleted"`
+		Blocked     bool       `json:"blocked"`
+		CreatedAt   time.Time  `json:"created_at"`
+	}{
+		Code:        info.Code,
+		ShortURL:    fmt.Sprintf("%s/%s", h.redirectBasePath, info.Code),
+		OriginalURL: info.OriginalURL,
+		OwnerID:     info.OwnerID,
+		Deleted:     info.Deleted,
+		Blocked:     info.Blocked,
+		CreatedAt:   info.CreatedAt,
+	}
+	if !info.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &info.ExpiresAt
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// AdminDeleteURL удаляет короткую ссылку любого пользователя, автором удаления в истории становится администратор
+// в отличие от Delete ссылка удаляется синхронно
+func (h *Handlers) AdminDeleteURL(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.ForceDeleteURL(req.Context(), user.UserID, chi.URLParam(req, "code")); err != nil {
+		h.adminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminBlockURL блокирует короткую ссылку, по заблокированной ссылке редирект отвечает 451
+func (h *Handlers) AdminBlockURL(w http.ResponseWriter, req *http.Request) {
+	h.blockURL(w, req, true)
+}
+
+// AdminUnblockURL снимает блокировку с короткой ссылки
+func (h *Handlers) AdminUnblockURL(w http.ResponseWriter, req *http.Request) {
+	h.blockURL(w, req, false)
+}
+
+func (h *Handlers) blockURL(w http.ResponseWriter, req *http.Request, blocked bool) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.BlockURL(req.Context(), user.UserID, chi.URLParam(req, "code"), blocked); err != nil {
+		h.adminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminUserURLs возвращает администратору страницу ссылок любого пользователя
+// параметры страницы такие же, как у UsersURLs
+func (h *Handlers) AdminUserURLs(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := req.URL.Query()
+	q, err := parseUsersURLsQuery(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.UserURLs(req.Context(), user.UserID, chi.URLParam(req, "id"), q)
+	if err != nil {
+		h.adminError(w, err)
+		return
+	}
+
+	h.writeUsersURLs(w, req, params, page)
+}
+
+// AdminBanUser блокирует пользователя, на его запросы сервис отвечает 403
+// администраторов заблокировать нельзя
+func (h *Handlers) AdminBanUser(w http.ResponseWriter, req *http.Request) {
+	h.banUser(w, req, true)
+}
+
+// AdminUnbanUser снимает блокировку с пользователя
+func (h *Handlers) AdminUnbanUser(w http.ResponseWriter, req *http.Request) {
+	h.banUser(w, req, false)
+}
+
+func (h *Handlers) banUser(w http.ResponseWriter, req *http.Request, banned bool) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.BanUser(req.Context(), user.UserID, chi.URLParam(req, "id"), banned); err != nil {
+		h.adminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) adminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	case errors.Is(err, service.ErrNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidUsersURLsQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrModerationNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		logrus.Errorf("failed to moderate: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
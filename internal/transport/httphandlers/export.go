@@ -0,0 +1,191 @@
+package httphandlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// exportURL ссылка в выгрузке
+type exportURL struct {
+	Code        string     `json:"code"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Deleted     bool       `json:"deleted"`
+}
+
+// exportEncoder пишет ссылки в ответ в одном из форматов выгрузки
+type exportEncoder interface {
+	begin() error
+	encode(u exportURL) error
+	end() error
+}
+
+// exportFormats содержит тип содержимого и конструктор кодировщика для каждого формата выгрузки
+var exportFormats = map[string]struct {
+	contentType string
+	newEncoder  func(w io.Writer) exportEncoder
+}{
+	"csv":    {contentType: "text/csv", newEncoder: newCSVExportEncoder},
+	"ndjson": {contentType: "application/x-ndjson", newEncoder: newNDJSONExportEncoder},
+	"json":   {contentType: "application/json", newEncoder: newJSONExportEncoder},
+}
+
+// ExportURLs выгружает все ссылки пользователя в формате csv, ndjson или json (по умолчанию)
+// ссылки пишутся в ответ по мере чтения из хранилища, не накапливаясь в памяти
+func (h *Handlers) ExportURLs(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	f, ok := exportFormats[format]
+	if !ok {
+		http.Error(w, "format must be one of csv, ndjson, json", http.StatusBadRequest)
+		return
+	}
+
+	enc := f.newEncoder(w)
+
+	started := false
+	start := func() error {
+		started = true
+
+		w.Header().Add("Content-Type", f.contentType)
+		w.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="urls.%s"`, format))
+		w.WriteHeader(http.StatusOK)
+
+		return enc.begin()
+	}
+
+	err := h.service.ExportUsersURLs(req.Context(), user.UserID, func(u service.UsersURL) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		item := exportURL{
+			Code:        u.Code,
+			ShortURL:    fmt.Sprintf("%s/%s", h.redirectBasePath, u.Code),
+			OriginalURL: u.OriginalURL,
+			Deleted:     u.Deleted,
+		}
+		if !u.ExpiresAt.IsZero() {
+			item.ExpiresAt = &u.ExpiresAt
+		}
+
+		return enc.encode(item)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end()
+	}
+
+	if err != nil {
+		logrus.Errorf("failed to export users urls: %s", err)
+		if !started {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// ответ уже начат, поэтому соединение обрывается, чтобы клиент не принял обрезанный файл за полный
+		panic(http.ErrAbortHandler)
+	}
+}
+
+type csvExportEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVExportEncoder(w io.Writer) exportEncoder {
+	return &csvExportEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvExportEncoder) begin() error {
+	return e.w.Write([]string{"code", "short_url", "original_url", "expires_at", "deleted"})
+}
+
+func (e *csvExportEncoder) encode(u exportURL) error {
+	expiresAt := ""
+	if u.ExpiresAt != nil {
+		expiresAt = u.ExpiresAt.Format(time.RFC3339)
+	}
+
+	return e.w.Write([]string{u.Code, u.ShortURL, u.OriginalURL, expiresAt, strconv.FormatBool(u.Deleted)})
+}
+
+func (e *csvExportEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExportEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONExportEncoder(w io.Writer) exportEncoder {
+	return &ndjsonExportEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *ndjsonExportEncoder) begin() error {
+	return nil
+}
+
+func (e *ndjsonExportEncoder) encode(u exportURL) error {
+	return e.enc.Encode(u)
+}
+
+func (e *ndjsonExportEncoder) end() error {
+	return nil
+}
+
+// jsonExportEncoder пишет массив по одному элементу
+type jsonExportEncoder struct {
+	w     io.Writer
+	enc   *json.Encoder
+	first bool
+}
+
+func newJSONExportEncoder(w io.Writer) exportEncoder {
+	return &jsonExportEncoder{w: w, enc: json.NewEncoder(w), first: true}
+}
+
+func (e *jsonExportEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExportEncoder) encode(u exportURL) error {
+	if !e.first {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.first = false
+
+	return e.enc.Encode(u)
+}
+
+func (e *jsonExportEncoder) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
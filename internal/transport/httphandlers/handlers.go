@@ -9,14 +9,26 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
 	"github.com/lks-go/url-shortener/internal/service"
 )
 
+// codePathRegexp извлекает код короткой ссылки из пути запроса
+var codePathRegexp = regexp.MustCompile(`^/([^/]+)`)
+
+// defaultStatsLimit количество последних переходов по ссылке в ответе статистики по умолчанию
+const defaultStatsLimit = 100
+
 // Config общий конфиг пакета
 type Config struct {
 	RedirectBasePath string
@@ -25,11 +37,29 @@ type Config struct {
 
 // Service это интерфейс сервиса отвечающего за обратоку входящих http запросов
 type Service interface {
-	MakeBatchShortURL(ctx context.Context, userID string, urls []service.URL) ([]service.URL, error)
-	MakeShortURL(ctx context.Context, userID, url string) (string, error)
+	MakeBatchShortURL(ctx context.Context, userID string, urls []service.URL) ([]service.BatchResult, error)
+	MakeShortURL(ctx context.Context, userID, url string, opts service.ShortenOptions) (string, error)
 	URL(ctx context.Context, id string) (string, error)
-	UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error)
+	UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error)
+	ExportUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error
+	UpdateURL(ctx context.Context, userID, code, url string) (string, error)
+	URLHistory(ctx context.Context, userID, code string) ([]service.Revision, error)
+	RestoreURL(ctx context.Context, userID, code string, revisionID int64) error
 	Stats(ctx context.Context) (*service.StatsInfo, error)
+	URLStats(ctx context.Context, userID, code string, limit int) (*service.URLStats, error)
+	ClickStats(ctx context.Context, userID string, q service.ClickStatsQuery) (*service.ClickStats, error)
+	ImportURLs(ctx context.Context, userID string, r service.ImportReader, checkpoint int64, progress func(service.ImportProgress) error) (service.ImportProgress, error)
+	SignUp(ctx context.Context, login, password string) (*service.Account, error)
+	Login(ctx context.Context, login, password string) (*service.Account, error)
+	ClaimURLs(ctx context.Context, anonymousID, accountID string) (int, error)
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (string, *service.APIKey, error)
+	APIKeys(ctx context.Context, userID string) ([]service.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	URLInfo(ctx context.Context, adminID, code string) (*service.URLInfo, error)
+	ForceDeleteURL(ctx context.Context, adminID, code string) error
+	BlockURL(ctx context.Context, adminID, code string, blocked bool) error
+	UserURLs(ctx context.Context, adminID, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error)
+	BanUser(ctx context.Context, adminID, userID string, banned bool) error
 }
 
 // Deleter это интерфейс сервиса отвечающего за получение запроса на удаление
@@ -37,10 +67,30 @@ type Deleter interface {
 	Delete(ctx context.Context, userID string, codes []string) error
 }
 
+// ClickRecorder это интерфейс сервиса отвечающего за асинхронную запись переходов по ссылкам
+type ClickRecorder interface {
+	Record(click service.Click) error
+}
+
+// TokenBuilder это интерфейс выпуска jwt для cookie пользователя после входа в аккаунт
+type TokenBuilder interface {
+	BuildNewJWTToken(userID string) (string, error)
+}
+
+// OIDCProvider это интерфейс входа через OIDC провайдер
+type OIDCProvider interface {
+	AuthCodeURL(state, nonce string) string
+	Exchange(ctx context.Context, code string) (*oidc.IDToken, error)
+}
+
 // Dependencies основные зависимости
+// OIDCProvider может быть nil, тогда вход через OIDC не поддерживается
 type Dependencies struct {
 	Service
 	Deleter
+	ClickRecorder
+	TokenBuilder
+	OIDCProvider
 }
 
 // New is a constructor of *Handlers
@@ -59,6 +109,9 @@ func New(cfg Config, deps Dependencies) (*Handlers, error) {
 		redirectBasePath: strings.TrimRight(cfg.RedirectBasePath, "/"),
 		service:          deps.Service,
 		deleter:          deps.Deleter,
+		clickRecorder:    deps.ClickRecorder,
+		tokenBuilder:     deps.TokenBuilder,
+		oidc:             deps.OIDCProvider,
 		ipNet:            ipNet,
 	}, nil
 }
@@ -68,6 +121,9 @@ type Handlers struct {
 	redirectBasePath string
 	service          Service
 	deleter          Deleter
+	clickRecorder    ClickRecorder
+	tokenBuilder     TokenBuilder
+	oidc             OIDCProvider
 	ipNet            *net.IPNet
 }
 
@@ -82,8 +138,8 @@ func (h *Handlers) ShortURL(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userID, ok := req.Header["User-Id"]
-	if !ok || len(userID) == 0 {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -94,7 +150,7 @@ func (h *Handlers) ShortURL(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	id, err := h.service.MakeShortURL(req.Context(), userID[0], string(b))
+	id, err := h.service.MakeShortURL(req.Context(), user.UserID, string(b), service.ShortenOptions{})
 	if err != nil && !errors.Is(err, service.ErrURLAlreadyExists) {
 		logrus.Errorf("failed to make short url: %s", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -115,6 +171,8 @@ func (h *Handlers) ShortURL(w http.ResponseWriter, req *http.Request) {
 // Redirect запрашивает в сервисе оригинальный урл по короткой ссылке
 // и если такой урл есть, то возвращает клиенту http код ответа 307
 // и оригинальный урл в заголовке Location
+// переход записывается в статистику асинхронно и не задерживает ответ
+// на заблокированную администратором ссылку отвечает 451
 func (h *Handlers) Redirect(w http.ResponseWriter, req *http.Request) {
 	if http.MethodGet != req.Method {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -125,7 +183,7 @@ func (h *Handlers) Redirect(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	matches := regexp.MustCompile(`/(\w+)`).FindStringSubmatch(req.URL.Path)
+	matches := codePathRegexp.FindStringSubmatch(req.URL.Path)
 	if len(matches) < 1 {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -141,8 +199,10 @@ func (h *Handlers) Redirect(w http.ResponseWriter, req *http.Request) {
 			if err != nil {
 				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			}
-		case errors.Is(err, service.ErrDeleted):
+		case errors.Is(err, service.ErrDeleted), errors.Is(err, service.ErrExpired):
 			w.WriteHeader(http.StatusGone)
+		case errors.Is(err, service.ErrBlocked):
+			w.WriteHeader(http.StatusUnavailableForLegalReasons)
 		default:
 			logrus.Errorf("failed to get url by code [%s]: %s", code, err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -150,27 +210,252 @@ func (h *Handlers) Redirect(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	click := service.Click{
+		Code:      code,
+		Time:      time.Now(),
+		Referrer:  req.Referer(),
+		UserAgent: req.UserAgent(),
+		IP:        clientIP(req),
+	}
+	if err := h.clickRecorder.Record(click); err != nil {
+		logrus.Warnf("failed to record click of code [%s]: %s", code, err)
+	}
+
 	w.Header().Set("Location", url)
 	w.WriteHeader(http.StatusTemporaryRedirect)
 }
 
+// URLStats возвращает количество переходов по ссылке пользователя и последние переходы
+// количество переходов в ответе ограничивается параметром запроса limit
+func (h *Handlers) URLStats(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultStatsLimit
+	if l := req.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	code := chi.URLParam(req, "code")
+	stats, err := h.service.URLStats(req.Context(), user.UserID, code, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		default:
+			logrus.Errorf("failed to get stats of code [%s]: %s", code, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	type respEvent struct {
+		Time      time.Time `json:"time"`
+		Referrer  string    `json:"referrer"`
+		UserAgent string    `json:"user_agent"`
+		IP        string    `json:"ip"`
+	}
+
+	resp := struct {
+		Code   string      `json:"code"`
+		Clicks int         `json:"clicks"`
+		Events []respEvent `json:"events"`
+	}{
+		Code:   stats.Code,
+		Clicks: stats.Clicks,
+		Events: make([]respEvent, 0, len(stats.Events)),
+	}
+
+	for _, e := range stats.Events {
+		resp.Events = append(resp.Events, respEvent{
+			Time:      e.Time,
+			Referrer:  e.Referrer,
+			UserAgent: e.UserAgent,
+			IP:        e.IP,
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(resp); err != nil {
+		logrus.Errorf("failed encode response to json: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logrus.Errorf("failed write response: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// ClickStats возвращает агрегированную статистику переходов по ссылке пользователя
+// переходы группируются по интервалу (hour или day) в диапазоне from - to (RFC3339),
+// а также по доменам источников, браузерам и операционным системам, ограниченным параметром top
+func (h *Handlers) ClickStats(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	q, err := clickStatsQuery(req)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.ClickStats(req.Context(), user.UserID, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStatsQuery):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrNotFound):
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		default:
+			logrus.Errorf("failed to get click stats of code [%s]: %s", q.Code, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	type respBucket struct {
+		Time   time.Time `json:"time"`
+		Clicks int       `json:"clicks"`
+	}
+
+	type respGroup struct {
+		Name   string `json:"name"`
+		Clicks int    `json:"clicks"`
+	}
+
+	groups := func(list []service.ClickGroup) []respGroup {
+		res := make([]respGroup, 0, len(list))
+		for _, g := range list {
+			res = append(res, respGroup{Name: g.Name, Clicks: g.Clicks})
+		}
+		return res
+	}
+
+	resp := struct {
+		Code           string       `json:"code"`
+		Clicks         int          `json:"clicks"`
+		UniqueVisitors int          `json:"unique_visitors"`
+		Buckets        []respBucket `json:"buckets"`
+		Referrers      []respGroup  `json:"referrers"`
+		Browsers       []respGroup  `json:"browsers"`
+		OS             []respGroup  `json:"os"`
+	}{
+		Code:           stats.Code,
+		Clicks:         stats.Clicks,
+		UniqueVisitors: stats.UniqueVisitors,
+		Buckets:        make([]respBucket, 0, len(stats.Buckets)),
+		Referrers:      groups(stats.Referrers),
+		Browsers:       groups(stats.Browsers),
+		OS:             groups(stats.OS),
+	}
+
+	for _, b := range stats.Buckets {
+		resp.Buckets = append(resp.Buckets, respBucket{Time: b.Time, Clicks: b.Clicks})
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(resp); err != nil {
+		logrus.Errorf("failed encode response to json: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logrus.Errorf("failed write response: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// clickStatsQuery собирает параметры запроса агрегированной статистики
+func clickStatsQuery(req *http.Request) (service.ClickStatsQuery, error) {
+	params := req.URL.Query()
+	q := service.ClickStatsQuery{
+		Code:     chi.URLParam(req, "code"),
+		Interval: params.Get("interval"),
+	}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		if q.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return q, fmt.Errorf("failed to parse from: %w", err)
+		}
+	}
+
+	if to := params.Get("to"); to != "" {
+		if q.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return q, fmt.Errorf("failed to parse to: %w", err)
+		}
+	}
+
+	if top := params.Get("top"); top != "" {
+		if q.Top, err = strconv.Atoi(top); err != nil || q.Top <= 0 {
+			return q, fmt.Errorf("invalid top: %s", top)
+		}
+	}
+
+	return q, nil
+}
+
+// clientIP возвращает IP адрес клиента, определённый middleware с учётом доверенных прокси
+// без middleware используется адрес соединения, заголовки клиента не учитываются
+func clientIP(req *http.Request) string {
+	if ip, ok := entity.ClientIPFromContext(req.Context()); ok {
+		return ip.String()
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
 // ShortenBatchURL возвращает короткие ссылки пачку урлов
 // тело запроса должно содержать массив объектов
+// необязательные поля expires_at (RFC 3339) и ttl (в секундах) ограничивают время жизни ссылки
+// для каждого correlation_id возвращается статус: created, exists (с уже существующей короткой ссылкой) или invalid (с причиной)
+// если все ссылки созданы, отвечает 201, иначе 207
 //
 //	Пример:
 //	 [
-//			{"correlation_id": "example_id", "original_url": "https://ya.ru"}
+//			{"correlation_id": "example_id", "original_url": "https://ya.ru", "ttl": 3600}
 //	 ]
 func (h *Handlers) ShortenBatchURL(w http.ResponseWriter, req *http.Request) {
-	userID, ok := req.Header["User-Id"]
-	if !ok || len(userID) == 0 {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	type url struct {
-		CorrelationID string `json:"correlation_id"`
-		OriginalURL   string `json:"original_url"`
+		CorrelationID string    `json:"correlation_id"`
+		OriginalURL   string    `json:"original_url"`
+		ExpiresAt     time.Time `json:"expires_at"`
+		TTL           int64     `json:"ttl"`
 	}
 
 	body := make([]url, 0)
@@ -184,10 +469,12 @@ func (h *Handlers) ShortenBatchURL(w http.ResponseWriter, req *http.Request) {
 		urlList = append(urlList, service.URL{
 			СorrelationID: u.CorrelationID,
 			OriginalURL:   u.OriginalURL,
+			ExpiresAt:     u.ExpiresAt,
+			TTL:           time.Duration(u.TTL) * time.Second,
 		})
 	}
 
-	shortURLList, err := h.service.MakeBatchShortURL(req.Context(), userID[0], urlList)
+	results, err := h.service.MakeBatchShortURL(req.Context(), user.UserID, urlList)
 	if err != nil {
 		logrus.Errorf("failed to make batch short urls: %s", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -196,15 +483,28 @@ func (h *Handlers) ShortenBatchURL(w http.ResponseWriter, req *http.Request) {
 
 	type respURL struct {
 		CorrelationID string `json:"correlation_id"`
-		ShortURL      string `json:"short_url"`
-	}
+		ShortURL      string `json:"short_url,omitempty"`
+		Status        string `json:"status"`
+		Reason        string `json:"reason,omitempty"`
+	}
+
+	statusCode := http.StatusCreated
+	resp := make([]respURL, 0, len(results))
+	for _, r := range results {
+		item := respURL{
+			CorrelationID: r.СorrelationID,
+			Status:        r.Status,
+			Reason:        r.Reason,
+		}
+		if r.Code != "" {
+			item.ShortURL = fmt.Sprintf("%s/%s", h.redirectBasePath, r.Code)
+		}
 
-	resp := make([]respURL, 0, len(shortURLList))
-	for _, u := range shortURLList {
-		resp = append(resp, respURL{
-			CorrelationID: u.СorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", h.redirectBasePath, u.Code),
-		})
+		if r.Status != service.BatchStatusCreated {
+			statusCode = http.StatusMultiStatus
+		}
+
+		resp = append(resp, item)
 	}
 
 	buf := new(bytes.Buffer)
@@ -214,7 +514,7 @@ func (h *Handlers) ShortenBatchURL(w http.ResponseWriter, req *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(statusCode)
 	_, err = w.Write(buf.Bytes())
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -222,6 +522,8 @@ func (h *Handlers) ShortenBatchURL(w http.ResponseWriter, req *http.Request) {
 }
 
 // ShortenURL создает короткую ссылку для урла
+// необязательное поле alias задает собственный код ссылки
+// необязательные поля expires_at (RFC 3339) и ttl (в секундах) ограничивают время жизни ссылки
 func (h *Handlers) ShortenURL(w http.ResponseWriter, req *http.Request) {
 	if http.MethodPost != req.Method {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -232,14 +534,17 @@ func (h *Handlers) ShortenURL(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userID, ok := req.Header["User-Id"]
-	if !ok || len(userID) == 0 {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	body := struct {
-		URL string `json:"url"`
+		URL       string    `json:"url"`
+		Alias     string    `json:"alias"`
+		ExpiresAt time.Time `json:"expires_at"`
+		TTL       int64     `json:"ttl"`
 	}{}
 
 	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
@@ -249,12 +554,24 @@ func (h *Handlers) ShortenURL(w http.ResponseWriter, req *http.Request) {
 
 	isConflict := false
 
-	code, err := h.service.MakeShortURL(req.Context(), userID[0], body.URL)
+	opts := service.ShortenOptions{
+		Alias:     body.Alias,
+		ExpiresAt: body.ExpiresAt,
+		TTL:       time.Duration(body.TTL) * time.Second,
+	}
+
+	code, err := h.service.MakeShortURL(req.Context(), user.UserID, body.URL, opts)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrURLAlreadyExists):
 			logrus.Warnf("url [%s] already exists: %s", body.URL, err)
 			isConflict = true
+		case errors.Is(err, service.ErrInvalidAlias), errors.Is(err, service.ErrInvalidExpiration):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrAliasTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
 		default:
 			logrus.Errorf("failed to make short url: %s", err)
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -281,31 +598,88 @@ func (h *Handlers) ShortenURL(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// UsersURLs возращает списко ссылок, добавленных для пользователя
+// UsersURLs возвращает страницу ссылок, добавленных пользователем
+// параметры: limit размер страницы, cursor из ссылки на следующую страницу, sort created или clicks,
+// order asc или desc, q подстрока для поиска по исходному URL
+// ссылка на следующую страницу передаётся в заголовке Link с rel="next"
 func (h *Handlers) UsersURLs(w http.ResponseWriter, req *http.Request) {
-	userID, ok := req.Header["User-Id"]
-	if !ok || len(userID) == 0 {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	urls, err := h.service.UsersURLs(req.Context(), userID[0])
+	params := req.URL.Query()
+	q, err := parseUsersURLsQuery(params)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.UsersURLs(req.Context(), user.UserID, q)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUsersURLsQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		logrus.Errorf("failed to get users urls: %s", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
+	h.writeUsersURLs(w, req, params, page)
+}
+
+// parseUsersURLsQuery читает параметры страницы ссылок пользователя: q, sort, order, limit и cursor
+func parseUsersURLsQuery(params url.Values) (service.UsersURLsQuery, error) {
+	q := service.UsersURLsQuery{
+		Search: params.Get("q"),
+		Sort:   params.Get("sort"),
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return service.UsersURLsQuery{}, errors.New("order must be asc or desc")
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return service.UsersURLsQuery{}, errors.New("limit must be a positive integer")
+		}
+		q.Limit = limit
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		cursor, err := service.ParseURLsCursor(v)
+		if err != nil {
+			return service.UsersURLsQuery{}, err
+		}
+		q.After = cursor
+	}
+
+	return q, nil
+}
+
+// writeUsersURLs отвечает страницей ссылок пользователя
+// ссылка на следующую страницу передается в заголовке Link
+func (h *Handlers) writeUsersURLs(w http.ResponseWriter, req *http.Request, params url.Values, page *service.UsersURLsPage) {
 	type respURL struct {
 		ShortURL    string `json:"short_url"`
 		OriginalURL string `json:"original_url"`
+		Clicks      int    `json:"clicks"`
 	}
 
-	resp := make([]respURL, 0, len(urls))
-	for _, u := range urls {
+	resp := make([]respURL, 0, len(page.URLs))
+	for _, u := range page.URLs {
 		resp = append(resp, respURL{
 			ShortURL:    fmt.Sprintf("%s/%s", h.redirectBasePath, u.Code),
 			OriginalURL: u.OriginalURL,
+			Clicks:      u.Clicks,
 		})
 	}
 
@@ -316,16 +690,79 @@ func (h *Handlers) UsersURLs(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if page.Next != nil {
+		params.Set("cursor", page.Next.String())
+		w.Header().Add("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, h.redirectBasePath, req.URL.Path, params.Encode()))
+	}
+
 	w.Header().Add("Content-Type", "application/json")
-	if len(resp) == 0 {
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logrus.Errorf("failed write response: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// UpdateURL меняет исходный URL короткой ссылки пользователя, сама короткая ссылка не меняется
+// в теле запроса передается новый URL в поле url
+// если новый URL уже сокращён, отвечает 409 с существующей короткой ссылкой
+func (h *Handlers) UpdateURL(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(buf.Bytes())
+	body := struct {
+		URL string `json:"url"`
+	}{}
+
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	isConflict := false
+
+	code, err := h.service.UpdateURL(req.Context(), user.UserID, chi.URLParam(req, "code"), body.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrURLAlreadyExists):
+			isConflict = true
+		case errors.Is(err, service.ErrInvalidURL):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrNotFound):
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		case errors.Is(err, service.ErrDeleted):
+			http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+			return
+		default:
+			logrus.Errorf("failed to update url: %s", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp, err := h.shortenURLResponse(code)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if isConflict {
+		w.WriteHeader(http.StatusConflict)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	_, err = w.Write(resp)
 	if err != nil {
-		logrus.Errorf("failed write response: %s", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 }
@@ -349,8 +786,8 @@ func (h *Handlers) shortenURLResponse(code string) ([]byte, error) {
 // в теле запроса передается список кодов коротких ссылок
 // хендлер не дожидается фактического удаления урлов и возвращает http код 202
 func (h *Handlers) Delete(w http.ResponseWriter, req *http.Request) {
-	userID, ok := req.Header["User-Id"]
-	if !ok || len(userID) == 0 {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -369,8 +806,8 @@ func (h *Handlers) Delete(w http.ResponseWriter, req *http.Request) {
 	}
 
 	go func() {
-		if err := h.deleter.Delete(context.Background(), userID[0], codes); err != nil {
-			logrus.Errorf("failed to delete urls (userId = %s, codes = [%v]): %s", userID, codes, err)
+		if err := h.deleter.Delete(context.Background(), user.UserID, codes); err != nil {
+			logrus.Errorf("failed to delete urls (userId = %s, codes = [%v]): %s", user.UserID, codes, err)
 		}
 	}()
 
@@ -379,8 +816,8 @@ func (h *Handlers) Delete(w http.ResponseWriter, req *http.Request) {
 
 // Stats возвращает количество сокращённых URL и количество пользователей в сервисе
 func (h *Handlers) Stats(w http.ResponseWriter, req *http.Request) {
-	ip := req.Header.Get("X-Real-IP")
-	if h.ipNet != nil && !h.ipNet.Contains(net.ParseIP(ip)) {
+	ip := net.ParseIP(clientIP(req))
+	if h.ipNet != nil && !h.ipNet.Contains(ip) {
 		logrus.Errorf("ip %s is not in trusted subnet", ip)
 		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
@@ -393,14 +830,29 @@ func (h *Handlers) Stats(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	type respCache struct {
+		Hits   uint64 `json:"hits"`
+		Misses uint64 `json:"misses"`
+		Size   int    `json:"size"`
+	}
+
 	resp := struct {
-		URLS  int `json:"urls"`
-		USERS int `json:"users"`
+		URLS  int        `json:"urls"`
+		USERS int        `json:"users"`
+		Cache *respCache `json:"cache,omitempty"`
 	}{
 		URLS:  statsInfo.URLCount,
 		USERS: statsInfo.UserCount,
 	}
 
+	if statsInfo.Cache != nil {
+		resp.Cache = &respCache{
+			Hits:   statsInfo.Cache.Hits,
+			Misses: statsInfo.Cache.Misses,
+			Size:   statsInfo.Cache.Size,
+		}
+	}
+
 	buf := new(bytes.Buffer)
 	if err := json.NewEncoder(buf).Encode(resp); err != nil {
 		logrus.Errorf("failed encode response to json: %s", err)
@@ -2,28 +2,54 @@ package httphandlers_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/lib/jwt"
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+	"github.com/lks-go/url-shortener/internal/lib/oidc/oidctest"
+	"github.com/lks-go/url-shortener/internal/lib/realip"
 	"github.com/lks-go/url-shortener/internal/service"
 	"github.com/lks-go/url-shortener/internal/transport/httphandlers"
 	"github.com/lks-go/url-shortener/internal/transport/httphandlers/mocks"
 	"github.com/lks-go/url-shortener/internal/transport/middleware"
 )
 
+// withAuth authenticates requests of the tests by a random key
+var withAuth = func() func(http.Handler) http.Handler {
+	key, err := jwt.GenerateKey("test")
+	if err != nil {
+		panic(err)
+	}
+
+	keyring, err := jwt.NewKeyring(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return middleware.WithAuth(keyring, nil, nil)
+}()
+
 func TestHandlers_Redirect(t *testing.T) {
 	serviceMock := mocks.NewService(t)
+	clickRecorderMock := mocks.NewClickRecorder(t)
 
 	deps := httphandlers.Dependencies{
-		Service: serviceMock,
+		Service:       serviceMock,
+		ClickRecorder: clickRecorderMock,
 	}
 	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "/"}, deps)
 	assert.NoError(t, err)
@@ -52,6 +78,9 @@ func TestHandlers_Redirect(t *testing.T) {
 			callMocks: func() {
 				serviceMock.On("URL", mock.Anything, "123456").
 					Return("https://ya.ru", nil).Once()
+				clickRecorderMock.On("Record", mock.MatchedBy(func(c service.Click) bool {
+					return c.Code == "123456"
+				})).Return(nil).Once()
 			},
 		},
 		{
@@ -68,6 +97,49 @@ func TestHandlers_Redirect(t *testing.T) {
 					Return("", service.ErrNotFound).Once()
 			},
 		},
+		{
+			name:         "expired",
+			method:       http.MethodGet,
+			target:       "/123458",
+			wantHTTPCode: http.StatusGone,
+			wantHeader: header{
+				key:   "Location",
+				value: "",
+			},
+			callMocks: func() {
+				serviceMock.On("URL", mock.Anything, "123458").
+					Return("", service.ErrExpired).Once()
+			},
+		},
+		{
+			name:         "blocked",
+			method:       http.MethodGet,
+			target:       "/123459",
+			wantHTTPCode: http.StatusUnavailableForLegalReasons,
+			wantHeader: header{
+				key:   "Location",
+				value: "",
+			},
+			callMocks: func() {
+				serviceMock.On("URL", mock.Anything, "123459").
+					Return("", service.ErrBlocked).Once()
+			},
+		},
+		{
+			name:         "redirect by alias",
+			method:       http.MethodGet,
+			target:       "/launch-2026",
+			wantHTTPCode: http.StatusTemporaryRedirect,
+			wantHeader: header{
+				key:   "Location",
+				value: "https://ya.ru/launch",
+			},
+			callMocks: func() {
+				serviceMock.On("URL", mock.Anything, "launch-2026").
+					Return("https://ya.ru/launch", nil).Once()
+				clickRecorderMock.On("Record", mock.Anything).Return(service.ErrClickRecorderFull).Once()
+			},
+		},
 		{
 			name:         "internal server error",
 			method:       http.MethodGet,
@@ -138,7 +210,7 @@ func TestHandlers_ShortURL(t *testing.T) {
 			wantHTTPCode: http.StatusCreated,
 			wantResp:     fmt.Sprintf("%s/%s", basePath, id),
 			callMocks: func() {
-				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru").Return(id, nil).Once()
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{}).Return(id, nil).Once()
 			},
 		},
 		{
@@ -159,7 +231,7 @@ func TestHandlers_ShortURL(t *testing.T) {
 			wantResp:     http.StatusText(http.StatusInternalServerError) + "\n",
 			callMocks: func() {
 				err := errors.New("any error")
-				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru").Return("", err).Once()
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{}).Return("", err).Once()
 			},
 		},
 	}
@@ -170,7 +242,7 @@ func TestHandlers_ShortURL(t *testing.T) {
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(tt.method, tt.target, tt.body)
 
-			hh := middleware.WithAuth(http.HandlerFunc(h.ShortURL))
+			hh := withAuth(http.HandlerFunc(h.ShortURL))
 			hh.ServeHTTP(w, r)
 
 			assert.Equal(t, tt.wantResp, w.Body.String())
@@ -208,7 +280,7 @@ func TestHandlers_ShortenURL(t *testing.T) {
 			wantHTTPCode: http.StatusCreated,
 			wantResp:     fmt.Sprintf("{\"result\":\"%s/%s\"}\n", basePath, id),
 			callMocks: func() {
-				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru").Return(id, nil).Once()
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{}).Return(id, nil).Once()
 			},
 		},
 		{
@@ -229,7 +301,43 @@ func TestHandlers_ShortenURL(t *testing.T) {
 			wantResp:     http.StatusText(http.StatusInternalServerError) + "\n",
 			callMocks: func() {
 				err := errors.New("any error")
-				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru").Return("", err).Once()
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{}).Return("", err).Once()
+			},
+		},
+		{
+			name:         "successful request with alias",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         bytes.NewReader([]byte(`{"url": "https://ya.ru", "alias": "launch-2026"}`)),
+			wantHTTPCode: http.StatusCreated,
+			wantResp:     fmt.Sprintf("{\"result\":\"%s/%s\"}\n", basePath, "launch-2026"),
+			callMocks: func() {
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{Alias: "launch-2026"}).
+					Return("launch-2026", nil).Once()
+			},
+		},
+		{
+			name:         "invalid alias",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         bytes.NewReader([]byte(`{"url": "https://ya.ru", "alias": "api"}`)),
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     service.ErrInvalidAlias.Error() + "\n",
+			callMocks: func() {
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{Alias: "api"}).
+					Return("", service.ErrInvalidAlias).Once()
+			},
+		},
+		{
+			name:         "alias taken",
+			method:       http.MethodPost,
+			target:       "/api/shorten",
+			body:         bytes.NewReader([]byte(`{"url": "https://ya.ru", "alias": "taken"}`)),
+			wantHTTPCode: http.StatusConflict,
+			wantResp:     service.ErrAliasTaken.Error() + "\n",
+			callMocks: func() {
+				serviceMock.On("MakeShortURL", mock.Anything, mock.Anything, "https://ya.ru", service.ShortenOptions{Alias: "taken"}).
+					Return("", service.ErrAliasTaken).Once()
 			},
 		},
 	}
@@ -240,7 +348,7 @@ func TestHandlers_ShortenURL(t *testing.T) {
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(tt.method, tt.target, tt.body)
 
-			hh := middleware.WithAuth(http.HandlerFunc(h.ShortenURL))
+			hh := withAuth(http.HandlerFunc(h.ShortenURL))
 			hh.ServeHTTP(w, r)
 
 			assert.Equal(t, tt.wantResp, w.Body.String())
@@ -249,89 +357,1558 @@ func TestHandlers_ShortenURL(t *testing.T) {
 	}
 }
 
-func TestHandlers_Stats(t *testing.T) {
+func TestHandlers_ShortenBatchURL(t *testing.T) {
 	basePath := "http://localhost:8080"
 	serviceMock := mocks.NewService(t)
 
 	deps := httphandlers.Dependencies{
 		Service: serviceMock,
 	}
-
-	cfg := httphandlers.Config{RedirectBasePath: basePath, TrustedSubnet: "248.133.71.0/24"}
-	h, err := httphandlers.New(cfg, deps)
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: basePath}, deps)
 	assert.NoError(t, err)
 
-	expectedRespBody := `{"urls": 23,"users": 10}`
 	tests := []struct {
 		name         string
-		ip           string
+		body         string
 		wantHTTPCode int
 		wantResp     string
 		callMocks    func()
 	}{
 		{
-			name:         "successful request",
-			ip:           "248.133.71.33",
-			wantHTTPCode: http.StatusOK,
-			wantResp:     expectedRespBody,
+			name:         "all created",
+			body:         `[{"correlation_id": "1", "original_url": "https://ya.ru"}]`,
+			wantHTTPCode: http.StatusCreated,
+			wantResp:     fmt.Sprintf("[{\"correlation_id\":\"1\",\"short_url\":\"%s/abc\",\"status\":\"created\"}]\n", basePath),
 			callMocks: func() {
-				serviceMock.On("Stats", mock.Anything).
-					Return(&service.StatsInfo{URLCount: 23, UserCount: 10}, nil).Once()
+				serviceMock.On("MakeBatchShortURL", mock.Anything, mock.Anything, []service.URL{{СorrelationID: "1", OriginalURL: "https://ya.ru"}}).
+					Return([]service.BatchResult{{СorrelationID: "1", OriginalURL: "https://ya.ru", Code: "abc", Status: service.BatchStatusCreated}}, nil).Once()
 			},
 		},
 		{
-			name:         "internal error",
-			ip:           "248.133.71.33",
+			name:         "partially created",
+			body:         `[{"correlation_id": "1", "original_url": "https://ya.ru"}, {"correlation_id": "2", "original_url": "bad"}]`,
+			wantHTTPCode: http.StatusMultiStatus,
+			wantResp: fmt.Sprintf("[{\"correlation_id\":\"1\",\"short_url\":\"%s/abc\",\"status\":\"exists\"},"+
+				"{\"correlation_id\":\"2\",\"status\":\"invalid\",\"reason\":\"invalid url\"}]\n", basePath),
+			callMocks: func() {
+				serviceMock.On("MakeBatchShortURL", mock.Anything, mock.Anything, mock.Anything).
+					Return([]service.BatchResult{
+						{СorrelationID: "1", OriginalURL: "https://ya.ru", Code: "abc", Status: service.BatchStatusExists},
+						{СorrelationID: "2", OriginalURL: "bad", Status: service.BatchStatusInvalid, Reason: "invalid url"},
+					}, nil).Once()
+			},
+		},
+		{
+			name:         "bad request",
+			body:         `{"correlation_id": "1"}`,
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     http.StatusText(http.StatusBadRequest) + "\n",
+			callMocks:    func() {},
+		},
+		{
+			name:         "internal server error",
+			body:         `[{"correlation_id": "1", "original_url": "https://ya.ru"}]`,
 			wantHTTPCode: http.StatusInternalServerError,
-			wantResp:     "",
+			wantResp:     http.StatusText(http.StatusInternalServerError) + "\n",
 			callMocks: func() {
-				serviceMock.On("Stats", mock.Anything).
+				serviceMock.On("MakeBatchShortURL", mock.Anything, mock.Anything, mock.Anything).
 					Return(nil, errors.New("any error")).Once()
 			},
 		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(tt.body))
+
+			hh := withAuth(http.HandlerFunc(h.ShortenBatchURL))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantResp, w.Body.String())
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+		})
+	}
+}
+
+func TestHandlers_ImportURLs(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, httphandlers.Dependencies{
+		Service: serviceMock,
+	})
+	assert.NoError(t, err)
+
+	// importRecords reads all records of the import and reports a single chunk
+	importRecords := func(records *[]service.URL) func(args mock.Arguments) {
+		return func(args mock.Arguments) {
+			r := args.Get(2).(service.ImportReader)
+			for {
+				u, err := r.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if errors.Is(err, service.ErrInvalidImportRecord) {
+					u = service.URL{OriginalURL: "rejected"}
+				}
+				*records = append(*records, u)
+			}
+
+			progress := args.Get(4).(func(service.ImportProgress) error)
+			progress(service.ImportProgress{
+				Checkpoint: 2,
+				Created:    1,
+				Invalid:    1,
+				Rejected:   []service.ImportRejected{{Record: 1, Reason: "invalid"}},
+			})
+		}
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		contentType  string
+		body         string
+		wantHTTPCode int
+		wantResp     string
+		wantRecords  []service.URL
+		checkpoint   int64
+		err          error
+	}{
+		{
+			name:         "ndjson",
+			target:       "/api/user/urls/import",
+			contentType:  "application/x-ndjson",
+			body:         "{\"correlation_id\": \"1\", \"original_url\": \"https://ya.ru\", \"ttl\": 60}\n\n{broken\n",
+			wantHTTPCode: http.StatusOK,
+			wantResp: "{\"checkpoint\":2,\"created\":1,\"exists\":0,\"invalid\":1,\"rejected\":[{\"record\":1,\"reason\":\"invalid\"}]}\n" +
+				"{\"checkpoint\":2,\"created\":1,\"exists\":0,\"invalid\":1,\"done\":true}\n",
+			wantRecords: []service.URL{
+				{СorrelationID: "1", OriginalURL: "https://ya.ru", TTL: time.Minute},
+				{OriginalURL: "rejected"},
+			},
+		},
 		{
-			name:         "forbidden",
-			ip:           "248.133.72.1",
-			wantHTTPCode: http.StatusForbidden,
-			wantResp:     "",
+			name:         "csv from checkpoint",
+			target:       "/api/user/urls/import?checkpoint=10",
+			contentType:  "text/csv; charset=utf-8",
+			body:         "original_url,correlation_id,comment\nhttps://ya.ru,1,first\nhttps://google.com\n",
+			wantHTTPCode: http.StatusOK,
+			wantResp: "{\"checkpoint\":2,\"created\":1,\"exists\":0,\"invalid\":1,\"rejected\":[{\"record\":1,\"reason\":\"invalid\"}]}\n" +
+				"{\"checkpoint\":2,\"created\":1,\"exists\":0,\"invalid\":1,\"done\":true}\n",
+			wantRecords: []service.URL{
+				{СorrelationID: "1", OriginalURL: "https://ya.ru"},
+				{OriginalURL: "https://google.com"},
+			},
+			checkpoint: 10,
+		},
+		{
+			name:         "interrupted",
+			target:       "/api/user/urls/import",
+			contentType:  "application/x-ndjson",
+			body:         "{\"original_url\": \"https://ya.ru\"}\n",
+			wantHTTPCode: http.StatusOK,
+			wantResp: "{\"checkpoint\":2,\"created\":1,\"exists\":0,\"invalid\":1,\"rejected\":[{\"record\":1,\"reason\":\"invalid\"}]}\n" +
+				"{\"checkpoint\":2,\"created\":1,\"exists\":0,\"invalid\":1,\"error\":\"import interrupted, resume from the checkpoint\"}\n",
+			wantRecords: []service.URL{{OriginalURL: "https://ya.ru"}},
+			err:         errors.New("any error"),
+		},
+		{
+			name:         "csv without original_url",
+			target:       "/api/user/urls/import",
+			contentType:  "text/csv",
+			body:         "url\nhttps://ya.ru\n",
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     "csv header must contain original_url column\n",
+		},
+		{
+			name:         "invalid checkpoint",
+			target:       "/api/user/urls/import?checkpoint=-1",
+			contentType:  "text/csv",
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     "checkpoint must be a non-negative integer\n",
+		},
+		{
+			name:         "unsupported media type",
+			target:       "/api/user/urls/import",
+			contentType:  "application/json",
+			body:         "[]",
+			wantHTTPCode: http.StatusUnsupportedMediaType,
+			wantResp:     http.StatusText(http.StatusUnsupportedMediaType) + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make([]service.URL, 0)
+			if tt.wantRecords != nil {
+				serviceMock.On("ImportURLs", mock.Anything, mock.Anything, mock.Anything, tt.checkpoint, mock.Anything).
+					Run(importRecords(&records)).
+					Return(service.ImportProgress{Checkpoint: 2, Created: 1, Invalid: 1}, tt.err).Once()
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			hh := withAuth(http.HandlerFunc(h.ImportURLs))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantResp, w.Body.String())
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantRecords != nil {
+				assert.Equal(t, tt.wantRecords, records)
+			}
+		})
+	}
+}
+
+func TestHandlers_UsersURLs(t *testing.T) {
+	basePath := "http://localhost:8080"
+	serviceMock := mocks.NewService(t)
+
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: basePath}, httphandlers.Dependencies{
+		Service: serviceMock,
+	})
+	assert.NoError(t, err)
+
+	next := &service.URLsCursor{Key: 3, Code: "abc"}
+
+	tests := []struct {
+		name         string
+		target       string
+		wantHTTPCode int
+		wantResp     string
+		wantLink     string
+		callMocks    func()
+	}{
+		{
+			name:         "empty list",
+			target:       "/api/user/urls",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     "[]\n",
+			callMocks: func() {
+				serviceMock.On("UsersURLs", mock.Anything, mock.Anything, service.UsersURLsQuery{}).
+					Return(&service.UsersURLsPage{}, nil).Once()
+			},
+		},
+		{
+			name:         "page with next",
+			target:       "/api/user/urls?limit=1&sort=clicks&order=desc&q=ya",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     fmt.Sprintf("[{\"short_url\":\"%s/abc\",\"original_url\":\"https://ya.ru\",\"clicks\":5}]\n", basePath),
+			wantLink:     fmt.Sprintf("<%s/api/user/urls?cursor=%s&limit=1&order=desc&q=ya&sort=clicks>; rel=\"next\"", basePath, next),
+			callMocks: func() {
+				q := service.UsersURLsQuery{Search: "ya", Sort: "clicks", Desc: true, Limit: 1}
+				serviceMock.On("UsersURLs", mock.Anything, mock.Anything, q).
+					Return(&service.UsersURLsPage{
+						URLs: []service.UsersURL{{Code: "abc", OriginalURL: "https://ya.ru", Clicks: 5}},
+						Next: next,
+					}, nil).Once()
+			},
+		},
+		{
+			name:         "next page",
+			target:       "/api/user/urls?cursor=" + next.String(),
+			wantHTTPCode: http.StatusOK,
+			wantResp:     "[]\n",
+			callMocks: func() {
+				serviceMock.On("UsersURLs", mock.Anything, mock.Anything, service.UsersURLsQuery{After: next}).
+					Return(&service.UsersURLsPage{}, nil).Once()
+			},
+		},
+		{
+			name:         "invalid cursor",
+			target:       "/api/user/urls?cursor=!!!",
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     "invalid users URLs query: malformed cursor\n",
 			callMocks:    func() {},
 		},
 		{
-			name:         "forbidden",
-			ip:           "248.134.71.5",
-			wantHTTPCode: http.StatusForbidden,
-			wantResp:     "",
+			name:         "invalid limit",
+			target:       "/api/user/urls?limit=0",
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     "limit must be a positive integer\n",
 			callMocks:    func() {},
 		},
+		{
+			name:         "invalid query",
+			target:       "/api/user/urls?sort=name",
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     service.ErrInvalidUsersURLsQuery.Error() + "\n",
+			callMocks: func() {
+				serviceMock.On("UsersURLs", mock.Anything, mock.Anything, service.UsersURLsQuery{Sort: "name"}).
+					Return(nil, service.ErrInvalidUsersURLsQuery).Once()
+			},
+		},
+		{
+			name:         "internal server error",
+			target:       "/api/user/urls",
+			wantHTTPCode: http.StatusInternalServerError,
+			wantResp:     http.StatusText(http.StatusInternalServerError) + "\n",
+			callMocks: func() {
+				serviceMock.On("UsersURLs", mock.Anything, mock.Anything, service.UsersURLsQuery{}).
+					Return(nil, errors.New("any error")).Once()
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.callMocks()
 
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			r.Header.Add("X-Real-IP", tt.ip)
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
 
-			hh := middleware.WithAuth(http.HandlerFunc(h.Stats))
+			hh := withAuth(http.HandlerFunc(h.UsersURLs))
 			hh.ServeHTTP(w, r)
 
-			if tt.wantResp != "" {
-				assert.JSONEq(t, tt.wantResp, w.Body.String())
-			}
+			assert.Equal(t, tt.wantResp, w.Body.String())
 			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			assert.Equal(t, tt.wantLink, w.Header().Get("Link"))
 		})
 	}
-
 }
 
-func TestHandlers_StatsTrustedSubnet(t *testing.T) {
+func TestHandlers_ExportURLs(t *testing.T) {
+	basePath := "http://localhost:8080"
+	serviceMock := mocks.NewService(t)
 
-	ip := net.ParseIP("248.133.72.1")
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: basePath}, httphandlers.Dependencies{
+		Service: serviceMock,
+	})
+	assert.NoError(t, err)
 
-	_, ipNet, err := net.ParseCIDR("248.133.71.0/24")
-	if err != nil {
-		t.Fatal(err)
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	urls := []service.UsersURL{
+		{Code: "abc", OriginalURL: "https://ya.ru"},
+		{Code: "xyz", OriginalURL: "https://google.com", ExpiresAt: expiresAt, Deleted: true},
 	}
 
-	t.Log(ipNet.Contains(ip))
+	exportURLs := func(urls []service.UsersURL) func(args mock.Arguments) {
+		return func(args mock.Arguments) {
+			fn := args.Get(2).(func(service.UsersURL) error)
+			for _, u := range urls {
+				fn(u)
+			}
+		}
+	}
+
+	tests := []struct {
+		name            string
+		target          string
+		urls            []service.UsersURL
+		err             error
+		wantHTTPCode    int
+		wantContentType string
+		wantResp        string
+	}{
+		{
+			name:            "json by default",
+			target:          "/api/user/urls/export",
+			urls:            urls,
+			wantHTTPCode:    http.StatusOK,
+			wantContentType: "application/json",
+			wantResp: `[{"code":"abc","short_url":"http://localhost:8080/abc","original_url":"https://ya.ru","deleted":false}` + "\n" +
+				`,{"code":"xyz","short_url":"http://localhost:8080/xyz","original_url":"https://google.com","expires_at":"2026-01-02T03:04:05Z","deleted":true}` + "\n" +
+				"]\n",
+		},
+		{
+			name:            "empty json",
+			target:          "/api/user/urls/export?format=json",
+			wantHTTPCode:    http.StatusOK,
+			wantContentType: "application/json",
+			wantResp:        "[]\n",
+		},
+		{
+			name:            "ndjson",
+			target:          "/api/user/urls/export?format=ndjson",
+			urls:            urls[:1],
+			wantHTTPCode:    http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantResp:        `{"code":"abc","short_url":"http://localhost:8080/abc","original_url":"https://ya.ru","deleted":false}` + "\n",
+		},
+		{
+			name:            "csv",
+			target:          "/api/user/urls/export?format=csv",
+			urls:            urls,
+			wantHTTPCode:    http.StatusOK,
+			wantContentType: "text/csv",
+			wantResp: "code,short_url,original_url,expires_at,deleted\n" +
+				"abc,http://localhost:8080/abc,https://ya.ru,,false\n" +
+				"xyz,http://localhost:8080/xyz,https://google.com,2026-01-02T03:04:05Z,true\n",
+		},
+		{
+			name:         "unknown format",
+			target:       "/api/user/urls/export?format=xml",
+			wantHTTPCode: http.StatusBadRequest,
+			wantResp:     "format must be one of csv, ndjson, json\n",
+		},
+		{
+			name:            "storage error",
+			target:          "/api/user/urls/export",
+			err:             errors.New("any error"),
+			wantHTTPCode:    http.StatusInternalServerError,
+			wantContentType: "text/plain; charset=utf-8",
+			wantResp:        http.StatusText(http.StatusInternalServerError) + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantContentType != "" {
+				serviceMock.On("ExportUsersURLs", mock.Anything, mock.Anything, mock.Anything).
+					Run(exportURLs(tt.urls)).
+					Return(tt.err).Once()
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+
+			hh := withAuth(http.HandlerFunc(h.ExportURLs))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantResp, w.Body.String())
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandlers_Stats(t *testing.T) {
+	basePath := "http://localhost:8080"
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+
+	cfg := httphandlers.Config{RedirectBasePath: basePath, TrustedSubnet: "248.133.71.0/24"}
+	h, err := httphandlers.New(cfg, deps)
+	assert.NoError(t, err)
+
+	expectedRespBody := `{"urls": 23,"users": 10}`
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			remoteAddr:   "248.133.71.33:4321",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     expectedRespBody,
+			callMocks: func() {
+				serviceMock.On("Stats", mock.Anything).
+					Return(&service.StatsInfo{URLCount: 23, UserCount: 10}, nil).Once()
+			},
+		},
+		{
+			name:         "successful request with cache",
+			remoteAddr:   "248.133.71.33:4321",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"urls": 23,"users": 10,"cache": {"hits": 7,"misses": 3,"size": 2}}`,
+			callMocks: func() {
+				serviceMock.On("Stats", mock.Anything).
+					Return(&service.StatsInfo{
+						URLCount:  23,
+						UserCount: 10,
+						Cache:     &service.CacheStats{Hits: 7, Misses: 3, Size: 2},
+					}, nil).Once()
+			},
+		},
+		{
+			name:         "internal error",
+			remoteAddr:   "248.133.71.33:4321",
+			wantHTTPCode: http.StatusInternalServerError,
+			wantResp:     "",
+			callMocks: func() {
+				serviceMock.On("Stats", mock.Anything).
+					Return(nil, errors.New("any error")).Once()
+			},
+		},
+		{
+			name:         "forbidden",
+			remoteAddr:   "248.133.72.1:4321",
+			wantHTTPCode: http.StatusForbidden,
+			wantResp:     "",
+			callMocks:    func() {},
+		},
+		{
+			name:         "forbidden",
+			remoteAddr:   "248.134.71.5:4321",
+			wantHTTPCode: http.StatusForbidden,
+			wantResp:     "",
+			callMocks:    func() {},
+		},
+		{
+			name:         "client behind trusted proxy",
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: "248.133.71.33",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     expectedRespBody,
+			callMocks: func() {
+				serviceMock.On("Stats", mock.Anything).
+					Return(&service.StatsInfo{URLCount: 23, UserCount: 10}, nil).Once()
+			},
+		},
+		{
+			name:         "client spoofs forwarded ip",
+			remoteAddr:   "248.134.71.5:4321",
+			forwardedFor: "248.133.71.33",
+			wantHTTPCode: http.StatusForbidden,
+			callMocks:    func() {},
+		},
+		{
+			name:         "client spoofs forwarded ip behind trusted proxy",
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: "248.133.71.33, 248.134.71.5",
+			wantHTTPCode: http.StatusForbidden,
+			callMocks:    func() {},
+		},
+	}
+	resolver, err := realip.NewResolver([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			// X-Real-IP of the client is ignored
+			r.Header.Set("X-Real-IP", "248.133.71.33")
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			hh := middleware.WithRealIP(resolver)(withAuth(http.HandlerFunc(h.Stats)))
+			hh.ServeHTTP(w, r)
+
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+		})
+	}
+
+}
+
+func TestHandlers_URLStats(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	clickTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		target       string
+		code         string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			target:       "/api/user/urls/abc/stats",
+			code:         "abc",
+			wantHTTPCode: http.StatusOK,
+			wantResp: `{"code":"abc","clicks":1,"events":[
+				{"time":"2026-01-02T03:04:05Z","referrer":"https://google.com","user_agent":"curl/8.0","ip":"10.0.0.1"}
+			]}`,
+			callMocks: func() {
+				serviceMock.On("URLStats", mock.Anything, mock.Anything, "abc", 100).
+					Return(&service.URLStats{Code: "abc", Clicks: 1, Events: []service.Click{
+						{Code: "abc", Time: clickTime, Referrer: "https://google.com", UserAgent: "curl/8.0", IP: "10.0.0.1"},
+					}}, nil).Once()
+			},
+		},
+		{
+			name:         "custom limit",
+			target:       "/api/user/urls/abc/stats?limit=5",
+			code:         "abc",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"code":"abc","clicks":0,"events":[]}`,
+			callMocks: func() {
+				serviceMock.On("URLStats", mock.Anything, mock.Anything, "abc", 5).
+					Return(&service.URLStats{Code: "abc"}, nil).Once()
+			},
+		},
+		{
+			name:         "invalid limit",
+			target:       "/api/user/urls/abc/stats?limit=x",
+			code:         "abc",
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+		{
+			name:         "not owner",
+			target:       "/api/user/urls/abc/stats",
+			code:         "abc",
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("URLStats", mock.Anything, mock.Anything, "abc", 100).
+					Return(nil, service.ErrForbidden).Once()
+			},
+		},
+		{
+			name:         "not found",
+			target:       "/api/user/urls/xyz/stats",
+			code:         "xyz",
+			wantHTTPCode: http.StatusNotFound,
+			callMocks: func() {
+				serviceMock.On("URLStats", mock.Anything, mock.Anything, "xyz", 100).
+					Return(nil, service.ErrNotFound).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("code", tt.code)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			hh := withAuth(http.HandlerFunc(h.URLStats))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_UpdateURL(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		code         string
+		body         string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			code:         "abc",
+			body:         `{"url":"https://ya.com"}`,
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"result":"http://localhost:8080/abc"}`,
+			callMocks: func() {
+				serviceMock.On("UpdateURL", mock.Anything, mock.Anything, "abc", "https://ya.com").Return("abc", nil).Once()
+			},
+		},
+		{
+			name:         "URL is shortened with another code",
+			code:         "abc",
+			body:         `{"url":"https://google.com"}`,
+			wantHTTPCode: http.StatusConflict,
+			wantResp:     `{"result":"http://localhost:8080/xyz"}`,
+			callMocks: func() {
+				serviceMock.On("UpdateURL", mock.Anything, mock.Anything, "abc", "https://google.com").
+					Return("xyz", service.ErrURLAlreadyExists).Once()
+			},
+		},
+		{
+			name:         "malformed body",
+			code:         "abc",
+			body:         `https://ya.com`,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+		{
+			name:         "invalid URL",
+			code:         "abc",
+			body:         `{"url":"ya.com"}`,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks: func() {
+				serviceMock.On("UpdateURL", mock.Anything, mock.Anything, "abc", "ya.com").Return("", service.ErrInvalidURL).Once()
+			},
+		},
+		{
+			name:         "not owner",
+			code:         "abc",
+			body:         `{"url":"https://ya.com"}`,
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("UpdateURL", mock.Anything, mock.Anything, "abc", "https://ya.com").Return("", service.ErrForbidden).Once()
+			},
+		},
+		{
+			name:         "not found",
+			code:         "xyz",
+			body:         `{"url":"https://ya.com"}`,
+			wantHTTPCode: http.StatusNotFound,
+			callMocks: func() {
+				serviceMock.On("UpdateURL", mock.Anything, mock.Anything, "xyz", "https://ya.com").Return("", service.ErrNotFound).Once()
+			},
+		},
+		{
+			name:         "deleted",
+			code:         "old",
+			body:         `{"url":"https://ya.com"}`,
+			wantHTTPCode: http.StatusGone,
+			callMocks: func() {
+				serviceMock.On("UpdateURL", mock.Anything, mock.Anything, "old", "https://ya.com").Return("", service.ErrDeleted).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+tt.code, strings.NewReader(tt.body))
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("code", tt.code)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			hh := withAuth(http.HandlerFunc(h.UpdateURL))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_URLHistory(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		code         string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			code:         "abc",
+			wantHTTPCode: http.StatusOK,
+			wantResp: `[
+				{"id":1,"action":"created","url":"https://ya.ru","user_id":"owner","time":"2024-01-01T00:00:00Z"},
+				{"id":2,"action":"expired","url":"https://ya.ru","previous_url":"https://ya.ru","time":"2024-01-01T00:01:00Z"}
+			]`,
+			callMocks: func() {
+				serviceMock.On("URLHistory", mock.Anything, mock.Anything, "abc").Return([]service.Revision{
+					{ID: 1, Code: "abc", Action: service.RevisionCreated, URL: "https://ya.ru", UserID: "owner", Time: created},
+					{ID: 2, Code: "abc", Action: service.RevisionExpired, URL: "https://ya.ru", PreviousURL: "https://ya.ru", Time: created.Add(time.Minute)},
+				}, nil).Once()
+			},
+		},
+		{
+			name:         "not owner",
+			code:         "abc",
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("URLHistory", mock.Anything, mock.Anything, "abc").Return(nil, service.ErrForbidden).Once()
+			},
+		},
+		{
+			name:         "not found",
+			code:         "xyz",
+			wantHTTPCode: http.StatusNotFound,
+			callMocks: func() {
+				serviceMock.On("URLHistory", mock.Anything, mock.Anything, "xyz").Return(nil, service.ErrNotFound).Once()
+			},
+		},
+		{
+			name:         "storage without revisions",
+			code:         "def",
+			wantHTTPCode: http.StatusNotImplemented,
+			callMocks: func() {
+				serviceMock.On("URLHistory", mock.Anything, mock.Anything, "def").Return(nil, service.ErrRevisionsNotSupported).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+tt.code+"/history", nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("code", tt.code)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			hh := withAuth(http.HandlerFunc(h.URLHistory))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_RestoreURL(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		code         string
+		body         string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "restore revision",
+			code:         "abc",
+			body:         `{"revision":1}`,
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"result":"http://localhost:8080/abc"}`,
+			callMocks: func() {
+				serviceMock.On("RestoreURL", mock.Anything, mock.Anything, "abc", int64(1)).Return(nil).Once()
+			},
+		},
+		{
+			name:         "undelete without body",
+			code:         "abc",
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"result":"http://localhost:8080/abc"}`,
+			callMocks: func() {
+				serviceMock.On("RestoreURL", mock.Anything, mock.Anything, "abc", int64(0)).Return(nil).Once()
+			},
+		},
+		{
+			name:         "malformed body",
+			code:         "abc",
+			body:         `1`,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+		{
+			name:         "URL is shortened with another code",
+			code:         "abc",
+			body:         `{"revision":2}`,
+			wantHTTPCode: http.StatusConflict,
+			callMocks: func() {
+				serviceMock.On("RestoreURL", mock.Anything, mock.Anything, "abc", int64(2)).Return(service.ErrURLAlreadyExists).Once()
+			},
+		},
+		{
+			name:         "unknown revision",
+			code:         "abc",
+			body:         `{"revision":100}`,
+			wantHTTPCode: http.StatusNotFound,
+			callMocks: func() {
+				serviceMock.On("RestoreURL", mock.Anything, mock.Anything, "abc", int64(100)).Return(service.ErrNotFound).Once()
+			},
+		},
+		{
+			name:         "not owner",
+			code:         "xyz",
+			body:         `{"revision":1}`,
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("RestoreURL", mock.Anything, mock.Anything, "xyz", int64(1)).Return(service.ErrForbidden).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/urls/"+tt.code+"/restore", strings.NewReader(tt.body))
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("code", tt.code)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			hh := withAuth(http.HandlerFunc(h.RestoreURL))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_SignUp(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	key, err := jwt.GenerateKey("test")
+	assert.NoError(t, err)
+	keyring, err := jwt.NewKeyring(key)
+	assert.NoError(t, err)
+
+	deps := httphandlers.Dependencies{
+		Service:      serviceMock,
+		TokenBuilder: keyring,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	account := &service.Account{ID: "account", Login: "alice"}
+
+	tests := []struct {
+		name         string
+		body         string
+		identity     *entity.Identity
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			body:         `{"login":"alice","password":"password"}`,
+			wantHTTPCode: http.StatusCreated,
+			wantResp:     `{"user_id":"account","login":"alice","claimed":0}`,
+			callMocks: func() {
+				serviceMock.On("SignUp", mock.Anything, "alice", "password").Return(account, nil).Once()
+			},
+		},
+		{
+			name:         "claim links of the anonymous user",
+			body:         `{"login":"alice","password":"password","claim":true}`,
+			wantHTTPCode: http.StatusCreated,
+			wantResp:     `{"user_id":"account","login":"alice","claimed":2}`,
+			callMocks: func() {
+				serviceMock.On("SignUp", mock.Anything, "alice", "password").Return(account, nil).Once()
+				serviceMock.On("ClaimURLs", mock.Anything, mock.Anything, "account").Return(2, nil).Once()
+			},
+		},
+		{
+			name:         "links of the oidc user aren't claimed",
+			body:         `{"login":"alice","password":"password","claim":true}`,
+			identity:     &entity.Identity{UserID: "sso-user", Scopes: service.SessionScopes, Kind: entity.KindOIDC},
+			wantHTTPCode: http.StatusCreated,
+			wantResp:     `{"user_id":"account","login":"alice","claimed":0}`,
+			callMocks: func() {
+				serviceMock.On("SignUp", mock.Anything, "alice", "password").Return(account, nil).Once()
+			},
+		},
+		{
+			name:         "login taken",
+			body:         `{"login":"alice","password":"password"}`,
+			wantHTTPCode: http.StatusConflict,
+			callMocks: func() {
+				serviceMock.On("SignUp", mock.Anything, "alice", "password").Return(nil, service.ErrLoginTaken).Once()
+			},
+		},
+		{
+			name:         "short password",
+			body:         `{"login":"alice","password":"pass"}`,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks: func() {
+				serviceMock.On("SignUp", mock.Anything, "alice", "pass").Return(nil, service.ErrInvalidPassword).Once()
+			},
+		},
+		{
+			name:         "malformed body",
+			body:         `alice`,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/signup", strings.NewReader(tt.body))
+
+			hh := withAuth(http.HandlerFunc(h.SignUp))
+			if tt.identity != nil {
+				r = r.WithContext(entity.WithIdentity(r.Context(), *tt.identity))
+				hh = http.HandlerFunc(h.SignUp)
+			}
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp == "" {
+				return
+			}
+
+			assert.JSONEq(t, tt.wantResp, w.Body.String())
+
+			// the last cookie is the session of the account
+			cookies := w.Result().Cookies()
+			if assert.NotEmpty(t, cookies) {
+				claims, err := keyring.ParseJWTToken(cookies[len(cookies)-1].Value)
+				assert.NoError(t, err)
+				assert.Equal(t, "account", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestHandlers_Login(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	key, err := jwt.GenerateKey("test")
+	assert.NoError(t, err)
+	keyring, err := jwt.NewKeyring(key)
+	assert.NoError(t, err)
+
+	deps := httphandlers.Dependencies{
+		Service:      serviceMock,
+		TokenBuilder: keyring,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	account := &service.Account{ID: "account", Login: "alice"}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			body:         `{"login":"alice","password":"password"}`,
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"user_id":"account","login":"alice","claimed":0}`,
+			callMocks: func() {
+				serviceMock.On("Login", mock.Anything, "alice", "password").Return(account, nil).Once()
+			},
+		},
+		{
+			name:         "current user is another account",
+			body:         `{"login":"alice","password":"password","claim":true}`,
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `{"user_id":"account","login":"alice","claimed":0}`,
+			callMocks: func() {
+				serviceMock.On("Login", mock.Anything, "alice", "password").Return(account, nil).Once()
+				serviceMock.On("ClaimURLs", mock.Anything, mock.Anything, "account").Return(0, service.ErrForbidden).Once()
+			},
+		},
+		{
+			name:         "wrong password",
+			body:         `{"login":"alice","password":"wrong"}`,
+			wantHTTPCode: http.StatusUnauthorized,
+			callMocks: func() {
+				serviceMock.On("Login", mock.Anything, "alice", "wrong").Return(nil, service.ErrInvalidCredentials).Once()
+			},
+		},
+		{
+			name:         "storage without accounts",
+			body:         `{"login":"alice","password":"password"}`,
+			wantHTTPCode: http.StatusNotImplemented,
+			callMocks: func() {
+				serviceMock.On("Login", mock.Anything, "alice", "password").Return(nil, service.ErrAccountsNotSupported).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(tt.body))
+
+			hh := withAuth(http.HandlerFunc(h.Login))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_CreateAPIKey(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			body:         `{"name":"ci","scopes":["read","stats"]}`,
+			wantHTTPCode: http.StatusCreated,
+			wantResp:     `{"id":"1","name":"ci","scopes":["read","stats"],"key":"secret","created_at":"2024-01-01T00:00:00Z"}`,
+			callMocks: func() {
+				serviceMock.On("CreateAPIKey", mock.Anything, mock.Anything, "ci", []string{"read", "stats"}).
+					Return("secret", &service.APIKey{ID: "1", Name: "ci", Scopes: []string{"read", "stats"}, CreatedAt: created}, nil).Once()
+			},
+		},
+		{
+			name:         "unknown scope",
+			body:         `{"name":"ci","scopes":["write"]}`,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks: func() {
+				serviceMock.On("CreateAPIKey", mock.Anything, mock.Anything, "ci", []string{"write"}).
+					Return("", nil, service.ErrInvalidScope).Once()
+			},
+		},
+		{
+			name:         "anonymous user",
+			body:         `{"name":"ci","scopes":["read"]}`,
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("CreateAPIKey", mock.Anything, mock.Anything, "ci", []string{"read"}).
+					Return("", nil, service.ErrForbidden).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/keys", strings.NewReader(tt.body))
+
+			hh := withAuth(http.HandlerFunc(h.CreateAPIKey))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_OIDC(t *testing.T) {
+	provider := oidctest.NewProvider(t)
+	provider.Subject = "alice"
+
+	const callbackURL = "http://localhost:8080/api/auth/oidc/callback"
+	p, err := oidc.Discover(context.Background(), provider.Config(callbackURL), nil)
+	assert.NoError(t, err)
+
+	key, err := jwt.GenerateKey("test")
+	assert.NoError(t, err)
+	keyring, err := jwt.NewKeyring(key)
+	assert.NoError(t, err)
+
+	h, err := httphandlers.New(httphandlers.Config{}, httphandlers.Dependencies{
+		Service:      mocks.NewService(t),
+		TokenBuilder: keyring,
+		OIDCProvider: p,
+	})
+	assert.NoError(t, err)
+
+	// login starts the flow and returns the state cookie and the callback URL the provider redirects to
+	login := func(t *testing.T) (*http.Cookie, string) {
+		w := httptest.NewRecorder()
+		h.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 1)
+
+		client := http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Get(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+		return cookies[0], resp.Header.Get("Location")
+	}
+
+	callback := func(cookie *http.Cookie, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+
+		h.OIDCCallback(w, r)
+
+		return w
+	}
+
+	t.Run("successful login", func(t *testing.T) {
+		cookie, target := login(t)
+
+		w := callback(cookie, target)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		userID := (&oidc.IDToken{Issuer: provider.URL, Subject: "alice"}).UserID()
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id":%q}`, userID), w.Body.String())
+
+		var authCookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == entity.AuthTokenHeader {
+				authCookie = c
+			}
+		}
+		if assert.NotNil(t, authCookie) {
+			claims, err := keyring.ParseJWTToken(authCookie.Value)
+			assert.NoError(t, err)
+			assert.Equal(t, userID, claims.UserID)
+		}
+
+		w = callback(cookie, target)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "code is single use")
+	})
+
+	t.Run("another state", func(t *testing.T) {
+		cookie, target := login(t)
+		cookie.Value = "other." + strings.SplitN(cookie.Value, ".", 2)[1]
+
+		assert.Equal(t, http.StatusBadRequest, callback(cookie, target).Code)
+	})
+
+	t.Run("missing state cookie", func(t *testing.T) {
+		_, target := login(t)
+
+		assert.Equal(t, http.StatusBadRequest, callback(nil, target).Code)
+	})
+
+	t.Run("another nonce", func(t *testing.T) {
+		cookie, _ := login(t)
+		state := strings.SplitN(cookie.Value, ".", 2)[0]
+
+		target := callbackURL + "?state=" + state + "&code=" + provider.Code("alice", "other")
+		assert.Equal(t, http.StatusUnauthorized, callback(cookie, target).Code)
+	})
+
+	t.Run("login denied by provider", func(t *testing.T) {
+		cookie, _ := login(t)
+		state := strings.SplitN(cookie.Value, ".", 2)[0]
+
+		target := callbackURL + "?state=" + state + "&error=access_denied"
+		assert.Equal(t, http.StatusUnauthorized, callback(cookie, target).Code)
+	})
+
+	t.Run("provider isn't configured", func(t *testing.T) {
+		h, err := httphandlers.New(httphandlers.Config{}, httphandlers.Dependencies{Service: mocks.NewService(t)})
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		h.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+}
+
+func TestHandlers_ClickStats(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		target       string
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "successful request",
+			target:       "/api/user/urls/abc/stats/aggregate?from=2026-01-01T00:00:00Z&to=2026-01-03T00:00:00Z&interval=day&top=3",
+			wantHTTPCode: http.StatusOK,
+			wantResp: `{"code":"abc","clicks":3,"unique_visitors":2,
+				"buckets":[{"time":"2026-01-01T00:00:00Z","clicks":3},{"time":"2026-01-02T00:00:00Z","clicks":0}],
+				"referrers":[{"name":"google.com","clicks":2}],
+				"browsers":[{"name":"Chrome","clicks":3}],
+				"os":[]}`,
+			callMocks: func() {
+				serviceMock.On("ClickStats", mock.Anything, mock.Anything, service.ClickStatsQuery{
+					Code: "abc", From: from, To: to, Interval: "day", Top: 3,
+				}).Return(&service.ClickStats{
+					Code:           "abc",
+					Clicks:         3,
+					UniqueVisitors: 2,
+					Buckets:        []service.ClickBucket{{Time: from, Clicks: 3}, {Time: from.AddDate(0, 0, 1)}},
+					Referrers:      []service.ClickGroup{{Name: "google.com", Clicks: 2}},
+					Browsers:       []service.ClickGroup{{Name: "Chrome", Clicks: 3}},
+				}, nil).Once()
+			},
+		},
+		{
+			name:         "invalid time",
+			target:       "/api/user/urls/abc/stats/aggregate?from=yesterday",
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+		{
+			name:         "invalid top",
+			target:       "/api/user/urls/abc/stats/aggregate?top=0",
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+		{
+			name:         "invalid query",
+			target:       "/api/user/urls/abc/stats/aggregate?interval=week",
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks: func() {
+				serviceMock.On("ClickStats", mock.Anything, mock.Anything, service.ClickStatsQuery{Code: "abc", Interval: "week"}).
+					Return(nil, service.ErrInvalidStatsQuery).Once()
+			},
+		},
+		{
+			name:         "not owner",
+			target:       "/api/user/urls/abc/stats/aggregate",
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("ClickStats", mock.Anything, mock.Anything, service.ClickStatsQuery{Code: "abc"}).
+					Return(nil, service.ErrForbidden).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("code", "abc")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			hh := withAuth(http.HandlerFunc(h.ClickStats))
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_StatsTrustedSubnet(t *testing.T) {
+
+	ip := net.ParseIP("248.133.72.1")
+
+	_, ipNet, err := net.ParseCIDR("248.133.71.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(ipNet.Contains(ip))
+}
+
+func TestHandlers_Admin(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+
+	deps := httphandlers.Dependencies{
+		Service: serviceMock,
+	}
+	h, err := httphandlers.New(httphandlers.Config{RedirectBasePath: "http://localhost:8080"}, deps)
+	assert.NoError(t, err)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		params       map[string]string
+		handler      http.HandlerFunc
+		wantHTTPCode int
+		wantResp     string
+		callMocks    func()
+	}{
+		{
+			name:         "look up url",
+			method:       http.MethodGet,
+			target:       "/api/admin/urls/abc",
+			params:       map[string]string{"code": "abc"},
+			handler:      h.AdminURL,
+			wantHTTPCode: http.StatusOK,
+			wantResp: `{"code":"abc","short_url":"http://localhost:8080/abc","original_url":"https://ya.ru","owner_id":"owner",
+				"deleted":false,"blocked":true,"created_at":"2024-01-01T00:00:00Z"}`,
+			callMocks: func() {
+				serviceMock.On("URLInfo", mock.Anything, mock.Anything, "abc").Return(&service.URLInfo{
+					Code: "abc", OriginalURL: "https://ya.ru", OwnerID: "owner", Blocked: true, CreatedAt: created,
+				}, nil).Once()
+			},
+		},
+		{
+			name:         "not admin",
+			method:       http.MethodGet,
+			target:       "/api/admin/urls/abc",
+			params:       map[string]string{"code": "abc"},
+			handler:      h.AdminURL,
+			wantHTTPCode: http.StatusForbidden,
+			callMocks: func() {
+				serviceMock.On("URLInfo", mock.Anything, mock.Anything, "abc").Return(nil, service.ErrForbidden).Once()
+			},
+		},
+		{
+			name:         "storage without moderation",
+			method:       http.MethodGet,
+			target:       "/api/admin/urls/abc",
+			params:       map[string]string{"code": "abc"},
+			handler:      h.AdminURL,
+			wantHTTPCode: http.StatusNotImplemented,
+			callMocks: func() {
+				serviceMock.On("URLInfo", mock.Anything, mock.Anything, "abc").Return(nil, service.ErrModerationNotSupported).Once()
+			},
+		},
+		{
+			name:         "force delete url",
+			method:       http.MethodDelete,
+			target:       "/api/admin/urls/abc",
+			params:       map[string]string{"code": "abc"},
+			handler:      h.AdminDeleteURL,
+			wantHTTPCode: http.StatusNoContent,
+			callMocks: func() {
+				serviceMock.On("ForceDeleteURL", mock.Anything, mock.Anything, "abc").Return(nil).Once()
+			},
+		},
+		{
+			name:         "force delete unknown url",
+			method:       http.MethodDelete,
+			target:       "/api/admin/urls/xyz",
+			params:       map[string]string{"code": "xyz"},
+			handler:      h.AdminDeleteURL,
+			wantHTTPCode: http.StatusNotFound,
+			callMocks: func() {
+				serviceMock.On("ForceDeleteURL", mock.Anything, mock.Anything, "xyz").Return(service.ErrNotFound).Once()
+			},
+		},
+		{
+			name:         "block url",
+			method:       http.MethodPut,
+			target:       "/api/admin/urls/abc/block",
+			params:       map[string]string{"code": "abc"},
+			handler:      h.AdminBlockURL,
+			wantHTTPCode: http.StatusNoContent,
+			callMocks: func() {
+				serviceMock.On("BlockURL", mock.Anything, mock.Anything, "abc", true).Return(nil).Once()
+			},
+		},
+		{
+			name:         "unblock url",
+			method:       http.MethodDelete,
+			target:       "/api/admin/urls/abc/block",
+			params:       map[string]string{"code": "abc"},
+			handler:      h.AdminUnblockURL,
+			wantHTTPCode: http.StatusNoContent,
+			callMocks: func() {
+				serviceMock.On("BlockURL", mock.Anything, mock.Anything, "abc", false).Return(nil).Once()
+			},
+		},
+		{
+			name:         "list urls of user",
+			method:       http.MethodGet,
+			target:       "/api/admin/users/owner/urls?limit=1",
+			params:       map[string]string{"id": "owner"},
+			handler:      h.AdminUserURLs,
+			wantHTTPCode: http.StatusOK,
+			wantResp:     `[{"short_url":"http://localhost:8080/abc","original_url":"https://ya.ru","clicks":3}]`,
+			callMocks: func() {
+				serviceMock.On("UserURLs", mock.Anything, mock.Anything, "owner", service.UsersURLsQuery{Limit: 1}).
+					Return(&service.UsersURLsPage{URLs: []service.UsersURL{{Code: "abc", OriginalURL: "https://ya.ru", Clicks: 3}}}, nil).Once()
+			},
+		},
+		{
+			name:         "invalid query",
+			method:       http.MethodGet,
+			target:       "/api/admin/users/owner/urls?order=random",
+			params:       map[string]string{"id": "owner"},
+			handler:      h.AdminUserURLs,
+			wantHTTPCode: http.StatusBadRequest,
+			callMocks:    func() {},
+		},
+		{
+			name:         "ban user",
+			method:       http.MethodPut,
+			target:       "/api/admin/users/owner/ban",
+			params:       map[string]string{"id": "owner"},
+			handler:      h.AdminBanUser,
+			wantHTTPCode: http.StatusNoContent,
+			callMocks: func() {
+				serviceMock.On("BanUser", mock.Anything, mock.Anything, "owner", true).Return(nil).Once()
+			},
+		},
+		{
+			name:         "unban user",
+			method:       http.MethodDelete,
+			target:       "/api/admin/users/owner/ban",
+			params:       map[string]string{"id": "owner"},
+			handler:      h.AdminUnbanUser,
+			wantHTTPCode: http.StatusNoContent,
+			callMocks: func() {
+				serviceMock.On("BanUser", mock.Anything, mock.Anything, "owner", false).Return(nil).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.callMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+
+			rctx := chi.NewRouteContext()
+			for k, v := range tt.params {
+				rctx.URLParams.Add(k, v)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			hh := withAuth(tt.handler)
+			hh.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantResp != "" {
+				assert.JSONEq(t, tt.wantResp, w.Body.String())
+			}
+		})
+	}
 }
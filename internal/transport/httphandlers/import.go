@@ -0,0 +1,265 @@
+package httphandlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// maxImportLineSize максимальный размер одной строки NDJSON при импорте
+const maxImportLineSize = 1 << 20
+
+// ImportURLs импортирует ссылки пользователя из потока NDJSON или CSV
+// записи сохраняются частями, после каждой части в ответ пишется строка NDJSON с прогрессом,
+// параметр checkpoint позволяет продолжить прерванный импорт, пропустив уже обработанные записи
+func (h *Handlers) ImportURLs(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// прогресс пишется в ответ пока читается тело запроса
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logrus.Errorf("failed to enable full duplex: %s", err)
+	}
+
+	var checkpoint int64
+	if v := req.URL.Query().Get("checkpoint"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "checkpoint must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		checkpoint = n
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	var r service.ImportReader
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl":
+		r = newNDJSONImportReader(req.Body)
+	case "text/csv":
+		cr, err := newCSVImportReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r = cr
+	default:
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	type rejected struct {
+		Record        int64  `json:"record"`
+		CorrelationID string `json:"correlation_id,omitempty"`
+		Reason        string `json:"reason"`
+	}
+
+	type progress struct {
+		Checkpoint int64      `json:"checkpoint"`
+		Created    int64      `json:"created"`
+		Exists     int64      `json:"exists"`
+		Invalid    int64      `json:"invalid"`
+		Rejected   []rejected `json:"rejected,omitempty"`
+		Done       bool       `json:"done,omitempty"`
+		Error      string     `json:"error,omitempty"`
+	}
+
+	w.Header().Add("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(p service.ImportProgress, done bool, errText string) error {
+		resp := progress{
+			Checkpoint: p.Checkpoint,
+			Created:    p.Created,
+			Exists:     p.Exists,
+			Invalid:    p.Invalid,
+			Done:       done,
+			Error:      errText,
+		}
+		for _, rj := range p.Rejected {
+			resp.Rejected = append(resp.Rejected, rejected{Record: rj.Record, CorrelationID: rj.СorrelationID, Reason: rj.Reason})
+		}
+
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(resp); err != nil {
+			return fmt.Errorf("failed encode progress to json: %w", err)
+		}
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write progress: %w", err)
+		}
+
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("failed to flush progress: %w", err)
+		}
+
+		return nil
+	}
+
+	report := func(p service.ImportProgress) error {
+		return write(p, false, "")
+	}
+
+	p, err := h.service.ImportURLs(req.Context(), user.UserID, r, checkpoint, report)
+	if err != nil {
+		logrus.Errorf("failed to import urls (checkpoint = %d): %s", p.Checkpoint, err)
+		if err := write(p, false, "import interrupted, resume from the checkpoint"); err != nil {
+			logrus.Errorf("failed to report import error: %s", err)
+		}
+		return
+	}
+
+	if err := write(p, true, ""); err != nil {
+		logrus.Errorf("failed to report import result: %s", err)
+	}
+}
+
+// importRecord запись импорта в формате NDJSON, поля совпадают с запросом ShortenBatchURL
+type importRecord struct {
+	CorrelationID string    `json:"correlation_id"`
+	OriginalURL   string    `json:"original_url"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	TTL           int64     `json:"ttl"`
+}
+
+func (r importRecord) url() service.URL {
+	return service.URL{
+		СorrelationID: r.CorrelationID,
+		OriginalURL:   r.OriginalURL,
+		ExpiresAt:     r.ExpiresAt,
+		TTL:           time.Duration(r.TTL) * time.Second,
+	}
+}
+
+// ndjsonImportReader читает записи импорта построчно, пустые строки пропускаются
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONImportReader(r io.Reader) *ndjsonImportReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+
+	return &ndjsonImportReader{scanner: scanner}
+}
+
+// Read возвращает следующую запись
+func (r *ndjsonImportReader) Read() (service.URL, error) {
+	for r.scanner.Scan() {
+		r.line++
+
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec importRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return service.URL{}, fmt.Errorf("%w: line %d: %s", service.ErrInvalidImportRecord, r.line, err)
+		}
+
+		return rec.url(), nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return service.URL{}, fmt.Errorf("failed to read line %d: %w", r.line+1, err)
+	}
+
+	return service.URL{}, io.EOF
+}
+
+// csvImportReader читает записи импорта в формате CSV
+// первая строка это заголовок, обязательна колонка original_url,
+// необязательны correlation_id, expires_at в формате RFC 3339 и ttl в секундах, остальные колонки игнорируются
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["original_url"]; !ok {
+		return nil, errors.New("csv header must contain original_url column")
+	}
+
+	return &csvImportReader{reader: reader, columns: columns}, nil
+}
+
+// Read возвращает следующую запись
+func (r *csvImportReader) Read() (service.URL, error) {
+	fields, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return service.URL{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return service.URL{}, fmt.Errorf("%w: %s", service.ErrInvalidImportRecord, parseErr)
+	}
+	if err != nil {
+		return service.URL{}, fmt.Errorf("failed to read csv record: %w", err)
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	rec := importRecord{
+		CorrelationID: field("correlation_id"),
+		OriginalURL:   field("original_url"),
+	}
+
+	if v := field("expires_at"); v != "" {
+		rec.ExpiresAt, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return service.URL{}, fmt.Errorf("%w: line %d: expires_at must be in RFC 3339 format", service.ErrInvalidImportRecord, line)
+		}
+	}
+
+	if v := field("ttl"); v != "" {
+		rec.TTL, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return service.URL{}, fmt.Errorf("%w: line %d: ttl must be an integer", service.ErrInvalidImportRecord, line)
+		}
+	}
+
+	return rec.url(), nil
+}
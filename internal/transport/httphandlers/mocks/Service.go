@@ -15,24 +15,279 @@ type Service struct {
 	mock.Mock
 }
 
+// APIKeys provides a mock function with given fields: ctx, userID
+func (_m *Service) APIKeys(ctx context.Context, userID string) ([]service.APIKey, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for APIKeys")
+	}
+
+	var r0 []service.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]service.APIKey, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []service.APIKey); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BanUser provides a mock function with given fields: ctx, adminID, userID, banned
+func (_m *Service) BanUser(ctx context.Context, adminID string, userID string, banned bool) error {
+	ret := _m.Called(ctx, adminID, userID, banned)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BanUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, bool) error); ok {
+		r0 = rf(ctx, adminID, userID, banned)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// BlockURL provides a mock function with given fields: ctx, adminID, code, blocked
+func (_m *Service) BlockURL(ctx context.Context, adminID string, code string, blocked bool) error {
+	ret := _m.Called(ctx, adminID, code, blocked)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BlockURL")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, bool) error); ok {
+		r0 = rf(ctx, adminID, code, blocked)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClaimURLs provides a mock function with given fields: ctx, anonymousID, accountID
+func (_m *Service) ClaimURLs(ctx context.Context, anonymousID string, accountID string) (int, error) {
+	ret := _m.Called(ctx, anonymousID, accountID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimURLs")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (int, error)); ok {
+		return rf(ctx, anonymousID, accountID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, anonymousID, accountID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, anonymousID, accountID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClickStats provides a mock function with given fields: ctx, userID, q
+func (_m *Service) ClickStats(ctx context.Context, userID string, q service.ClickStatsQuery) (*service.ClickStats, error) {
+	ret := _m.Called(ctx, userID, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClickStats")
+	}
+
+	var r0 *service.ClickStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.ClickStatsQuery) (*service.ClickStats, error)); ok {
+		return rf(ctx, userID, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.ClickStatsQuery) *service.ClickStats); ok {
+		r0 = rf(ctx, userID, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.ClickStats)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, service.ClickStatsQuery) error); ok {
+		r1 = rf(ctx, userID, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateAPIKey provides a mock function with given fields: ctx, userID, name, scopes
+func (_m *Service) CreateAPIKey(ctx context.Context, userID string, name string, scopes []string) (string, *service.APIKey, error) {
+	ret := _m.Called(ctx, userID, name, scopes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAPIKey")
+	}
+
+	var r0 string
+	var r1 *service.APIKey
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string) (string, *service.APIKey, error)); ok {
+		return rf(ctx, userID, name, scopes)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string) string); ok {
+		r0 = rf(ctx, userID, name, scopes)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []string) *service.APIKey); ok {
+		r1 = rf(ctx, userID, name, scopes)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*service.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, []string) error); ok {
+		r2 = rf(ctx, userID, name, scopes)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ExportUsersURLs provides a mock function with given fields: ctx, userID, fn
+func (_m *Service) ExportUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	ret := _m.Called(ctx, userID, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportUsersURLs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, func(service.UsersURL) error) error); ok {
+		r0 = rf(ctx, userID, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ForceDeleteURL provides a mock function with given fields: ctx, adminID, code
+func (_m *Service) ForceDeleteURL(ctx context.Context, adminID string, code string) error {
+	ret := _m.Called(ctx, adminID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ForceDeleteURL")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, adminID, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ImportURLs provides a mock function with given fields: ctx, userID, r, checkpoint, progress
+func (_m *Service) ImportURLs(ctx context.Context, userID string, r service.ImportReader, checkpoint int64, progress func(service.ImportProgress) error) (service.ImportProgress, error) {
+	ret := _m.Called(ctx, userID, r, checkpoint, progress)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportURLs")
+	}
+
+	var r0 service.ImportProgress
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.ImportReader, int64, func(service.ImportProgress) error) (service.ImportProgress, error)); ok {
+		return rf(ctx, userID, r, checkpoint, progress)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.ImportReader, int64, func(service.ImportProgress) error) service.ImportProgress); ok {
+		r0 = rf(ctx, userID, r, checkpoint, progress)
+	} else {
+		r0 = ret.Get(0).(service.ImportProgress)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, service.ImportReader, int64, func(service.ImportProgress) error) error); ok {
+		r1 = rf(ctx, userID, r, checkpoint, progress)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Login provides a mock function with given fields: ctx, login, password
+func (_m *Service) Login(ctx context.Context, login string, password string) (*service.Account, error) {
+	ret := _m.Called(ctx, login, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Login")
+	}
+
+	var r0 *service.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*service.Account, error)); ok {
+		return rf(ctx, login, password)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *service.Account); ok {
+		r0 = rf(ctx, login, password)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.Account)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, login, password)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // MakeBatchShortURL provides a mock function with given fields: ctx, userID, urls
-func (_m *Service) MakeBatchShortURL(ctx context.Context, userID string, urls []service.URL) ([]service.URL, error) {
+func (_m *Service) MakeBatchShortURL(ctx context.Context, userID string, urls []service.URL) ([]service.BatchResult, error) {
 	ret := _m.Called(ctx, userID, urls)
 
 	if len(ret) == 0 {
 		panic("no return value specified for MakeBatchShortURL")
 	}
 
-	var r0 []service.URL
+	var r0 []service.BatchResult
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, []service.URL) ([]service.URL, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string, []service.URL) ([]service.BatchResult, error)); ok {
 		return rf(ctx, userID, urls)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, []service.URL) []service.URL); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string, []service.URL) []service.BatchResult); ok {
 		r0 = rf(ctx, userID, urls)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]service.URL)
+			r0 = ret.Get(0).([]service.BatchResult)
 		}
 	}
 
@@ -45,9 +300,9 @@ func (_m *Service) MakeBatchShortURL(ctx context.Context, userID string, urls []
 	return r0, r1
 }
 
-// MakeShortURL provides a mock function with given fields: ctx, userID, url
-func (_m *Service) MakeShortURL(ctx context.Context, userID string, url string) (string, error) {
-	ret := _m.Called(ctx, userID, url)
+// MakeShortURL provides a mock function with given fields: ctx, userID, url, opts
+func (_m *Service) MakeShortURL(ctx context.Context, userID string, url string, opts service.ShortenOptions) (string, error) {
+	ret := _m.Called(ctx, userID, url, opts)
 
 	if len(ret) == 0 {
 		panic("no return value specified for MakeShortURL")
@@ -55,17 +310,83 @@ func (_m *Service) MakeShortURL(ctx context.Context, userID string, url string)
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) (string, error)); ok {
-		return rf(ctx, userID, url)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, service.ShortenOptions) (string, error)); ok {
+		return rf(ctx, userID, url, opts)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
-		r0 = rf(ctx, userID, url)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, service.ShortenOptions) string); ok {
+		r0 = rf(ctx, userID, url, opts)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, service.ShortenOptions) error); ok {
+		r1 = rf(ctx, userID, url, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreURL provides a mock function with given fields: ctx, userID, code, revisionID
+func (_m *Service) RestoreURL(ctx context.Context, userID string, code string, revisionID int64) error {
+	ret := _m.Called(ctx, userID, code, revisionID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreURL")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int64) error); ok {
+		r0 = rf(ctx, userID, code, revisionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeAPIKey provides a mock function with given fields: ctx, userID, id
+func (_m *Service) RevokeAPIKey(ctx context.Context, userID string, id string) error {
+	ret := _m.Called(ctx, userID, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAPIKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userID, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SignUp provides a mock function with given fields: ctx, login, password
+func (_m *Service) SignUp(ctx context.Context, login string, password string) (*service.Account, error) {
+	ret := _m.Called(ctx, login, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SignUp")
+	}
+
+	var r0 *service.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*service.Account, error)); ok {
+		return rf(ctx, login, password)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *service.Account); ok {
+		r0 = rf(ctx, login, password)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.Account)
+		}
+	}
+
 	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, userID, url)
+		r1 = rf(ctx, login, password)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -131,29 +452,177 @@ func (_m *Service) URL(ctx context.Context, id string) (string, error) {
 	return r0, r1
 }
 
-// UsersURLs provides a mock function with given fields: ctx, userID
-func (_m *Service) UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error) {
-	ret := _m.Called(ctx, userID)
+// URLHistory provides a mock function with given fields: ctx, userID, code
+func (_m *Service) URLHistory(ctx context.Context, userID string, code string) ([]service.Revision, error) {
+	ret := _m.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for URLHistory")
+	}
+
+	var r0 []service.Revision
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]service.Revision, error)); ok {
+		return rf(ctx, userID, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []service.Revision); ok {
+		r0 = rf(ctx, userID, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.Revision)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userID, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// URLInfo provides a mock function with given fields: ctx, adminID, code
+func (_m *Service) URLInfo(ctx context.Context, adminID string, code string) (*service.URLInfo, error) {
+	ret := _m.Called(ctx, adminID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for URLInfo")
+	}
+
+	var r0 *service.URLInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*service.URLInfo, error)); ok {
+		return rf(ctx, adminID, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *service.URLInfo); ok {
+		r0 = rf(ctx, adminID, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.URLInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, adminID, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// URLStats provides a mock function with given fields: ctx, userID, code, limit
+func (_m *Service) URLStats(ctx context.Context, userID string, code string, limit int) (*service.URLStats, error) {
+	ret := _m.Called(ctx, userID, code, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for URLStats")
+	}
+
+	var r0 *service.URLStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) (*service.URLStats, error)); ok {
+		return rf(ctx, userID, code, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) *service.URLStats); ok {
+		r0 = rf(ctx, userID, code, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.URLStats)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int) error); ok {
+		r1 = rf(ctx, userID, code, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateURL provides a mock function with given fields: ctx, userID, code, url
+func (_m *Service) UpdateURL(ctx context.Context, userID string, code string, url string) (string, error) {
+	ret := _m.Called(ctx, userID, code, url)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateURL")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (string, error)); ok {
+		return rf(ctx, userID, code, url)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) string); ok {
+		r0 = rf(ctx, userID, code, url)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, userID, code, url)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserURLs provides a mock function with given fields: ctx, adminID, userID, q
+func (_m *Service) UserURLs(ctx context.Context, adminID string, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	ret := _m.Called(ctx, adminID, userID, q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UserURLs")
+	}
+
+	var r0 *service.UsersURLsPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, service.UsersURLsQuery) (*service.UsersURLsPage, error)); ok {
+		return rf(ctx, adminID, userID, q)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, service.UsersURLsQuery) *service.UsersURLsPage); ok {
+		r0 = rf(ctx, adminID, userID, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.UsersURLsPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, service.UsersURLsQuery) error); ok {
+		r1 = rf(ctx, adminID, userID, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersURLs provides a mock function with given fields: ctx, userID, q
+func (_m *Service) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	ret := _m.Called(ctx, userID, q)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UsersURLs")
 	}
 
-	var r0 []service.UsersURL
+	var r0 *service.UsersURLsPage
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]service.UsersURL, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.UsersURLsQuery) (*service.UsersURLsPage, error)); ok {
+		return rf(ctx, userID, q)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []service.UsersURL); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, service.UsersURLsQuery) *service.UsersURLsPage); ok {
+		r0 = rf(ctx, userID, q)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]service.UsersURL)
+			r0 = ret.Get(0).(*service.UsersURLsPage)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, service.UsersURLsQuery) error); ok {
+		r1 = rf(ctx, userID, q)
 	} else {
 		r1 = ret.Error(1)
 	}
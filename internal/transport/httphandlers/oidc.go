@@ -0,0 +1,128 @@
+package httphandlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+)
+
+// oidcStateCookie cookie со state и nonce начатого входа через OIDC провайдер
+const oidcStateCookie = "oidc_state"
+
+// oidcLoginTimeout время, за которое пользователь должен войти у провайдера
+const oidcLoginTimeout = 10 * time.Minute
+
+// OIDCLogin начинает вход через OIDC провайдер и перенаправляет пользователя на его страницу входа
+// state и nonce сохраняются в cookie и проверяются в OIDCCallback
+func (h *Handlers) OIDCLogin(w http.ResponseWriter, req *http.Request) {
+	if h.oidc == nil {
+		http.Error(w, "oidc login isn't supported", http.StatusNotImplemented)
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		logrus.Errorf("failed to generate state: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		logrus.Errorf("failed to generate nonce: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state + "." + nonce,
+		Path:     "/",
+		Expires:  time.Now().Add(oidcLoginTimeout),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, req, h.oidc.AuthCodeURL(state, nonce), http.StatusFound)
+}
+
+// OIDCCallback завершает вход через OIDC провайдер: обменивает код на ID токен и выставляет cookie пользователя
+// ID пользователя выводится из sub токена, поэтому повторный вход даёт того же пользователя
+func (h *Handlers) OIDCCallback(w http.ResponseWriter, req *http.Request) {
+	if h.oidc == nil {
+		http.Error(w, "oidc login isn't supported", http.StatusNotImplemented)
+		return
+	}
+
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil {
+		http.Error(w, "missing login state", http.StatusBadRequest)
+		return
+	}
+
+	state, nonce, _ := strings.Cut(cookie.Value, ".")
+	q := req.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	token, err := h.oidc.Exchange(req.Context(), q.Get("code"))
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidCode) || errors.Is(err, oidc.ErrInvalidToken) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		logrus.Errorf("failed to exchange oidc code: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(token.Nonce)) != 1 {
+		http.Error(w, "invalid nonce", http.StatusUnauthorized)
+		return
+	}
+
+	userID := token.UserID()
+	if !h.setAuthCookie(w, userID) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		UserID string `json:"user_id"`
+	}{
+		UserID: userID,
+	})
+}
+
+// randomString возвращает случайную строку для state и nonce
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,124 @@
+package httphandlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// URLHistory возвращает историю изменений ссылки пользователя: создание, смену исходного URL, удаление и восстановление
+// для каждого изменения возвращается автор, время, исходный URL до и после изменения
+func (h *Handlers) URLHistory(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	code := chi.URLParam(req, "code")
+	revisions, err := h.service.URLHistory(req.Context(), user.UserID, code)
+	if err != nil {
+		h.revisionError(w, code, err)
+		return
+	}
+
+	type respRevision struct {
+		ID          int64     `json:"id"`
+		Action      string    `json:"action"`
+		URL         string    `json:"url"`
+		PreviousURL string    `json:"previous_url,omitempty"`
+		UserID      string    `json:"user_id,omitempty"`
+		Time        time.Time `json:"time"`
+	}
+
+	resp := make([]respRevision, 0, len(revisions))
+	for _, r := range revisions {
+		resp = append(resp, respRevision{
+			ID:          r.ID,
+			Action:      r.Action,
+			URL:         r.URL,
+			PreviousURL: r.PreviousURL,
+			UserID:      r.UserID,
+			Time:        r.Time,
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(resp); err != nil {
+		logrus.Errorf("failed encode response to json: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logrus.Errorf("failed write response: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// RestoreURL возвращает ссылке исходный URL из ревизии истории и отменяет её удаление
+// в теле запроса передается id ревизии в поле revision, без тела ссылка только восстанавливается после удаления
+func (h *Handlers) RestoreURL(w http.ResponseWriter, req *http.Request) {
+	user, ok := entity.IdentityFromContext(req.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body := struct {
+		Revision int64 `json:"revision"`
+	}{}
+
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	code := chi.URLParam(req, "code")
+	if err := h.service.RestoreURL(req.Context(), user.UserID, code, body.Revision); err != nil {
+		h.revisionError(w, code, err)
+		return
+	}
+
+	resp, err := h.shortenURLResponse(code)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// revisionError пишет в ответ код ошибки истории или восстановления ссылки
+func (h *Handlers) revisionError(w http.ResponseWriter, code string, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	case errors.Is(err, service.ErrURLAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrRevisionsNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		logrus.Errorf("failed to handle revisions of code [%s]: %s", code, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
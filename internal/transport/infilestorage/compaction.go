@@ -0,0 +1,194 @@
+package infilestorage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lks-go/url-shortener/pkg/fs"
+)
+
+// compactFileSuffix is appended to the URLs file name to get the temporary file of compaction
+const compactFileSuffix = ".compact"
+
+// Start runs periodic syncing and compaction of the file until Stop is called
+func (s *Storage) Start() {
+	compactTicker := time.NewTicker(s.cfg.CompactInterval)
+	defer compactTicker.Stop()
+
+	syncTicker := time.NewTicker(s.cfg.SyncInterval)
+	defer syncTicker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-syncTicker.C:
+			if s.cfg.SyncPolicy != SyncInterval {
+				continue
+			}
+
+			if err := s.sync(); err != nil {
+				logrus.Errorf("failed to sync file storage: %s", err)
+			}
+		case <-compactTicker.C:
+			if err := s.Compact(); err != nil {
+				logrus.Errorf("failed to compact file storage: %s", err)
+			}
+		}
+	}
+}
+
+// Stop stops the background work, syncs and closes the file
+// it must be called after all writers are finished
+func (s *Storage) Stop() error {
+	close(s.stop)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+
+	return s.log.Close()
+}
+
+// Compact rewrites the file with the live set of records if the file contains redundant records
+// the live set is written to a temporary file which atomically replaces the current one
+// it does nothing after Stop because the file is closed
+func (s *Storage) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped() {
+		return nil
+	}
+
+	records := s.liveRecords()
+	if len(records) >= s.records {
+		return nil
+	}
+
+	tmpFilename := s.urlsFilename + compactFileSuffix
+	if err := writeFile(tmpFilename, records); err != nil {
+		return fmt.Errorf("failed to write compacted file: %w", err)
+	}
+
+	if err := os.Rename(tmpFilename, s.urlsFilename); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	if err := syncDir(filepath.Dir(s.urlsFilename)); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+
+	// the old descriptor refers to the replaced file
+	if err := s.log.Close(); err != nil {
+		logrus.Errorf("failed to close replaced file: %s", err)
+	}
+
+	producer, err := fs.NewProducer(s.urlsFilename)
+	if err != nil {
+		return fmt.Errorf("failed to get producer: %w", err)
+	}
+
+	logrus.Infof("file storage %s compacted from %d to %d records", s.urlsFilename, s.records, len(records))
+
+	s.log = producer
+	s.records = len(records)
+
+	return nil
+}
+
+// liveRecords returns the minimal list of records restoring the current state
+func (s *Storage) liveRecords() []*fs.Record {
+	codes := make([]string, 0, len(s.urls))
+	for code := range s.urls {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return s.urls[codes[i]].seq < s.urls[codes[j]].seq
+	})
+
+	records := make([]*fs.Record, 0, len(s.urls))
+	for _, code := range codes {
+		e := s.urls[code]
+		records = append(records, &fs.Record{
+			UUID:        e.uuid,
+			ShortURL:    code,
+			OriginalURL: e.url,
+			ExpiresAt:   expiresAtPtr(e.expiresAt),
+			Deleted:     e.deleted,
+		})
+	}
+
+	users := make([]string, 0, len(s.usersCodes))
+	for userID := range s.usersCodes {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+
+	for _, userID := range users {
+		for _, code := range s.usersCodes[userID] {
+			records = append(records, &fs.Record{ShortURL: code, UserID: userID})
+		}
+	}
+
+	return records
+}
+
+func (s *Storage) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped() {
+		return nil
+	}
+
+	return s.log.Sync()
+}
+
+// stopped reports if Stop is called, Stop closes the file under the lock after the stop channel is closed
+func (s *Storage) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func writeFile(filename string, records []*fs.Record) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale file: %w", err)
+	}
+
+	producer, err := fs.NewProducer(filename)
+	if err != nil {
+		return fmt.Errorf("failed to get producer: %w", err)
+	}
+	defer producer.Close()
+
+	for _, r := range records {
+		if err := producer.WriteRow(r); err != nil {
+			return fmt.Errorf("filed to write row: %w", err)
+		}
+	}
+
+	return producer.Sync()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
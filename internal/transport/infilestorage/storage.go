@@ -1,160 +1,507 @@
 package infilestorage
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/lks-go/url-shortener/internal/service"
 	"github.com/lks-go/url-shortener/pkg/fs"
 )
 
+// Sync policies of the file storage
+const (
+	// SyncAlways syncs the file after every write
+	SyncAlways = "always"
+	// SyncInterval syncs the file periodically while the storage is started
+	SyncInterval = "interval"
+	// SyncNever leaves syncing to the operating system
+	SyncNever = "never"
+)
+
 // Config of file storage
 type Config struct {
 	UrlsFilename string
+	// SyncPolicy is one of SyncAlways, SyncInterval and SyncNever, SyncInterval by default
+	SyncPolicy   string
+	SyncInterval time.Duration
+	// CompactInterval is a period of rewriting the file with the live set of records
+	CompactInterval time.Duration
+	// Recover allows to discard a torn trailing record instead of failing
+	Recover bool
+}
+
+// clicksFileSuffix is appended to the URLs file name to get the file of click events
+const clicksFileSuffix = ".clicks"
+
+// MustNew returns instance of Storage
+// if an errors occurs then panic happens
+func MustNew(cfg Config) *Storage {
+	s, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
 }
 
-// New creates a new instance of Storage
-func New(filename string) *Storage {
-	return &Storage{
-		urlsFilename: filename,
-		mu:           sync.Mutex{},
+// New creates a new instance of Storage and loads the state from the file
+// every mutation is appended to the file, so the file is a log of saves, ownerships and deletions
+func New(cfg Config) (*Storage, error) {
+	if cfg.SyncPolicy == "" {
+		cfg.SyncPolicy = SyncInterval
+	}
+
+	switch cfg.SyncPolicy {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q", cfg.SyncPolicy)
+	}
+
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Second
+	}
+
+	if cfg.CompactInterval <= 0 {
+		cfg.CompactInterval = 10 * time.Minute
+	}
+
+	s := &Storage{
+		cfg:            cfg,
+		urlsFilename:   cfg.UrlsFilename,
+		clicksFilename: cfg.UrlsFilename + clicksFileSuffix,
+		urls:           make(map[string]*urlEntry),
+		codes:          make(map[string]string),
+		usersCodes:     make(map[string][]string),
+		owners:         make(map[string]map[string]struct{}),
+		clickCounts:    make(map[string]int),
+		mu:             sync.RWMutex{},
+		clicksMu:       sync.Mutex{},
+		stop:           make(chan struct{}),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load file storage: %w", err)
+	}
+
+	if err := s.loadClickCounts(); err != nil {
+		return nil, fmt.Errorf("failed to load clicks: %w", err)
 	}
+
+	producer, err := fs.NewProducer(s.urlsFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get producer: %w", err)
+	}
+	s.log = producer
+
+	return s, nil
 }
 
 // Storage the main struct
 type Storage struct {
-	urlsFilename string
-	mu           sync.Mutex
+	cfg            Config
+	urlsFilename   string
+	clicksFilename string
+	log            *fs.Producer
+	stop           chan struct{}
+
+	// lastUUID is the last used UUID of the save record
+	lastUUID int
+	// records is count of records in the file, it's compared with the live set to decide on compaction
+	records int
+	// urls maps code to its URL and codes is the reverse index
+	urls  map[string]*urlEntry
+	codes map[string]string
+	// usersCodes keeps user's codes in order of saving and owners is used to check ownership
+	usersCodes map[string][]string
+	owners     map[string]map[string]struct{}
+
+	// clickCounts keeps counts of clicks of codes, so only raw events are read from the clicks file
+	clickCounts map[string]int
+
+	// mu guards indexes and the URLs file, clicksMu guards click counts and the clicks file
+	mu       sync.RWMutex
+	clicksMu sync.Mutex
 }
 
-// Save stores a new URL to file storage
-func (s *Storage) Save(ctx context.Context, id, url string) error {
+type urlEntry struct {
+	// seq is an order of saving
+	seq       int
+	uuid      string
+	url       string
+	expiresAt time.Time
+	deleted   bool
+}
 
-	l, err := s.recordList(s.urlsFilename)
-	if err != nil {
-		return fmt.Errorf("failed to get url list: %w", err)
+func (e *urlEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+// Save stores a new URL to file storage
+// returns service.ErrCodeAlreadyExists if the code is taken and service.ErrURLAlreadyExists if the URL is already saved
+func (s *Storage) Save(ctx context.Context, id, url string, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	r := fs.Record{
-		UUID:        strconv.Itoa(len(l) + 1),
-		ShortURL:    id,
-		OriginalURL: url,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNew(id, url); err != nil {
+		return err
 	}
 
-	if err := s.append(s.urlsFilename, &r); err != nil {
+	r := saveRecord(s.lastUUID+1, id, url, expiresAt)
+	if err := s.append(&r); err != nil {
 		return fmt.Errorf("failed to append row: %w", err)
 	}
 
+	s.apply(&r)
+
 	return nil
 }
 
-// Exists checks if URL already exists
-func (s *Storage) Exists(ctx context.Context, id string) (bool, error) {
+// SaveBatch stores array of URLs to file storage and makes the user their owner if the user is set
+// nothing is saved if any of codes or URLs already exists
+func (s *Storage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	l, err := s.recordList(s.urlsFilename)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := service.CheckBatch(urls,
+		func(code string) bool { _, ok := s.urls[code]; return ok },
+		func(url string) bool { _, ok := s.codes[url]; return ok },
+	)
 	if err != nil {
-		return false, fmt.Errorf("failed to get url list: %w", err)
+		return err
 	}
 
-	for _, row := range l {
-		if row.ShortURL == id {
-			return true, nil
-		}
+	records := make([]*fs.Record, 0, len(urls))
+	for i, u := range urls {
+		r := saveRecord(s.lastUUID+i+1, u.Code, u.OriginalURL, u.ExpiresAt)
+		r.UserID = userID
+		records = append(records, &r)
+	}
+
+	return s.appendAndApply(records)
+}
+
+// Exists checks if URL already exists
+func (s *Storage) Exists(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
 
-	return false, nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.urls[id]
+
+	return ok, nil
 }
 
 // URL returns URL by code
 func (s *Storage) URL(ctx context.Context, id string) (string, error) {
+	url, _, err := s.URLWithExpiry(ctx, id)
+	return url, err
+}
 
-	l, err := s.recordList(s.urlsFilename)
-	if err != nil {
-		return "", fmt.Errorf("failed to get url list: %w", err)
-	}
-
-	for _, row := range l {
-		if row.ShortURL == id {
-			return row.OriginalURL, nil
-		}
+// URLWithExpiry returns URL by code with the time when it expires, zero time means never
+func (s *Storage) URLWithExpiry(ctx context.Context, id string) (string, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return "", time.Time{}, err
 	}
 
-	return "", service.ErrNotFound
-}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-// SaveBatch stores array of URLs to file storage
-func (s *Storage) SaveBatch(ctx context.Context, url []service.URL) error {
-	l, err := s.recordList(s.urlsFilename)
-	if err != nil {
-		return fmt.Errorf("failed to get url list: %w", err)
+	e, ok := s.urls[id]
+	if !ok {
+		return "", time.Time{}, service.ErrNotFound
 	}
 
-	for _, u := range url {
-		r := fs.Record{
-			UUID:        strconv.Itoa(len(l) + 1),
-			ShortURL:    u.Code,
-			OriginalURL: u.OriginalURL,
-		}
+	if e.deleted {
+		return "", time.Time{}, service.ErrDeleted
+	}
 
-		if err := s.append(s.urlsFilename, &r); err != nil {
-			return fmt.Errorf("failed to append row: %w", err)
-		}
+	if e.expired(time.Now()) {
+		return "", time.Time{}, service.ErrExpired
 	}
 
-	return nil
+	return e.url, e.expiresAt, nil
 }
 
 // CodeByURL returns URLs code by URL
 func (s *Storage) CodeByURL(ctx context.Context, url string) (string, error) {
-	l, err := s.recordList(s.urlsFilename)
-	if err != nil {
-		return "", fmt.Errorf("failed to get url list: %w", err)
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
-	for _, row := range l {
-		if row.OriginalURL == url {
-			return row.ShortURL, nil
-		}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	code, ok := s.codes[url]
+	if !ok {
+		return "", service.ErrNotFound
 	}
 
-	return "", service.ErrNotFound
+	return code, nil
 }
 
 // SaveUsersCode stores owner and code of URL to file storage
+// returns service.ErrRecordAlreadyExists if the code already belongs to the user
 func (s *Storage) SaveUsersCode(ctx context.Context, userID string, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.owners[userID][code]; ok {
+		return service.ErrRecordAlreadyExists
+	}
+
+	r := fs.Record{ShortURL: code, UserID: userID}
+	if err := s.append(&r); err != nil {
+		return fmt.Errorf("failed to append row: %w", err)
+	}
+
+	s.apply(&r)
+
 	return nil
 }
 
 // UsersURLCodes returns codes of user's URLs
 func (s *Storage) UsersURLCodes(ctx context.Context, userID string) ([]string, error) {
-	return []string{}, nil
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	codes := make([]string, len(s.usersCodes[userID]))
+	copy(codes, s.usersCodes[userID])
+
+	return codes, nil
+}
+
+// DeleteURLs marks URLs as deleted by codes
+func (s *Storage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]*fs.Record, 0, len(codes))
+	for _, code := range codes {
+		if e, ok := s.urls[code]; ok && !e.deleted {
+			records = append(records, &fs.Record{ShortURL: code, Deleted: true})
+		}
+	}
+
+	return s.appendAndApply(records)
+}
+
+// UpdateURL appends a record with the new URL of the code
+func (s *Storage) UpdateURL(ctx context.Context, userID, code, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.urls[code]
+	if !ok {
+		return service.ErrNotFound
+	}
+
+	if e.deleted {
+		return service.ErrDeleted
+	}
+
+	if e.url == url {
+		return nil
+	}
+
+	if _, ok := s.codes[url]; ok {
+		return service.ErrURLAlreadyExists
+	}
+
+	r := fs.Record{ShortURL: code, OriginalURL: url}
+	if err := s.append(&r); err != nil {
+		return fmt.Errorf("failed to append row: %w", err)
+	}
+
+	s.apply(&r)
+
+	return nil
+}
+
+// UsersURLs returns a page of user's URLs, they are filtered and sorted in memory
+func (s *Storage) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	urls := make([]service.UsersURL, 0)
+	err := s.IterateUsersURLs(ctx, userID, func(u service.UsersURL) error {
+		urls = append(urls, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.clicksMu.Lock()
+	for i := range urls {
+		urls[i].Clicks = s.clickCounts[urls[i].Code]
+	}
+	s.clicksMu.Unlock()
+
+	return service.PageUsersURLs(urls, q), nil
 }
 
-// DeleteURLs removes URLs from storage by codes
-func (s *Storage) DeleteURLs(ctx context.Context, codes []string) error {
+// IterateUsersURLs calls fn for every URL of the user in order of saving
+// the lock isn't held while fn is called, so a slow consumer doesn't block writers
+func (s *Storage) IterateUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	codes, err := s.UsersURLCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		u, ok := s.usersURL(code)
+		if !ok {
+			continue
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// UsersURLs returns list of user's URLs
-func (s *Storage) UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error) {
-	return []service.UsersURL{}, nil
+func (s *Storage) usersURL(code string) (service.UsersURL, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.urls[code]
+	if !ok {
+		return service.UsersURL{}, false
+	}
+
+	return service.UsersURL{Code: code, OriginalURL: e.url, ExpiresAt: e.expiresAt, Deleted: e.deleted}, true
+}
+
+// URLCount returns count of URLs which are not deleted
+func (s *Storage) URLCount(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cnt := 0
+	for _, e := range s.urls {
+		if !e.deleted {
+			cnt++
+		}
+	}
+
+	return cnt, nil
+}
+
+// UserCount returns count of users owning at least one code
+func (s *Storage) UserCount(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.usersCodes), nil
 }
 
-func (s *Storage) recordList(fileName string) ([]fs.Record, error) {
+// DeleteExpiredURLs marks as deleted all URLs which expiration time has come
+// returns count of deleted URLs
+func (s *Storage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	consumer, err := fs.NewConsumer(fileName)
+	now := time.Now()
+	records := make([]*fs.Record, 0)
+	for code, e := range s.urls {
+		if !e.deleted && e.expired(now) {
+			records = append(records, &fs.Record{ShortURL: code, Deleted: true})
+		}
+	}
+
+	if err := s.appendAndApply(records); err != nil {
+		return 0, err
+	}
+
+	return len(records), nil
+}
+
+func (s *Storage) checkNew(code, url string) error {
+	if _, ok := s.urls[code]; ok {
+		return service.ErrCodeAlreadyExists
+	}
+
+	if _, ok := s.codes[url]; ok {
+		return service.ErrURLAlreadyExists
+	}
+
+	return nil
+}
+
+func saveRecord(uuid int, code, url string, expiresAt time.Time) fs.Record {
+	return fs.Record{
+		UUID:        strconv.Itoa(uuid),
+		ShortURL:    code,
+		OriginalURL: url,
+		ExpiresAt:   expiresAtPtr(expiresAt),
+	}
+}
+
+// load replays the records of the file into the indexes
+func (s *Storage) load() error {
+	consumer, err := fs.NewConsumer(s.urlsFilename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get consumer: %w", err)
+		return fmt.Errorf("failed to get consumer: %w", err)
 	}
 	defer consumer.Close()
 
-	rows := make([]fs.Record, 0)
 	for {
+		offset := consumer.Offset()
+
 		rec := fs.Record{}
 		err := consumer.ReadRow(&rec)
 		if err != nil {
@@ -162,38 +509,310 @@ func (s *Storage) recordList(fileName string) ([]fs.Record, error) {
 				break
 			}
 
-			return nil, fmt.Errorf("failed to read record: %w", err)
+			if !s.cfg.Recover {
+				return fmt.Errorf("failed to read record: %w", err)
+			}
+
+			if err := s.discardTail(offset); err != nil {
+				return fmt.Errorf("failed to read record: %w", err)
+			}
+
+			logrus.Warnf("file storage %s: torn trailing record at offset %d has been discarded", s.urlsFilename, offset)
+			break
 		}
 
-		rows = append(rows, rec)
+		s.apply(&rec)
 	}
 
-	return rows, nil
+	return nil
 }
 
-func (s *Storage) append(fileName string, r *fs.Record) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// discardTail truncates the file from the offset if the rest of the file is a single torn record
+func (s *Storage) discardTail(offset int64) error {
+	f, err := os.OpenFile(s.urlsFilename, os.O_RDWR, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek: %w", err)
+	}
 
-	producer, err := fs.NewProducer(fileName)
+	tail, err := io.ReadAll(f)
 	if err != nil {
-		return fmt.Errorf("failed to get producer: %w", err)
+		return fmt.Errorf("failed to read tail: %w", err)
 	}
-	defer producer.Close()
 
-	if err := producer.WriteRow(r); err != nil {
-		return fmt.Errorf("filed to write row: %w", err)
+	// the line break of the last good record is kept
+	if len(tail) > 0 && tail[0] == '\n' {
+		offset++
+	}
+
+	if bytes.Contains(bytes.TrimSpace(tail), []byte("\n")) {
+		return errors.New("file is corrupted not only at the end")
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate: %w", err)
+	}
+
+	return f.Sync()
+}
+
+// apply changes the indexes according to the record
+// a record with an original URL is a save, a record with a user is an ownership and a deleted record without URL is a tombstone
+// a record with an original URL of a saved code is an update, the rest of the entry is kept
+func (s *Storage) apply(r *fs.Record) {
+	s.records++
+
+	if r.OriginalURL == "" && r.Deleted {
+		if e, ok := s.urls[r.ShortURL]; ok {
+			e.deleted = true
+		}
+		return
+	}
+
+	switch e, ok := s.urls[r.ShortURL]; {
+	case ok && r.OriginalURL != "":
+		if s.codes[e.url] == r.ShortURL {
+			delete(s.codes, e.url)
+		}
+		e.url = r.OriginalURL
+
+		if _, ok := s.codes[r.OriginalURL]; !ok {
+			s.codes[r.OriginalURL] = r.ShortURL
+		}
+	case r.OriginalURL != "":
+		e := &urlEntry{seq: len(s.urls), uuid: r.UUID, url: r.OriginalURL, deleted: r.Deleted}
+		if r.ExpiresAt != nil {
+			e.expiresAt = *r.ExpiresAt
+		}
+		s.urls[r.ShortURL] = e
+
+		if _, ok := s.codes[r.OriginalURL]; !ok {
+			s.codes[r.OriginalURL] = r.ShortURL
+		}
+
+		if uuid, err := strconv.Atoi(r.UUID); err == nil && uuid > s.lastUUID {
+			s.lastUUID = uuid
+		}
+	}
+
+	if r.UserID != "" {
+		owned, ok := s.owners[r.UserID]
+		if !ok {
+			owned = make(map[string]struct{})
+			s.owners[r.UserID] = owned
+		}
+
+		if _, ok := owned[r.ShortURL]; !ok {
+			owned[r.ShortURL] = struct{}{}
+			s.usersCodes[r.UserID] = append(s.usersCodes[r.UserID], r.ShortURL)
+		}
+	}
+}
+
+func (s *Storage) appendAndApply(records []*fs.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := s.append(records...); err != nil {
+		return fmt.Errorf("failed to append rows: %w", err)
+	}
+
+	for _, r := range records {
+		s.apply(r)
 	}
 
 	return nil
 }
 
-// URLCount blank
-func (s *Storage) URLCount(ctx context.Context) (int, error) {
-	return 0, nil
+func (s *Storage) append(records ...*fs.Record) error {
+	for _, r := range records {
+		if err := s.log.WriteRow(r); err != nil {
+			return fmt.Errorf("filed to write row: %w", err)
+		}
+	}
+
+	if s.cfg.SyncPolicy == SyncAlways {
+		if err := s.log.Sync(); err != nil {
+			return fmt.Errorf("failed to sync: %w", err)
+		}
+	}
+
+	return nil
 }
 
-// UserCount blank
-func (s *Storage) UserCount(ctx context.Context) (int, error) {
-	return 0, nil
+func expiresAtPtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+type clickRecord struct {
+	Code           string    `json:"code"`
+	Time           time.Time `json:"time"`
+	Referrer       string    `json:"referrer,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	IP             string    `json:"ip,omitempty"`
+	ReferrerDomain string    `json:"referrer_domain,omitempty"`
+	Browser        string    `json:"browser,omitempty"`
+	OS             string    `json:"os,omitempty"`
+}
+
+// SaveClicks appends click events to the clicks file
+func (s *Storage) SaveClicks(ctx context.Context, clicks []service.Click) error {
+	s.clicksMu.Lock()
+	defer s.clicksMu.Unlock()
+
+	f, err := os.OpenFile(s.clicksFilename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open clicks file: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, c := range clicks {
+		r := clickRecord{
+			Code:           c.Code,
+			Time:           c.Time,
+			Referrer:       c.Referrer,
+			UserAgent:      c.UserAgent,
+			IP:             c.IP,
+			ReferrerDomain: c.ReferrerDomain,
+			Browser:        c.Browser,
+			OS:             c.OS,
+		}
+		if err := encoder.Encode(&r); err != nil {
+			return fmt.Errorf("failed to write click: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush clicks: %w", err)
+	}
+
+	for _, c := range clicks {
+		s.clickCounts[c.Code]++
+	}
+
+	return nil
+}
+
+// ClickCount returns count of clicks of the code
+func (s *Storage) ClickCount(ctx context.Context, code string) (int, error) {
+	exists, err := s.Exists(ctx, code)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check code: %w", err)
+	}
+
+	if !exists {
+		return 0, service.ErrNotFound
+	}
+
+	s.clicksMu.Lock()
+	defer s.clicksMu.Unlock()
+
+	return s.clickCounts[code], nil
+}
+
+// Clicks returns the latest click events of the code
+// if limit isn't positive returns all events
+func (s *Storage) Clicks(ctx context.Context, code string, limit int) ([]service.Click, error) {
+	clicks, err := s.clickList(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click list: %w", err)
+	}
+
+	sort.SliceStable(clicks, func(i, j int) bool {
+		return clicks[i].Time.After(clicks[j].Time)
+	})
+
+	if limit > 0 && limit < len(clicks) {
+		clicks = clicks[:limit]
+	}
+
+	return clicks, nil
+}
+
+// ClickStats aggregates clicks of the code in the range of the query
+func (s *Storage) ClickStats(ctx context.Context, q service.ClickStatsQuery) (*service.ClickStats, error) {
+	clicks, err := s.clickList(q.Code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click list: %w", err)
+	}
+
+	return service.AggregateClicks(clicks, q), nil
+}
+
+// loadClickCounts counts clicks of codes by a single pass over the clicks file
+func (s *Storage) loadClickCounts() error {
+	s.clicksMu.Lock()
+	defer s.clicksMu.Unlock()
+
+	return s.readClicks(func(r *clickRecord) {
+		s.clickCounts[r.Code]++
+	})
+}
+
+// clickList returns click events of the code, the clicks file is read only if the code has clicks
+func (s *Storage) clickList(code string) ([]service.Click, error) {
+	s.clicksMu.Lock()
+	defer s.clicksMu.Unlock()
+
+	clicks := make([]service.Click, 0, s.clickCounts[code])
+	if s.clickCounts[code] == 0 {
+		return clicks, nil
+	}
+
+	err := s.readClicks(func(r *clickRecord) {
+		if r.Code == code {
+			clicks = append(clicks, service.Click{
+				Code:           r.Code,
+				Time:           r.Time,
+				Referrer:       r.Referrer,
+				UserAgent:      r.UserAgent,
+				IP:             r.IP,
+				ReferrerDomain: r.ReferrerDomain,
+				Browser:        r.Browser,
+				OS:             r.OS,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clicks, nil
+}
+
+// readClicks calls fn for every record of the clicks file, clicksMu must be held
+func (s *Storage) readClicks(fn func(r *clickRecord)) error {
+	f, err := os.Open(s.clicksFilename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to open clicks file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	for {
+		r := clickRecord{}
+		if err := decoder.Decode(&r); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return fmt.Errorf("failed to read click: %w", err)
+		}
+
+		fn(&r)
+	}
 }
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,6 +15,7 @@ import (
 	"github.com/lks-go/url-shortener/internal/lib/random"
 	"github.com/lks-go/url-shortener/internal/service"
 	"github.com/lks-go/url-shortener/internal/transport/infilestorage"
+	"github.com/lks-go/url-shortener/internal/transport/storagetest"
 	"github.com/lks-go/url-shortener/pkg/fs"
 )
 
@@ -66,7 +69,7 @@ func TestStorage_Exists(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			s := infilestorage.New(testFileName)
+			s := infilestorage.MustNew(infilestorage.Config{UrlsFilename: testFileName})
 			got, err := s.Exists(context.Background(), tt.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Exists() error = %v, wantErr %v", err, tt.wantErr)
@@ -99,9 +102,9 @@ func TestStorage_Save(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := infilestorage.New(testFileName)
+			s := infilestorage.MustNew(infilestorage.Config{UrlsFilename: testFileName})
 
-			if err := s.Save(context.Background(), tt.id, tt.url); (err != nil) != tt.wantErr {
+			if err := s.Save(context.Background(), tt.id, tt.url, time.Time{}); (err != nil) != tt.wantErr {
 				t.Errorf("Save() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -146,7 +149,7 @@ func TestStorage_URL(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := infilestorage.New(testFileName)
+			s := infilestorage.MustNew(infilestorage.Config{UrlsFilename: testFileName})
 			got, err := s.URL(context.Background(), tt.id)
 			if tt.wantErr {
 				require.ErrorIs(t, err, service.ErrNotFound)
@@ -158,3 +161,247 @@ func TestStorage_URL(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.RunURLStorage(t, func(t *testing.T) service.URLStorage {
+		return infilestorage.MustNew(infilestorage.Config{UrlsFilename: filepath.Join(t.TempDir(), "storage")})
+	})
+}
+
+func TestStorage_Restart(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "storage")
+
+	s := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName})
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.SaveBatch(ctx, "", []service.URL{
+		{Code: "xyz", OriginalURL: "https://google.com"},
+		{Code: "old", OriginalURL: "https://example.com", ExpiresAt: time.Now().Add(-time.Minute)},
+	}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "xyz"))
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"xyz"}))
+
+	cnt, err := s.DeleteExpiredURLs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, cnt)
+
+	restarted := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName})
+
+	url, err := restarted.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	_, err = restarted.URL(ctx, "xyz")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	_, err = restarted.URL(ctx, "old")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	code, err := restarted.CodeByURL(ctx, "https://google.com")
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", code)
+
+	codes, err := restarted.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "xyz"}, codes)
+
+	cnt, err = restarted.URLCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	cnt, err = restarted.UserCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	require.NoError(t, restarted.Save(ctx, "new", "https://new.com", time.Time{}))
+	require.ErrorIs(t, restarted.Save(ctx, "abc", "https://other.com", time.Time{}), service.ErrCodeAlreadyExists)
+}
+
+func TestStorage_Compact(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "storage")
+	cfg := infilestorage.Config{UrlsFilename: fileName, SyncPolicy: infilestorage.SyncAlways}
+
+	s := infilestorage.MustNew(cfg)
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "xyz"))
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"xyz"}))
+
+	before, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Compact())
+
+	after, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	assert.Less(t, len(after), len(before))
+
+	require.NoError(t, s.Save(ctx, "new", "https://new.com", time.Time{}))
+	require.NoError(t, s.Stop())
+
+	restarted := infilestorage.MustNew(cfg)
+
+	url, err := restarted.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	url, err = restarted.URL(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.com", url)
+
+	_, err = restarted.URL(ctx, "xyz")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	codes, err := restarted.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "xyz"}, codes)
+}
+
+func TestStorage_Clicks(t *testing.T) {
+	ctx := context.Background()
+	cfg := infilestorage.Config{UrlsFilename: filepath.Join(t.TempDir(), "storage"), SyncPolicy: infilestorage.SyncAlways}
+	now := time.Now().UTC()
+
+	s := infilestorage.MustNew(cfg)
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "xyz"))
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{
+		{Code: "abc", Time: now.Add(-time.Minute), IP: "10.0.0.1"},
+		{Code: "abc", Time: now, IP: "10.0.0.2"},
+	}))
+	require.NoError(t, s.Stop())
+
+	// counts are restored from the clicks file and kept up to date in memory
+	restarted := infilestorage.MustNew(cfg)
+	require.NoError(t, restarted.SaveClicks(ctx, []service.Click{{Code: "abc", Time: now.Add(time.Second), IP: "10.0.0.3"}}))
+
+	cnt, err := restarted.ClickCount(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 3, cnt)
+
+	page, err := restarted.UsersURLs(ctx, "user1", service.UsersURLsQuery{Sort: service.UsersURLsSortClicks, Desc: true})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 2)
+	assert.Equal(t, "abc", page.URLs[0].Code)
+	assert.Equal(t, 3, page.URLs[0].Clicks)
+	assert.Equal(t, 0, page.URLs[1].Clicks)
+
+	latest, err := restarted.Clicks(ctx, "abc", 1)
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	assert.Equal(t, "10.0.0.3", latest[0].IP)
+
+	none, err := restarted.Clicks(ctx, "xyz", 0)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestStorage_CompactAfterStop(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "storage")
+
+	s := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName, SyncPolicy: infilestorage.SyncAlways})
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"abc"}))
+	require.NoError(t, s.Stop())
+
+	before, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Compact(), "compaction after stop must not touch the closed file")
+
+	after, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestStorage_Recover(t *testing.T) {
+	good := `{"uuid":"1","short_url":"abc","original_url":"https://ya.ru"}` + "\n"
+
+	tests := []struct {
+		name     string
+		data     string
+		recover  bool
+		wantErr  bool
+		wantData string
+	}{
+		{
+			name:     "torn trailing record is discarded",
+			data:     good + `{"uuid":"2","short_url":"xy`,
+			recover:  true,
+			wantData: good,
+		},
+		{
+			name:    "torn trailing record fails without recovery",
+			data:    good + `{"uuid":"2","short_url":"xy`,
+			wantErr: true,
+		},
+		{
+			name:    "corrupted record in the middle fails",
+			data:    good + `{"uuid":"2",` + "\n" + good,
+			recover: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "storage")
+			require.NoError(t, os.WriteFile(fileName, []byte(tt.data), 0666))
+
+			s, err := infilestorage.New(infilestorage.Config{UrlsFilename: fileName, Recover: tt.recover})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := os.ReadFile(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantData, string(b))
+
+			require.NoError(t, s.Save(context.Background(), "xyz", "https://google.com", time.Time{}))
+
+			url, err := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName}).URL(context.Background(), "xyz")
+			require.NoError(t, err)
+			assert.Equal(t, "https://google.com", url)
+		})
+	}
+}
+
+func TestStorage_UpdateURLRestart(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "storage")
+
+	s := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName})
+	require.NoError(t, s.SaveBatch(ctx, "user1", []service.URL{
+		{Code: "abc", OriginalURL: "https://ya.ru"},
+		{Code: "xyz", OriginalURL: "https://google.com"},
+	}))
+	require.NoError(t, s.UpdateURL(ctx, "user1", "abc", "https://ya.com"))
+
+	restarted := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName})
+
+	url, err := restarted.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.com", url)
+
+	_, err = restarted.CodeByURL(ctx, "https://ya.ru")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	codes, err := restarted.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "xyz"}, codes, "the order of saving must be kept")
+
+	require.NoError(t, restarted.Compact())
+
+	compacted := infilestorage.MustNew(infilestorage.Config{UrlsFilename: fileName})
+
+	url, err = compacted.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.com", url)
+}
@@ -0,0 +1,163 @@
+package inmemstorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// CreateAccount stores a new account
+// returns service.ErrLoginTaken if the login belongs to another account
+func (s *Storage) CreateAccount(ctx context.Context, a service.Account) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.logins[a.Login]; ok {
+		return service.ErrLoginTaken
+	}
+
+	s.accounts[a.ID] = a
+	s.logins[a.Login] = a.ID
+
+	return nil
+}
+
+// Account returns account by ID
+func (s *Storage) Account(ctx context.Context, id string) (*service.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+
+	return &a, nil
+}
+
+// AccountByLogin returns account by login
+func (s *Storage) AccountByLogin(ctx context.Context, login string) (*service.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.logins[login]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+
+	a := s.accounts[id]
+
+	return &a, nil
+}
+
+// SaveAPIKey stores API key
+func (s *Storage) SaveAPIKey(ctx context.Context, k service.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeys[k.Hash] = k
+
+	return nil
+}
+
+// APIKeyByHash returns API key by hash of the key
+func (s *Storage) APIKeyByHash(ctx context.Context, hash string) (*service.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.apiKeys[hash]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+
+	return &k, nil
+}
+
+// APIKeys returns API keys of the user in order of creation
+func (s *Storage) APIKeys(ctx context.Context, userID string) ([]service.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]service.APIKey, 0)
+	for _, k := range s.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	return keys, nil
+}
+
+// DeleteAPIKey deletes API key of the user
+func (s *Storage) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, k := range s.apiKeys {
+		if k.ID == id && k.UserID == userID {
+			delete(s.apiKeys, hash)
+			return nil
+		}
+	}
+
+	return service.ErrNotFound
+}
+
+// ClaimURLs moves codes and revisions of the user to another user, codes already owned by the target are skipped
+func (s *Storage) ClaimURLs(ctx context.Context, fromUserID, toUserID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := s.usersCodes[fromUserID]
+	for _, code := range codes {
+		if _, ok := s.owners[toUserID][code]; !ok {
+			s.saveUsersCode(toUserID, code)
+		}
+
+		for i := range s.revisions[code] {
+			if s.revisions[code][i].UserID == fromUserID {
+				s.revisions[code][i].UserID = toUserID
+			}
+		}
+	}
+
+	delete(s.usersCodes, fromUserID)
+	delete(s.owners, fromUserID)
+
+	return len(codes), nil
+}
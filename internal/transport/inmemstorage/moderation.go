@@ -0,0 +1,101 @@
+package inmemstorage
+
+import (
+	"context"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// URLInfo returns the code with its owner, the owner is the author of the creation
+// returns service.ErrNotFound if the code doesn't exist
+func (s *Storage) URLInfo(ctx context.Context, code string) (*service.URLInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.usersURL(code)
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+
+	_, blocked := s.blocked[code]
+	info := &service.URLInfo{
+		Code:        code,
+		OriginalURL: u.OriginalURL,
+		ExpiresAt:   u.ExpiresAt,
+		Deleted:     u.Deleted,
+		Blocked:     blocked,
+	}
+	if revisions := s.revisions[code]; len(revisions) > 0 {
+		info.OwnerID, info.CreatedAt = revisions[0].UserID, revisions[0].Time
+	}
+
+	return info, nil
+}
+
+// SetURLBlocked blocks or unblocks the code and saves the revision if the state is changed
+// returns service.ErrNotFound if the code doesn't exist
+func (s *Storage) SetURLBlocked(ctx context.Context, userID, code string, blocked bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.shortenURLs[code]
+	if !ok {
+		return service.ErrNotFound
+	}
+
+	if _, ok := s.blocked[code]; ok == blocked {
+		return nil
+	}
+
+	action := service.RevisionUnblocked
+	if blocked {
+		action = service.RevisionBlocked
+		s.blocked[code] = struct{}{}
+	} else {
+		delete(s.blocked, code)
+	}
+
+	s.addRevision(service.Revision{Code: code, Action: action, URL: url, PreviousURL: url, UserID: userID})
+
+	return nil
+}
+
+// SetUserBanned bans or unbans the user
+func (s *Storage) SetUserBanned(ctx context.Context, userID string, banned bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if banned {
+		s.banned[userID] = struct{}{}
+	} else {
+		delete(s.banned, userID)
+	}
+
+	return nil
+}
+
+// UserBanned checks if the user is banned
+func (s *Storage) UserBanned(ctx context.Context, userID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.banned[userID]
+
+	return ok, nil
+}
@@ -3,7 +3,9 @@ package inmemstorage
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/lks-go/url-shortener/internal/service"
 )
@@ -26,42 +28,134 @@ func New(memStoreShortenURLs map[string]string) (*Storage, error) {
 		return nil, errors.New("memory storage of shorten URL must not be nil")
 	}
 
+	codes := make(map[string]string, len(memStoreShortenURLs))
+	for code, url := range memStoreShortenURLs {
+		codes[url] = code
+	}
+
 	return &Storage{
 		shortenURLs: memStoreShortenURLs,
+		codes:       codes,
+		expiresAt:   make(map[string]time.Time),
+		deleted:     make(map[string]struct{}),
+		usersCodes:  make(map[string][]string),
+		owners:      make(map[string]map[string]struct{}),
+		clicks:      make(map[string][]service.Click),
+		revisions:   make(map[string][]service.Revision),
+		accounts:    make(map[string]service.Account),
+		logins:      make(map[string]string),
+		apiKeys:     make(map[string]service.APIKey),
+		blocked:     make(map[string]struct{}),
+		banned:      make(map[string]struct{}),
 		mu:          sync.RWMutex{},
 	}, nil
 }
 
 // Storage the main struct implementing the storage
 type Storage struct {
+	// shortenURLs maps code to URL and codes is its reverse index
 	shortenURLs map[string]string
-	mu          sync.RWMutex
+	codes       map[string]string
+	expiresAt   map[string]time.Time
+	deleted     map[string]struct{}
+	// usersCodes keeps user's codes in order of saving and owners is used to check ownership
+	usersCodes map[string][]string
+	owners     map[string]map[string]struct{}
+	clicks     map[string][]service.Click
+	// revisions keeps changes of every code in order of making, revisionSeq is the ID of the last revision
+	revisions   map[string][]service.Revision
+	revisionSeq int64
+	// accounts maps ID to account and logins is its index by login, apiKeys maps hash to API key
+	accounts map[string]service.Account
+	logins   map[string]string
+	apiKeys  map[string]service.APIKey
+	// blocked are codes blocked by admins and banned are IDs of banned users
+	blocked map[string]struct{}
+	banned  map[string]struct{}
+	mu      sync.RWMutex
 }
 
-// Save stores a new URL to file storage
-func (s *Storage) Save(ctx context.Context, url, id string) error {
+// Save stores a new URL to memory storage
+// returns service.ErrCodeAlreadyExists if the code is taken and service.ErrURLAlreadyExists if the URL is already saved
+func (s *Storage) Save(ctx context.Context, id, url string, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.shortenURLs[id] = url
+	if err := s.checkNew(id, url); err != nil {
+		return err
+	}
+
+	s.save(id, url, expiresAt)
 
 	return nil
 }
 
-// SaveBatch stores array of URLs to file storage
-func (s *Storage) SaveBatch(ctx context.Context, url []service.URL) error {
+// SaveBatch stores array of URLs to memory storage and makes the user their owner if the user is set
+// nothing is saved if any of codes or URLs already exists
+func (s *Storage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for _, u := range url {
-		s.shortenURLs[u.Code] = u.OriginalURL
+	err := service.CheckBatch(urls,
+		func(code string) bool { _, ok := s.shortenURLs[code]; return ok },
+		func(url string) bool { _, ok := s.codes[url]; return ok },
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range urls {
+		s.save(u.Code, u.OriginalURL, u.ExpiresAt)
+		if userID != "" {
+			s.saveUsersCode(userID, u.Code)
+		}
 	}
 
 	return nil
 }
 
+func (s *Storage) checkNew(code, url string) error {
+	if _, ok := s.shortenURLs[code]; ok {
+		return service.ErrCodeAlreadyExists
+	}
+
+	if _, ok := s.codes[url]; ok {
+		return service.ErrURLAlreadyExists
+	}
+
+	return nil
+}
+
+func (s *Storage) save(code, url string, expiresAt time.Time) {
+	s.shortenURLs[code] = url
+	s.codes[url] = code
+	if !expiresAt.IsZero() {
+		s.expiresAt[code] = expiresAt
+	}
+
+	s.addRevision(service.Revision{Code: code, Action: service.RevisionCreated, URL: url})
+}
+
+// addRevision must be called under the lock
+func (s *Storage) addRevision(r service.Revision) {
+	s.revisionSeq++
+	r.ID, r.Time = s.revisionSeq, time.Now()
+	s.revisions[r.Code] = append(s.revisions[r.Code], r)
+}
+
 // Exists checks if URL already exists
 func (s *Storage) Exists(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -73,59 +167,395 @@ func (s *Storage) Exists(ctx context.Context, id string) (bool, error) {
 
 // URL returns URL by code
 func (s *Storage) URL(ctx context.Context, id string) (string, error) {
+	url, _, err := s.URLWithExpiry(ctx, id)
+	return url, err
+}
+
+// URLWithExpiry returns URL by code with the time when it expires, zero time means never
+func (s *Storage) URLWithExpiry(ctx context.Context, id string) (string, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	url, ok := s.shortenURLs[id]
 	if !ok {
-		return "", service.ErrNotFound
+		return "", time.Time{}, service.ErrNotFound
 	}
 
-	return url, nil
+	if _, ok := s.deleted[id]; ok {
+		return "", time.Time{}, service.ErrDeleted
+	}
+
+	if _, ok := s.blocked[id]; ok {
+		return "", time.Time{}, service.ErrBlocked
+	}
+
+	expiresAt := s.expiresAt[id]
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return "", time.Time{}, service.ErrExpired
+	}
+
+	return url, expiresAt, nil
 }
 
 // CodeByURL returns URLs code by URL
 func (s *Storage) CodeByURL(ctx context.Context, url string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for code, u := range s.shortenURLs {
-		if u == url {
-			return code, nil
+	code, ok := s.codes[url]
+	if !ok {
+		return "", service.ErrNotFound
+	}
+
+	return code, nil
+}
+
+// DeleteURLs marks URLs as deleted by codes
+func (s *Storage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range codes {
+		url, ok := s.shortenURLs[code]
+		if !ok {
+			continue
 		}
+
+		if _, ok := s.deleted[code]; ok {
+			continue
+		}
+
+		s.deleted[code] = struct{}{}
+		s.addRevision(service.Revision{Code: code, Action: service.RevisionDeleted, URL: url, PreviousURL: url, UserID: userID})
 	}
 
-	return "", service.ErrNotFound
+	return nil
 }
 
-// DeleteURLs removes URLs from storage by codes
-func (s *Storage) DeleteURLs(ctx context.Context, codes []string) error {
+// UpdateURL replaces URL of the code and moves the code in the reverse index
+func (s *Storage) UpdateURL(ctx context.Context, userID, code, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.shortenURLs[code]
+	if !ok {
+		return service.ErrNotFound
+	}
+
+	if _, ok := s.deleted[code]; ok {
+		return service.ErrDeleted
+	}
+
+	if prev == url {
+		return nil
+	}
+
+	if _, ok := s.codes[url]; ok {
+		return service.ErrURLAlreadyExists
+	}
+
+	delete(s.codes, prev)
+	s.shortenURLs[code] = url
+	s.codes[url] = code
+
+	s.addRevision(service.Revision{Code: code, Action: service.RevisionUpdated, URL: url, PreviousURL: prev, UserID: userID})
+
 	return nil
 }
 
-// SaveUsersCode stores owner and code of URL to file storage
+// SaveUsersCode stores owner and code of URL to memory storage
+// returns service.ErrRecordAlreadyExists if the code already belongs to the user
 func (s *Storage) SaveUsersCode(ctx context.Context, userID string, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.owners[userID][code]; ok {
+		return service.ErrRecordAlreadyExists
+	}
+
+	s.saveUsersCode(userID, code)
 
 	return nil
 }
 
+func (s *Storage) saveUsersCode(userID, code string) {
+	owned, ok := s.owners[userID]
+	if !ok {
+		owned = make(map[string]struct{})
+		s.owners[userID] = owned
+	}
+
+	owned[code] = struct{}{}
+	s.usersCodes[userID] = append(s.usersCodes[userID], code)
+
+	// the first user of the code is the author of its creation
+	if revisions := s.revisions[code]; len(revisions) > 0 && revisions[0].UserID == "" {
+		revisions[0].UserID = userID
+	}
+}
+
 // UsersURLCodes returns codes of user's URLs
 func (s *Storage) UsersURLCodes(ctx context.Context, userID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	return []string{}, nil
+	codes := make([]string, len(s.usersCodes[userID]))
+	copy(codes, s.usersCodes[userID])
+
+	return codes, nil
 }
 
-// UsersURLs returns list of user's URLs
-func (s *Storage) UsersURLs(ctx context.Context, userID string) ([]service.UsersURL, error) {
-	return []service.UsersURL{}, nil
+// UsersURLs returns a page of user's URLs, they are filtered and sorted in memory
+func (s *Storage) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	urls := make([]service.UsersURL, 0, len(s.usersCodes[userID]))
+	for _, code := range s.usersCodes[userID] {
+		u, ok := s.usersURL(code)
+		if !ok {
+			continue
+		}
+
+		u.Clicks = len(s.clicks[code])
+		urls = append(urls, u)
+	}
+
+	return service.PageUsersURLs(urls, q), nil
 }
 
-// URLCount blank
+// IterateUsersURLs calls fn for every URL of the user in order of saving
+// the lock isn't held while fn is called, so a slow consumer doesn't block writers
+func (s *Storage) IterateUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	codes, err := s.UsersURLCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		u, ok := s.usersURL(code)
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// usersURL must be called under the lock
+func (s *Storage) usersURL(code string) (service.UsersURL, bool) {
+	url, ok := s.shortenURLs[code]
+	if !ok {
+		return service.UsersURL{}, false
+	}
+
+	_, deleted := s.deleted[code]
+
+	return service.UsersURL{Code: code, OriginalURL: url, ExpiresAt: s.expiresAt[code], Deleted: deleted}, true
+}
+
+// URLCount returns count of URLs which are not deleted
 func (s *Storage) URLCount(ctx context.Context) (int, error) {
-	return 0, nil
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.shortenURLs) - len(s.deleted), nil
 }
 
-// UserCount blank
+// UserCount returns count of users owning at least one code
 func (s *Storage) UserCount(ctx context.Context) (int, error) {
-	return 0, nil
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.usersCodes), nil
+}
+
+// DeleteExpiredURLs marks as deleted all URLs which expiration time has come
+// returns count of deleted URLs
+func (s *Storage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	cnt := 0
+	for code, expiresAt := range s.expiresAt {
+		if expiresAt.After(now) {
+			continue
+		}
+
+		if _, ok := s.deleted[code]; ok {
+			continue
+		}
+
+		s.deleted[code] = struct{}{}
+		s.addRevision(service.Revision{Code: code, Action: service.RevisionExpired, URL: s.shortenURLs[code], PreviousURL: s.shortenURLs[code]})
+		cnt++
+	}
+
+	return cnt, nil
+}
+
+// Revisions returns revisions of the code in order of making
+func (s *Storage) Revisions(ctx context.Context, code string) ([]service.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := make([]service.Revision, len(s.revisions[code]))
+	copy(revisions, s.revisions[code])
+
+	return revisions, nil
+}
+
+// RestoreURL sets the destination of the revision to the code and undeletes it
+// zero revisionID keeps the current destination
+func (s *Storage) RestoreURL(ctx context.Context, userID, code string, revisionID int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.shortenURLs[code]
+	if !ok {
+		return service.ErrNotFound
+	}
+
+	url := prev
+	if revisionID != 0 {
+		i := sort.Search(len(s.revisions[code]), func(i int) bool { return s.revisions[code][i].ID >= revisionID })
+		if i == len(s.revisions[code]) || s.revisions[code][i].ID != revisionID {
+			return service.ErrNotFound
+		}
+		url = s.revisions[code][i].URL
+	}
+
+	_, deleted := s.deleted[code]
+	if url == prev && !deleted {
+		return nil
+	}
+
+	if url != prev {
+		if _, ok := s.codes[url]; ok {
+			return service.ErrURLAlreadyExists
+		}
+
+		delete(s.codes, prev)
+		s.shortenURLs[code] = url
+		s.codes[url] = code
+	}
+
+	delete(s.deleted, code)
+	s.addRevision(service.Revision{Code: code, Action: service.RevisionRestored, URL: url, PreviousURL: prev, UserID: userID})
+
+	return nil
+}
+
+// SaveClicks stores click events
+func (s *Storage) SaveClicks(ctx context.Context, clicks []service.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range clicks {
+		s.clicks[c.Code] = append(s.clicks[c.Code], c)
+	}
+
+	return nil
+}
+
+// ClickCount returns count of clicks of the code
+func (s *Storage) ClickCount(ctx context.Context, code string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.shortenURLs[code]; !ok {
+		return 0, service.ErrNotFound
+	}
+
+	return len(s.clicks[code]), nil
+}
+
+// Clicks returns the latest click events of the code
+// if limit isn't positive returns all events
+func (s *Storage) Clicks(ctx context.Context, code string, limit int) ([]service.Click, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return latestClicks(s.clicks[code], limit), nil
+}
+
+// ClickStats aggregates clicks of the code in the range of the query
+func (s *Storage) ClickStats(ctx context.Context, q service.ClickStatsQuery) (*service.ClickStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return service.AggregateClicks(s.clicks[q.Code], q), nil
+}
+
+func latestClicks(clicks []service.Click, limit int) []service.Click {
+	latest := make([]service.Click, len(clicks))
+	copy(latest, clicks)
+	sort.SliceStable(latest, func(i, j int) bool {
+		return latest[i].Time.After(latest[j].Time)
+	})
+
+	if limit > 0 && limit < len(latest) {
+		latest = latest[:limit]
+	}
+
+	return latest
 }
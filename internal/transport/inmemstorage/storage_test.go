@@ -3,6 +3,7 @@ package inmemstorage_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -10,8 +11,15 @@ import (
 	"github.com/lks-go/url-shortener/internal/lib/random"
 	"github.com/lks-go/url-shortener/internal/service"
 	"github.com/lks-go/url-shortener/internal/transport/inmemstorage"
+	"github.com/lks-go/url-shortener/internal/transport/storagetest"
 )
 
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.RunURLStorage(t, func(t *testing.T) service.URLStorage {
+		return inmemstorage.MustNew(map[string]string{})
+	})
+}
+
 func TestStorage_Exists(t *testing.T) {
 
 	id1, id2 := random.NewString(6), random.NewString(6)
@@ -74,7 +82,7 @@ func TestStorage_Save(t *testing.T) {
 			mem := map[string]string{}
 			s := inmemstorage.MustNew(mem)
 
-			if err := s.Save(context.Background(), tt.url, tt.id); (err != nil) != tt.wantErr {
+			if err := s.Save(context.Background(), tt.id, tt.url, time.Time{}); (err != nil) != tt.wantErr {
 				t.Errorf("Save() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -129,3 +137,166 @@ func TestStorage_URL(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_URLExpired(t *testing.T) {
+	s := inmemstorage.MustNew(map[string]string{})
+
+	require.NoError(t, s.Save(context.Background(), "expired", "https://ya.ru", time.Now().Add(-time.Minute)))
+	require.NoError(t, s.Save(context.Background(), "alive", "https://google.com", time.Now().Add(time.Hour)))
+
+	_, err := s.URL(context.Background(), "expired")
+	require.ErrorIs(t, err, service.ErrExpired)
+
+	url, err := s.URL(context.Background(), "alive")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+}
+
+func TestStorage_Clicks(t *testing.T) {
+	s := inmemstorage.MustNew(map[string]string{"abc": "https://ya.ru"})
+	now := time.Now()
+
+	clicks := []service.Click{
+		{Code: "abc", Time: now.Add(-2 * time.Minute), IP: "10.0.0.1"},
+		{Code: "abc", Time: now, IP: "10.0.0.3"},
+		{Code: "abc", Time: now.Add(-time.Minute), IP: "10.0.0.2"},
+	}
+	require.NoError(t, s.SaveClicks(context.Background(), clicks))
+
+	cnt, err := s.ClickCount(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 3, cnt)
+
+	latest, err := s.Clicks(context.Background(), "abc", 2)
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+	assert.Equal(t, "10.0.0.3", latest[0].IP)
+	assert.Equal(t, "10.0.0.2", latest[1].IP)
+
+	_, err = s.ClickCount(context.Background(), "unknown")
+	require.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestStorage_Revisions(t *testing.T) {
+	ctx := context.Background()
+	s := inmemstorage.MustNew(map[string]string{})
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.UpdateURL(ctx, "user1", "abc", "https://google.com"))
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"abc"}))
+
+	revisions, err := s.Revisions(ctx, "abc")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+
+	assert.Equal(t, service.RevisionCreated, revisions[0].Action)
+	assert.Equal(t, "https://ya.ru", revisions[0].URL)
+	assert.Equal(t, "user1", revisions[0].UserID)
+
+	assert.Equal(t, service.RevisionUpdated, revisions[1].Action)
+	assert.Equal(t, "https://google.com", revisions[1].URL)
+	assert.Equal(t, "https://ya.ru", revisions[1].PreviousURL)
+
+	assert.Equal(t, service.RevisionDeleted, revisions[2].Action)
+	assert.Equal(t, "user1", revisions[2].UserID)
+
+	require.NoError(t, s.RestoreURL(ctx, "user1", "abc", revisions[0].ID))
+
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	code, err := s.CodeByURL(ctx, "https://ya.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", code)
+
+	revisions, err = s.Revisions(ctx, "abc")
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	assert.Equal(t, service.RevisionRestored, revisions[3].Action)
+	assert.Equal(t, "https://google.com", revisions[3].PreviousURL)
+
+	require.ErrorIs(t, s.RestoreURL(ctx, "user1", "abc", 100), service.ErrNotFound)
+	require.ErrorIs(t, s.RestoreURL(ctx, "user1", "xyz", 0), service.ErrNotFound)
+
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+	require.ErrorIs(t, s.RestoreURL(ctx, "user1", "abc", revisions[1].ID), service.ErrURLAlreadyExists)
+}
+
+func TestStorage_ClaimURLs(t *testing.T) {
+	ctx := context.Background()
+	s := inmemstorage.MustNew(map[string]string{})
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "anonymous", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "account", "xyz"))
+	require.NoError(t, s.SaveUsersCode(ctx, "anonymous", "xyz"))
+
+	cnt, err := s.ClaimURLs(ctx, "anonymous", "account")
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	codes, err := s.UsersURLCodes(ctx, "account")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"xyz", "abc"}, codes)
+
+	codes, err = s.UsersURLCodes(ctx, "anonymous")
+	require.NoError(t, err)
+	assert.Empty(t, codes)
+
+	revisions, err := s.Revisions(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "account", revisions[0].UserID)
+}
+
+func TestStorage_Moderation(t *testing.T) {
+	ctx := context.Background()
+	s := inmemstorage.MustNew(map[string]string{})
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "owner", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "another", "abc"))
+
+	info, err := s.URLInfo(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "owner", info.OwnerID)
+	assert.False(t, info.Blocked)
+
+	_, err = s.URLInfo(ctx, "xyz")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	require.NoError(t, s.SetURLBlocked(ctx, "admin", "abc", true))
+	require.NoError(t, s.SetURLBlocked(ctx, "admin", "abc", true), "blocking twice isn't recorded")
+	require.ErrorIs(t, s.SetURLBlocked(ctx, "admin", "xyz", true), service.ErrNotFound)
+
+	_, err = s.URL(ctx, "abc")
+	require.ErrorIs(t, err, service.ErrBlocked)
+
+	info, err = s.URLInfo(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, info.Blocked)
+
+	require.NoError(t, s.SetURLBlocked(ctx, "admin", "abc", false))
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	revisions, err := s.Revisions(ctx, "abc")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, service.RevisionBlocked, revisions[1].Action)
+	assert.Equal(t, "admin", revisions[1].UserID)
+	assert.Equal(t, service.RevisionUnblocked, revisions[2].Action)
+
+	require.NoError(t, s.SetUserBanned(ctx, "owner", true))
+	banned, err := s.UserBanned(ctx, "owner")
+	require.NoError(t, err)
+	assert.True(t, banned)
+
+	require.NoError(t, s.SetUserBanned(ctx, "owner", false))
+	banned, err = s.UserBanned(ctx, "owner")
+	require.NoError(t, err)
+	assert.False(t, banned)
+}
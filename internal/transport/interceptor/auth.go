@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strings"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
@@ -13,21 +14,56 @@ import (
 
 	"github.com/lks-go/url-shortener/internal/entity"
 	"github.com/lks-go/url-shortener/internal/lib/jwt"
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+	"github.com/lks-go/url-shortener/internal/service"
 )
 
-func Auth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+// authorizationHeader is a metadata key of OIDC ID tokens, the value is "Bearer <token>"
+const authorizationHeader = "authorization"
+
+// Auth checks jwt of the request by the keyring, a new user is created if the token is missing or invalid
+// requests with the OIDC ID token in the authorization metadata are authenticated by idTokens, which may be nil
+func Auth(keyring *jwt.Keyring, idTokens *oidc.Provider) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, keyring, idTokens)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth is Auth for streaming RPCs
+func StreamAuth(keyring *jwt.Keyring, idTokens *oidc.Provider) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), keyring, idTokens)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate returns the context with the identity of the user, a new user is created if the token is missing or invalid
+func authenticate(ctx context.Context, keyring *jwt.Keyring, idTokens *oidc.Provider) (context.Context, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil, status.Error(codes.InvalidArgument, "missing metadata")
 	}
 
+	if auth := md.Get(authorizationHeader); len(auth) > 0 {
+		return authenticateIDToken(ctx, idTokens, auth[0])
+	}
+
 	var userID string
 	var claims *jwt.Claims
 	var err error
 
 	token, ok := md[entity.AuthTokenHeader]
 	if ok {
-		claims, err = jwt.ParseJWTToken(token[0])
+		claims, err = keyring.ParseJWTToken(token[0])
 		if err != nil && !errors.Is(err, jwt.ErrInvalidToken) && !errors.Is(err, jwt.ErrTokenExpired) {
 			log.Println("failed to parse jwt:", err)
 			return nil, status.Error(codes.InvalidArgument, "failed to parse jwt")
@@ -45,7 +81,7 @@ func Auth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc
 	if !ok || errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, jwt.ErrTokenExpired) {
 		userID = uuid.NewString()
 
-		token, err := jwt.BuildNewJWTToken(userID)
+		token, err := keyring.BuildNewJWTToken(userID)
 		if err != nil {
 			return nil, status.Error(codes.Internal, (codes.Internal).String())
 		}
@@ -55,6 +91,25 @@ func Auth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc
 		grpc.SendHeader(ctx, header)
 	}
 
-	ctx = metadata.AppendToOutgoingContext(ctx, entity.UserIDHeaderName, userID)
-	return handler(ctx, req)
+	return entity.WithIdentity(ctx, entity.Identity{UserID: userID, Scopes: service.SessionScopes, Kind: entity.KindSession}), nil
+}
+
+// authenticateIDToken returns the context with the identity of the OIDC ID token subject, no new token is issued
+func authenticateIDToken(ctx context.Context, idTokens *oidc.Provider, auth string) (context.Context, error) {
+	rawToken, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok || idTokens == nil {
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	token, err := idTokens.Verify(ctx, rawToken)
+	if err != nil {
+		if !errors.Is(err, oidc.ErrInvalidToken) {
+			log.Println("failed to verify id token:", err)
+			return nil, status.Error(codes.Unavailable, "failed to verify id token")
+		}
+
+		return nil, status.Error(codes.Unauthenticated, (codes.Unauthenticated).String())
+	}
+
+	return entity.WithIdentity(ctx, entity.Identity{UserID: token.UserID(), Scopes: service.SessionScopes, Kind: entity.KindOIDC}), nil
 }
@@ -0,0 +1,66 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/pkg/proto"
+)
+
+// BanChecker checks if the user is banned
+type BanChecker interface {
+	CheckBanned(ctx context.Context, userID string) error
+}
+
+// publicMethods don't act on behalf of the user, so they are available to banned users too
+var publicMethods = map[string]bool{
+	proto.URLShortener_Redirect_FullMethodName: true,
+	proto.URLShortener_Stats_FullMethodName:    true,
+}
+
+// CheckBan rejects requests of banned users, it must follow Auth
+func CheckBan(checker BanChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkBan(ctx, checker, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamCheckBan is CheckBan for streaming RPCs
+func StreamCheckBan(checker BanChecker) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkBan(ss.Context(), checker, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func checkBan(ctx context.Context, checker BanChecker, method string) error {
+	user, ok := entity.IdentityFromContext(ctx)
+	if !ok || publicMethods[method] {
+		return nil
+	}
+
+	err := checker.CheckBanned(ctx, user.UserID)
+	switch {
+	case errors.Is(err, service.ErrUserBanned):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		log.Println("failed to check user ban:", err)
+		return status.Error(codes.Internal, (codes.Internal).String())
+	}
+
+	return nil
+}
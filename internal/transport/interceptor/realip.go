@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/lib/realip"
+)
+
+// forwardedForHeader is a metadata key of client IPs appended by proxies
+const forwardedForHeader = "x-forwarded-for"
+
+// RealIP puts the IP of the client to the context
+// x-forwarded-for metadata is taken into account only if it's set by trusted proxies of the resolver
+func RealIP(resolver *realip.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withClientIP(ctx, resolver), req)
+	}
+}
+
+// StreamRealIP is RealIP for streaming RPCs
+func StreamRealIP(resolver *realip.Resolver) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextStream{ServerStream: ss, ctx: withClientIP(ss.Context(), resolver)})
+	}
+}
+
+func withClientIP(ctx context.Context, resolver *realip.Resolver) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ctx
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return entity.WithClientIP(ctx, resolver.ClientIP(p.Addr.String(), md.Get(forwardedForHeader)))
+}
+
+// contextStream replaces the context of the stream with the one of the interceptor
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
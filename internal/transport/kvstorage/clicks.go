@@ -0,0 +1,123 @@
+package kvstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// SaveClicks stores click events to the buckets of their codes
+func (s *Storage) SaveClicks(ctx context.Context, clicks []service.Click) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		clicksB := tx.Bucket(clicksBucket)
+		for _, c := range clicks {
+			codeB, err := clicksB.CreateBucketIfNotExists([]byte(c.Code))
+			if err != nil {
+				return fmt.Errorf("failed to create clicks bucket: %w", err)
+			}
+
+			seq, err := codeB.NextSequence()
+			if err != nil {
+				return fmt.Errorf("failed to get sequence: %w", err)
+			}
+
+			if err := putJSON(codeB, uint64Bytes(seq), c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClickCount returns count of clicks of the code
+func (s *Storage) ClickCount(ctx context.Context, code string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(urlsBucket).Get([]byte(code)) == nil {
+			return service.ErrNotFound
+		}
+
+		if codeB := tx.Bucket(clicksBucket).Bucket([]byte(code)); codeB != nil {
+			cnt = codeB.Stats().KeyN
+		}
+		return nil
+	})
+
+	return cnt, err
+}
+
+// Clicks returns the latest click events of the code, the bucket is read from the end up to the limit
+// if limit isn't positive returns all events
+func (s *Storage) Clicks(ctx context.Context, code string, limit int) ([]service.Click, error) {
+	clicks := make([]service.Click, 0)
+	err := s.reverseClicks(ctx, code, func(c service.Click) bool {
+		clicks = append(clicks, c)
+		return limit <= 0 || len(clicks) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clicks, nil
+}
+
+// ClickStats aggregates clicks of the code in the range of the query
+// clicks are stored in order of recording, so the bucket is read from the end until the first click before the range
+func (s *Storage) ClickStats(ctx context.Context, q service.ClickStatsQuery) (*service.ClickStats, error) {
+	clicks := make([]service.Click, 0)
+	err := s.reverseClicks(ctx, q.Code, func(c service.Click) bool {
+		if c.Time.Before(q.From) {
+			return false
+		}
+
+		if c.Time.Before(q.To) {
+			clicks = append(clicks, c)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return service.AggregateClicks(clicks, q), nil
+}
+
+// reverseClicks calls fn for click events of the code from the latest recorded one while fn returns true
+func (s *Storage) reverseClicks(ctx context.Context, code string, fn func(c service.Click) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		codeB := tx.Bucket(clicksBucket).Bucket([]byte(code))
+		if codeB == nil {
+			return nil
+		}
+
+		cur := codeB.Cursor()
+		for k, v := cur.Last(); k != nil; k, v = cur.Prev() {
+			c := service.Click{}
+			if err := json.Unmarshal(v, &c); err != nil {
+				return fmt.Errorf("failed to unmarshal click: %w", err)
+			}
+
+			if !fn(c) {
+				return nil
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,590 @@
+// Package kvstorage implements storage on the embedded key-value store bbolt
+package kvstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// Buckets of the store
+var (
+	// urlsBucket maps code to urlRecord
+	urlsBucket = []byte("urls")
+	// codesBucket is the reverse index of URL to code
+	codesBucket = []byte("codes")
+	// usersBucket contains a nested bucket of codes for every user, values are sequence numbers of saving
+	usersBucket = []byte("users")
+	// expiringBucket is an index of codes by expiration time
+	expiringBucket = []byte("expiring")
+	// countersBucket contains counters of live URLs and users
+	countersBucket = []byte("counters")
+	// clicksBucket contains a nested bucket of click events for every code
+	clicksBucket = []byte("clicks")
+)
+
+// Keys of counters
+var (
+	urlCountKey  = []byte("urls")
+	userCountKey = []byte("users")
+)
+
+// iteratePageSize is a count of URLs read by a single transaction while iterating
+const iteratePageSize = 500
+
+// MustNew returns instance of Storage
+// if an errors occurs then panic happens
+func MustNew(path string) *Storage {
+	s, err := New(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// New opens the store by the path and creates buckets if they don't exist
+func New(path string) (*Storage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{urlsBucket, codesBucket, usersBucket, expiringBucket, countersBucket, clicksBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", b, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Storage the main struct
+type Storage struct {
+	db *bolt.DB
+}
+
+type urlRecord struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`
+}
+
+func (r urlRecord) usersURL(code string) service.UsersURL {
+	u := service.UsersURL{Code: code, OriginalURL: r.URL, Deleted: r.Deleted}
+	if r.ExpiresAt != nil {
+		u.ExpiresAt = *r.ExpiresAt
+	}
+
+	return u
+}
+
+// Close closes the store
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Save stores a new URL
+// returns service.ErrCodeAlreadyExists if the code is taken and service.ErrURLAlreadyExists if the URL is already saved
+func (s *Storage) Save(ctx context.Context, code, url string, expiresAt time.Time) error {
+	err := s.saveURLs(ctx, "", []service.URL{{Code: code, OriginalURL: url, ExpiresAt: expiresAt}})
+
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Conflicts[0].Err
+	}
+
+	return err
+}
+
+// SaveBatch stores URLs and makes the user their owner if the user is set in one transaction
+// returns *service.BatchError if any of codes or URLs is already saved
+func (s *Storage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	return s.saveURLs(ctx, userID, urls)
+}
+
+func (s *Storage) saveURLs(ctx context.Context, userID string, urls []service.URL) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		urlsB, codesB, expiringB := tx.Bucket(urlsBucket), tx.Bucket(codesBucket), tx.Bucket(expiringBucket)
+
+		err := service.CheckBatch(urls,
+			func(code string) bool { return urlsB.Get([]byte(code)) != nil },
+			func(url string) bool { return codesB.Get([]byte(url)) != nil },
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range urls {
+			r := urlRecord{URL: u.OriginalURL}
+			if !u.ExpiresAt.IsZero() {
+				r.ExpiresAt = &u.ExpiresAt
+				if err := expiringB.Put(expiringKey(u.ExpiresAt, u.Code), []byte(u.Code)); err != nil {
+					return fmt.Errorf("failed to put expiration: %w", err)
+				}
+			}
+
+			if err := putJSON(urlsB, []byte(u.Code), r); err != nil {
+				return err
+			}
+
+			if err := codesB.Put([]byte(u.OriginalURL), []byte(u.Code)); err != nil {
+				return fmt.Errorf("failed to put code: %w", err)
+			}
+
+			if userID != "" {
+				if err := saveUsersCode(tx, userID, u.Code); err != nil {
+					return err
+				}
+			}
+		}
+
+		return addCounter(tx, urlCountKey, len(urls))
+	})
+}
+
+// Exists checks if URL already exists
+func (s *Storage) Exists(ctx context.Context, code string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	exists := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(urlsBucket).Get([]byte(code)) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+// URL returns URL by code
+func (s *Storage) URL(ctx context.Context, code string) (string, error) {
+	url, _, err := s.URLWithExpiry(ctx, code)
+	return url, err
+}
+
+// URLWithExpiry returns URL by code with the time when it expires, zero time means never
+func (s *Storage) URLWithExpiry(ctx context.Context, code string) (string, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+
+	r := urlRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(urlsBucket), []byte(code), &r)
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if r.Deleted {
+		return "", time.Time{}, service.ErrDeleted
+	}
+
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return "", time.Time{}, service.ErrExpired
+	}
+
+	if r.ExpiresAt == nil {
+		return r.URL, time.Time{}, nil
+	}
+
+	return r.URL, *r.ExpiresAt, nil
+}
+
+// CodeByURL returns code by URL
+// returns service.ErrNotFound if the URL isn't saved
+func (s *Storage) CodeByURL(ctx context.Context, url string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	code := ""
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(codesBucket).Get([]byte(url))
+		if v == nil {
+			return service.ErrNotFound
+		}
+
+		code = string(v)
+		return nil
+	})
+
+	return code, err
+}
+
+// UpdateURL replaces URL of the code and the reverse index in one transaction
+func (s *Storage) UpdateURL(ctx context.Context, userID, code, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		urlsB, codesB := tx.Bucket(urlsBucket), tx.Bucket(codesBucket)
+
+		r := urlRecord{}
+		if err := getJSON(urlsB, []byte(code), &r); err != nil {
+			return err
+		}
+
+		switch {
+		case r.Deleted:
+			return service.ErrDeleted
+		case r.URL == url:
+			return nil
+		case codesB.Get([]byte(url)) != nil:
+			return service.ErrURLAlreadyExists
+		}
+
+		if err := codesB.Delete([]byte(r.URL)); err != nil {
+			return fmt.Errorf("failed to delete code: %w", err)
+		}
+
+		if err := codesB.Put([]byte(url), []byte(code)); err != nil {
+			return fmt.Errorf("failed to put code: %w", err)
+		}
+
+		r.URL = url
+		return putJSON(urlsB, []byte(code), r)
+	})
+}
+
+// SaveUsersCode stores owner of the code
+// returns service.ErrRecordAlreadyExists if the code already belongs to the user
+func (s *Storage) SaveUsersCode(ctx context.Context, userID string, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return saveUsersCode(tx, userID, code)
+	})
+}
+
+// UsersURLCodes returns codes of user's URLs in order of saving
+func (s *Storage) UsersURLCodes(ctx context.Context, userID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		codes = usersCodes(tx, userID)
+		return nil
+	})
+
+	return codes, err
+}
+
+// UsersURLs returns a page of user's URLs, they are read in one transaction and filtered and sorted in memory
+func (s *Storage) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	urls := make([]service.UsersURL, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		urlsB := tx.Bucket(urlsBucket)
+		clicksB := tx.Bucket(clicksBucket)
+		for _, code := range usersCodes(tx, userID) {
+			r := urlRecord{}
+			if err := getJSON(urlsB, []byte(code), &r); err != nil {
+				if errors.Is(err, service.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+
+			u := r.usersURL(code)
+			if codeB := clicksB.Bucket([]byte(code)); codeB != nil {
+				u.Clicks = codeB.Stats().KeyN
+			}
+
+			urls = append(urls, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return service.PageUsersURLs(urls, q), nil
+}
+
+// IterateUsersURLs calls fn for every URL of the user in order of saving
+// the URLs are read in pages of iteratePageSize codes, every page in its own read transaction,
+// so fn isn't called inside of a transaction
+func (s *Storage) IterateUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	codes, err := s.UsersURLCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for len(codes) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page := codes[:min(iteratePageSize, len(codes))]
+		codes = codes[len(page):]
+
+		urls := make([]service.UsersURL, 0, len(page))
+		err := s.db.View(func(tx *bolt.Tx) error {
+			urlsB := tx.Bucket(urlsBucket)
+			for _, code := range page {
+				r := urlRecord{}
+				if err := getJSON(urlsB, []byte(code), &r); err != nil {
+					if errors.Is(err, service.ErrNotFound) {
+						continue
+					}
+					return err
+				}
+
+				urls = append(urls, r.usersURL(code))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, u := range urls {
+			if err := fn(u); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeleteURLs marks URLs as deleted by codes
+func (s *Storage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		_, err := deleteURLs(tx, codes)
+		return err
+	})
+}
+
+// URLCount returns count of URLs which are not deleted
+func (s *Storage) URLCount(ctx context.Context) (int, error) {
+	return s.counter(ctx, urlCountKey)
+}
+
+// UserCount returns count of users owning at least one code
+func (s *Storage) UserCount(ctx context.Context) (int, error) {
+	return s.counter(ctx, userCountKey)
+}
+
+// DeleteExpiredURLs marks as deleted all URLs which expiration time has come
+// returns count of deleted URLs
+func (s *Storage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		now := uint64Bytes(uint64(time.Now().UnixNano()))
+
+		codes := make([]string, 0)
+		c := tx.Bucket(expiringBucket).Cursor()
+		for k, v := c.First(); k != nil && bytes.Compare(k[:8], now) <= 0; k, v = c.Next() {
+			codes = append(codes, string(v))
+		}
+
+		var err error
+		cnt, err = deleteURLs(tx, codes)
+		return err
+	})
+
+	return cnt, err
+}
+
+func (s *Storage) counter(ctx context.Context, key []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(countersBucket).Get(key); v != nil {
+			cnt = int(int64(binary.BigEndian.Uint64(v)))
+		}
+		return nil
+	})
+
+	return cnt, err
+}
+
+// deleteURLs marks existing URLs as deleted and removes them from the expiration index
+// returns count of URLs which weren't deleted before
+func deleteURLs(tx *bolt.Tx, codes []string) (int, error) {
+	urlsB, expiringB := tx.Bucket(urlsBucket), tx.Bucket(expiringBucket)
+
+	cnt := 0
+	for _, code := range codes {
+		r := urlRecord{}
+		if err := getJSON(urlsB, []byte(code), &r); err != nil {
+			if errors.Is(err, service.ErrNotFound) {
+				continue
+			}
+			return 0, err
+		}
+
+		if r.Deleted {
+			continue
+		}
+
+		if r.ExpiresAt != nil {
+			if err := expiringB.Delete(expiringKey(*r.ExpiresAt, code)); err != nil {
+				return 0, fmt.Errorf("failed to delete expiration: %w", err)
+			}
+		}
+
+		r.Deleted = true
+		if err := putJSON(urlsB, []byte(code), r); err != nil {
+			return 0, err
+		}
+
+		cnt++
+	}
+
+	return cnt, addCounter(tx, urlCountKey, -cnt)
+}
+
+// saveUsersCode returns service.ErrRecordAlreadyExists if the code already belongs to the user
+func saveUsersCode(tx *bolt.Tx, userID, code string) error {
+	usersB := tx.Bucket(usersBucket)
+
+	userB := usersB.Bucket([]byte(userID))
+	if userB == nil {
+		var err error
+		userB, err = usersB.CreateBucket([]byte(userID))
+		if err != nil {
+			return fmt.Errorf("failed to create user bucket: %w", err)
+		}
+
+		if err := addCounter(tx, userCountKey, 1); err != nil {
+			return err
+		}
+	}
+
+	if userB.Get([]byte(code)) != nil {
+		return service.ErrRecordAlreadyExists
+	}
+
+	seq, err := userB.NextSequence()
+	if err != nil {
+		return fmt.Errorf("failed to get sequence: %w", err)
+	}
+
+	return userB.Put([]byte(code), uint64Bytes(seq))
+}
+
+func usersCodes(tx *bolt.Tx, userID string) []string {
+	userB := tx.Bucket(usersBucket).Bucket([]byte(userID))
+	if userB == nil {
+		return []string{}
+	}
+
+	type ownership struct {
+		code string
+		seq  uint64
+	}
+
+	list := make([]ownership, 0)
+	userB.ForEach(func(k, v []byte) error {
+		list = append(list, ownership{code: string(k), seq: binary.BigEndian.Uint64(v)})
+		return nil
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].seq < list[j].seq
+	})
+
+	codes := make([]string, 0, len(list))
+	for _, o := range list {
+		codes = append(codes, o.code)
+	}
+
+	return codes
+}
+
+func addCounter(tx *bolt.Tx, key []byte, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	b := tx.Bucket(countersBucket)
+
+	var cnt int64
+	if v := b.Get(key); v != nil {
+		cnt = int64(binary.BigEndian.Uint64(v))
+	}
+
+	return b.Put(key, uint64Bytes(uint64(cnt+int64(delta))))
+}
+
+// expiringKey is ordered by expiration time, the code makes the key unique
+func expiringKey(t time.Time, code string) []byte {
+	return append(uint64Bytes(uint64(t.UnixNano())), code...)
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func putJSON(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	if err := b.Put(key, data); err != nil {
+		return fmt.Errorf("failed to put value: %w", err)
+	}
+
+	return nil
+}
+
+// getJSON returns service.ErrNotFound if the key doesn't exist
+func getJSON(b *bolt.Bucket, key []byte, v any) error {
+	data := b.Get(key)
+	if data == nil {
+		return service.ErrNotFound
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+
+	return nil
+}
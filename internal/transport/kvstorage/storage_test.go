@@ -0,0 +1,93 @@
+package kvstorage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/transport/kvstorage"
+	"github.com/lks-go/url-shortener/internal/transport/storagetest"
+)
+
+func newStorage(t *testing.T, path string) *kvstorage.Storage {
+	t.Helper()
+
+	s := kvstorage.MustNew(path)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.RunURLStorage(t, func(t *testing.T) service.URLStorage {
+		return newStorage(t, filepath.Join(t.TempDir(), "storage.db"))
+	})
+}
+
+func TestStorage_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	s := kvstorage.MustNew(path)
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "xyz"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"xyz"}))
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{{Code: "abc", Time: time.Now()}}))
+	require.NoError(t, s.Close())
+
+	reopened := newStorage(t, path)
+
+	url, err := reopened.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	_, err = reopened.URL(ctx, "xyz")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	codes, err := reopened.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"xyz", "abc"}, codes)
+
+	cnt, err := reopened.URLCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	cnt, err = reopened.ClickCount(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+}
+
+func TestStorage_Clicks(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t, filepath.Join(t.TempDir(), "storage.db"))
+	now := time.Now().UTC()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{
+		{Code: "abc", Time: now.Add(-2 * time.Hour), IP: "10.0.0.1"},
+		{Code: "abc", Time: now.Add(-time.Hour), IP: "10.0.0.2"},
+		{Code: "abc", Time: now, IP: "10.0.0.3"},
+	}))
+
+	latest, err := s.Clicks(ctx, "abc", 2)
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+	assert.Equal(t, "10.0.0.3", latest[0].IP)
+	assert.Equal(t, "10.0.0.2", latest[1].IP)
+
+	stats, err := s.ClickStats(ctx, service.ClickStatsQuery{
+		Code:     "abc",
+		From:     now.Add(-90 * time.Minute),
+		To:       now.Add(-time.Minute),
+		Interval: service.IntervalHour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Clicks)
+}
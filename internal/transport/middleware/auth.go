@@ -1,21 +1,51 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/lks-go/url-shortener/internal/entity"
 	"github.com/lks-go/url-shortener/internal/lib/jwt"
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+	"github.com/lks-go/url-shortener/internal/service"
 )
 
-// WithAuth checks user's cookie and jwt
+// bearerPrefix is a prefix of API keys and ID tokens in the Authorization header
+const bearerPrefix = "Bearer "
+
+// APIKeyAuthenticator checks API keys sent in the Authorization header
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*service.APIKey, error)
+}
+
+// WithAuth checks API key or OIDC ID token of the Authorization header or user's cookie and jwt by the keyring
 // if cookie is empty generates new jwt and set new cooker to headers
-func WithAuth(next http.Handler) http.Handler {
+// apiKeys and idTokens may be nil, then requests with API keys or ID tokens respectively are unauthorized
+func WithAuth(keyring *jwt.Keyring, apiKeys APIKeyAuthenticator, idTokens *oidc.Provider) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return withAuth(keyring, apiKeys, idTokens, next)
+	}
+}
+
+func withAuth(keyring *jwt.Keyring, apiKeys APIKeyAuthenticator, idTokens *oidc.Provider, next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			token, ok := strings.CutPrefix(auth, bearerPrefix)
+			if ok && isJWT(token) {
+				withIDToken(w, r, idTokens, token, next)
+				return
+			}
+
+			withAPIKey(w, r, apiKeys, auth, next)
+			return
+		}
+
 		var userID string
 		var emptyCookie bool
 		var claims *jwt.Claims
@@ -34,7 +64,7 @@ func WithAuth(next http.Handler) http.Handler {
 		}
 
 		if !emptyCookie {
-			claims, err = jwt.ParseJWTToken(cookie.Value)
+			claims, err = keyring.ParseJWTToken(cookie.Value)
 			if err != nil && !errors.Is(err, jwt.ErrInvalidToken) && !errors.Is(err, jwt.ErrTokenExpired) {
 				log.Println("failed to parse jwt:", err)
 				w.WriteHeader(http.StatusInternalServerError)
@@ -54,7 +84,7 @@ func WithAuth(next http.Handler) http.Handler {
 
 		if emptyCookie || errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, jwt.ErrTokenExpired) {
 			userID = uuid.NewString()
-			token, err := jwt.BuildNewJWTToken(userID)
+			token, err := keyring.BuildNewJWTToken(userID)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
@@ -69,9 +99,79 @@ func WithAuth(next http.Handler) http.Handler {
 			http.SetCookie(w, &newCookie)
 		}
 
-		r.Header.Set(entity.UserIDHeaderName, userID)
-		next.ServeHTTP(w, r)
+		ctx := entity.WithIdentity(r.Context(), entity.Identity{UserID: userID, Scopes: service.SessionScopes, Kind: entity.KindSession})
+		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 
 	return http.HandlerFunc(fn)
 }
+
+// withAPIKey authenticates the request by the API key, the request gets scopes of the key and no cookie is set
+func withAPIKey(w http.ResponseWriter, r *http.Request, apiKeys APIKeyAuthenticator, auth string, next http.Handler) {
+	key, ok := strings.CutPrefix(auth, bearerPrefix)
+	if !ok || key == "" || apiKeys == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	k, err := apiKeys.AuthenticateAPIKey(r.Context(), key)
+	if err != nil {
+		if !errors.Is(err, service.ErrInvalidAPIKey) && !errors.Is(err, service.ErrAccountsNotSupported) {
+			log.Println("failed to authenticate api key:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	ctx := entity.WithIdentity(r.Context(), entity.Identity{UserID: k.UserID, Scopes: k.Scopes, Kind: entity.KindAPIKey})
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// withIDToken authenticates the request by the ID token of the OIDC provider
+// the request gets scopes of the cookie session and no cookie is set
+func withIDToken(w http.ResponseWriter, r *http.Request, idTokens *oidc.Provider, rawToken string, next http.Handler) {
+	if idTokens == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	token, err := idTokens.Verify(r.Context(), rawToken)
+	if err != nil {
+		if !errors.Is(err, oidc.ErrInvalidToken) {
+			log.Println("failed to verify id token:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	ctx := entity.WithIdentity(r.Context(), entity.Identity{UserID: token.UserID(), Scopes: service.SessionScopes, Kind: entity.KindOIDC})
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// isJWT reports whether the bearer token is a JWT rather than an API key, API keys are base64url and have no dots
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// WithScope allows the request only if it has the scope
+func WithScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id, _ := entity.IdentityFromContext(r.Context())
+			if !service.HasScope(id.Scopes, scope) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
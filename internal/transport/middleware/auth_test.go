@@ -0,0 +1,136 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/lib/jwt"
+	"github.com/lks-go/url-shortener/internal/lib/oidc"
+	"github.com/lks-go/url-shortener/internal/lib/oidc/oidctest"
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/transport/middleware"
+)
+
+// apiKeys authenticates the only key "key" of user1 with the read scope
+type apiKeys struct{}
+
+func (apiKeys) AuthenticateAPIKey(_ context.Context, key string) (*service.APIKey, error) {
+	if key != "key" {
+		return nil, service.ErrInvalidAPIKey
+	}
+
+	return &service.APIKey{UserID: "user1", Scopes: []string{service.ScopeRead}}, nil
+}
+
+func TestWithAuth(t *testing.T) {
+	key, err := jwt.GenerateKey("test")
+	require.NoError(t, err)
+	keyring, err := jwt.NewKeyring(key)
+	require.NoError(t, err)
+
+	var gotUserID, gotKind string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := entity.IdentityFromContext(r.Context())
+		gotUserID, gotKind = id.UserID, id.Kind
+	})
+	provider := oidctest.NewProvider(t)
+	idTokens, err := oidc.Discover(context.Background(), provider.Config(""), nil)
+	require.NoError(t, err)
+
+	auth := middleware.WithAuth(keyring, apiKeys{}, idTokens)
+
+	routes := http.NewServeMux()
+	routes.Handle("/read", auth(middleware.WithScope(service.ScopeRead)(h)))
+	routes.Handle("/delete", auth(middleware.WithScope(service.ScopeDelete)(h)))
+
+	tests := []struct {
+		name         string
+		path         string
+		auth         string
+		wantHTTPCode int
+		wantUserID   string
+		wantKind     string
+		wantCookie   bool
+	}{
+		{
+			name:         "api key with the scope",
+			path:         "/read",
+			auth:         "Bearer key",
+			wantHTTPCode: http.StatusOK,
+			wantUserID:   "user1",
+			wantKind:     entity.KindAPIKey,
+		},
+		{
+			name:         "api key without the scope",
+			path:         "/delete",
+			auth:         "Bearer key",
+			wantHTTPCode: http.StatusForbidden,
+		},
+		{
+			name:         "unknown api key",
+			path:         "/read",
+			auth:         "Bearer other",
+			wantHTTPCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "not a bearer",
+			path:         "/read",
+			auth:         "Basic key",
+			wantHTTPCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "id token has all scopes",
+			path:         "/delete",
+			auth:         "Bearer " + provider.IDToken("alice", ""),
+			wantHTTPCode: http.StatusOK,
+			wantUserID:   (&oidc.IDToken{Issuer: provider.URL, Subject: "alice"}).UserID(),
+			wantKind:     entity.KindOIDC,
+		},
+		{
+			name:         "id token of another client",
+			path:         "/read",
+			auth:         "Bearer " + provider.SignIDToken(map[string]any{"iss": provider.URL, "sub": "alice", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()}),
+			wantHTTPCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "cookie session has all scopes",
+			path:         "/delete",
+			wantHTTPCode: http.StatusOK,
+			wantKind:     entity.KindSession,
+			wantCookie:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, gotKind = "", ""
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			// headers of the client don't affect the identity
+			r.Header.Set("User-Id", "spoofed")
+			r.Header.Set("Scopes", service.ScopeDelete)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+
+			routes.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+			if tt.wantUserID != "" {
+				assert.Equal(t, tt.wantUserID, gotUserID)
+			}
+			if tt.wantKind != "" {
+				assert.Equal(t, tt.wantKind, gotKind)
+			}
+			assert.NotEqual(t, "spoofed", gotUserID)
+			assert.Equal(t, tt.wantCookie, len(w.Result().Cookies()) > 0)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// BanChecker checks if the user is banned
+type BanChecker interface {
+	CheckBanned(ctx context.Context, userID string) error
+}
+
+// WithBanCheck rejects requests of banned users with 403, it must follow WithAuth
+func WithBanCheck(checker BanChecker) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			user, ok := entity.IdentityFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			err := checker.CheckBanned(r.Context(), user.UserID)
+			switch {
+			case errors.Is(err, service.ErrUserBanned):
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			case err != nil:
+				log.Println("failed to check user ban:", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,59 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/transport/middleware"
+)
+
+// bans bans the user "banned" and fails to check the user "broken"
+type bans struct{}
+
+func (bans) CheckBanned(_ context.Context, userID string) error {
+	switch userID {
+	case "banned":
+		return service.ErrUserBanned
+	case "broken":
+		return errors.New("storage is down")
+	}
+
+	return nil
+}
+
+func TestWithBanCheck(t *testing.T) {
+	h := middleware.WithBanCheck(bans{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name         string
+		userID       string
+		wantHTTPCode int
+	}{
+		{name: "user isn't banned", userID: "user1", wantHTTPCode: http.StatusOK},
+		{name: "banned user", userID: "banned", wantHTTPCode: http.StatusForbidden},
+		{name: "failed check", userID: "broken", wantHTTPCode: http.StatusInternalServerError},
+		{name: "request without identity is passed", wantHTTPCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.userID != "" {
+				r = r.WithContext(entity.WithIdentity(r.Context(), entity.Identity{UserID: tt.userID}))
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantHTTPCode, w.Code)
+		})
+	}
+}
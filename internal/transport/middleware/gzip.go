@@ -34,6 +34,17 @@ func (c *compressWriter) WriteHeader(statusCode int) {
 	c.w.WriteHeader(statusCode)
 }
 
+// Flush sends the compressed data written so far to the client
+func (c *compressWriter) Flush() {
+	c.zw.Flush()
+	http.NewResponseController(c.w).Flush()
+}
+
+// Unwrap returns the original http.ResponseWriter for http.ResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
+
 // Close is a wrapper of gzip.NewWriter Close()
 func (c *compressWriter) Close() error {
 	return c.zw.Close()
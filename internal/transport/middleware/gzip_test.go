@@ -85,3 +85,42 @@ func TestGzipCompression(t *testing.T) {
 		require.JSONEq(t, successBody, string(b))
 	})
 }
+
+func TestGzipCompressionFlush(t *testing.T) {
+	flushed := make(chan struct{})
+
+	handler := middleware.WithCompressor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("first\n"))
+		require.NoError(t, http.NewResponseController(w).Flush())
+
+		<-flushed
+		w.Write([]byte("second\n"))
+	}))
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	r, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(r)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	zr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+
+	// the first line must be readable before the handler writes the rest
+	first := make([]byte, len("first\n"))
+	_, err = io.ReadFull(zr, first)
+	require.NoError(t, err)
+	require.Equal(t, "first\n", string(first))
+
+	close(flushed)
+
+	rest, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "second\n", string(rest))
+}
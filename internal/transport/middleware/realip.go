@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/lks-go/url-shortener/internal/entity"
+	"github.com/lks-go/url-shortener/internal/lib/realip"
+)
+
+// WithRealIP puts the IP of the client to the request context
+// X-Forwarded-For is taken into account only if it's set by trusted proxies of the resolver
+func WithRealIP(resolver *realip.Resolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := resolver.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+			next.ServeHTTP(w, r.WithContext(entity.WithClientIP(r.Context(), ip)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,68 @@
+// Package pgtest provides a throwaway Postgres for tests
+package pgtest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// ciEnv is set by CI, tests which need Postgres fail there instead of being skipped
+const ciEnv = "CI"
+
+// DSN returns a connection string of the database from the env variable
+// if the variable isn't set an embedded Postgres is started for the test
+// the test is skipped if Postgres can't be started, e.g. its binaries can't be downloaded, and fails on CI
+func DSN(t *testing.T, env string) string {
+	t.Helper()
+
+	if dsn, ok := os.LookupEnv(env); ok {
+		return dsn
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("failed to get free port: %s", err)
+	}
+
+	pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(t.TempDir()).
+		Logger(io.Discard))
+
+	if err := pg.Start(); err != nil {
+		skip(t, "failed to start embedded postgres, set %s to run the test: %s", env, err)
+	}
+	t.Cleanup(func() {
+		if err := pg.Stop(); err != nil {
+			t.Errorf("failed to stop embedded postgres: %s", err)
+		}
+	})
+
+	return fmt.Sprintf("host=localhost port=%d user=postgres password=postgres dbname=postgres sslmode=disable", port)
+}
+
+// skip skips the test, on CI the test fails so missing Postgres is visible
+func skip(t *testing.T, format string, args ...any) {
+	t.Helper()
+
+	if _, ok := os.LookupEnv(ciEnv); ok {
+		t.Fatalf(format, args...)
+	}
+
+	t.Skipf(format, args...)
+}
+
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,167 @@
+package redisstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// clicksKeyPrefix is a prefix of sorted sets of click events of codes, events are scored by time in milliseconds
+const clicksKeyPrefix = keyPrefix + "clicks:"
+
+// ownersKeyPrefix is a prefix of sets of users of codes
+// userClicksKeyPrefix is a prefix of sorted sets of user's codes scored by count of clicks
+const (
+	ownersKeyPrefix     = keyPrefix + "owners:"
+	userClicksKeyPrefix = keyPrefix + "user-clicks:"
+)
+
+func clicksKey(code string) string {
+	return clicksKeyPrefix + code
+}
+
+func ownersKey(code string) string {
+	return ownersKeyPrefix + code
+}
+
+func userClicksKey(userID string) string {
+	return userClicksKeyPrefix + userID
+}
+
+// clickMember is a member of the sorted set of clicks, ID keeps equal clicks distinct
+type clickMember struct {
+	ID string `json:"id"`
+	service.Click
+}
+
+// SaveClicks adds click events to the sorted sets of their codes and counts them for users of the codes in one transaction
+// users of the codes are watched, so a user who gets a code concurrently starts from the right count
+func (s *Storage) SaveClicks(ctx context.Context, clicks []service.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, 0, len(clicks))
+	counts := make(map[string]int64)
+	codes := make([]string, 0)
+	keys := make([]string, 0)
+	for _, c := range clicks {
+		b, err := json.Marshal(clickMember{ID: uuid.NewString(), Click: c})
+		if err != nil {
+			return fmt.Errorf("failed to marshal click: %w", err)
+		}
+		members = append(members, redis.Z{Score: float64(c.Time.UnixMilli()), Member: b})
+
+		if _, ok := counts[c.Code]; !ok {
+			codes = append(codes, c.Code)
+			keys = append(keys, ownersKey(c.Code))
+		}
+		counts[c.Code]++
+	}
+
+	err := s.watch(ctx, func(tx *redis.Tx) error {
+		owners := make([]*redis.StringSliceCmd, 0, len(codes))
+		_, err := tx.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for _, code := range codes {
+				owners = append(owners, p.SMembers(ctx, ownersKey(code)))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get users of codes: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			for i, c := range clicks {
+				p.ZAdd(ctx, clicksKey(c.Code), members[i])
+			}
+
+			for i, code := range codes {
+				for _, userID := range owners[i].Val() {
+					p.ZIncrBy(ctx, userClicksKey(userID), float64(counts[code]), code)
+				}
+			}
+			return nil
+		})
+
+		return err
+	}, keys...)
+	if err != nil {
+		return fmt.Errorf("failed to save clicks: %w", err)
+	}
+
+	return nil
+}
+
+// ClickCount returns count of clicks of the code
+func (s *Storage) ClickCount(ctx context.Context, code string) (int, error) {
+	exists, err := s.Exists(ctx, code)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, service.ErrNotFound
+	}
+
+	cnt, err := s.client.ZCard(ctx, clicksKey(code)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count clicks: %w", err)
+	}
+
+	return int(cnt), nil
+}
+
+// Clicks returns the latest click events of the code, only the requested events are read
+// if limit isn't positive returns all events
+func (s *Storage) Clicks(ctx context.Context, code string, limit int) ([]service.Click, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit) - 1
+	}
+
+	rows, err := s.client.ZRevRange(ctx, clicksKey(code), 0, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clicks: %w", err)
+	}
+
+	return unmarshalClicks(rows)
+}
+
+// ClickStats aggregates clicks of the code in the range of the query, only clicks of the range are read
+func (s *Storage) ClickStats(ctx context.Context, q service.ClickStatsQuery) (*service.ClickStats, error) {
+	rows, err := s.client.ZRangeByScore(ctx, clicksKey(q.Code), &redis.ZRangeBy{
+		Min: strconv.FormatInt(q.From.UnixMilli(), 10),
+		Max: strconv.FormatInt(q.To.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clicks: %w", err)
+	}
+
+	clicks, err := unmarshalClicks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.AggregateClicks(clicks, q), nil
+}
+
+func unmarshalClicks(rows []string) ([]service.Click, error) {
+	clicks := make([]service.Click, 0, len(rows))
+	for _, row := range rows {
+		m := clickMember{}
+		if err := json.Unmarshal([]byte(row), &m); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal click: %w", err)
+		}
+
+		clicks = append(clicks, m.Click)
+	}
+
+	return clicks, nil
+}
@@ -0,0 +1,547 @@
+// Package redisstorage implements storage on a Redis compatible server
+package redisstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// Keys of the storage
+// every short URL is a hash with fields of urlField, expiresAtField and deletedField
+const (
+	keyPrefix      = "shortener:"
+	urlKeyPrefix   = keyPrefix + "url:"
+	codeKeyPrefix  = keyPrefix + "code:"
+	userKeyPrefix  = keyPrefix + "user:"
+	liveURLsKey    = keyPrefix + "urls:live"
+	expiringKey    = keyPrefix + "urls:expiring"
+	usersKey       = keyPrefix + "users"
+	urlField       = "url"
+	expiresAtField = "expires_at"
+	deletedField   = "deleted"
+)
+
+// iteratePageSize is a count of URLs read by a single round trip while iterating
+const iteratePageSize = 500
+
+// maxWatchAttempts limits attempts of a transaction which fails because the watched keys are changed concurrently
+const maxWatchAttempts = 10
+
+// New is Storage constructor
+func New(client *redis.Client) *Storage {
+	return &Storage{
+		client: client,
+	}
+}
+
+// Storage is storage main struct
+type Storage struct {
+	client *redis.Client
+}
+
+func urlKey(code string) string {
+	return urlKeyPrefix + code
+}
+
+func codeKey(url string) string {
+	return codeKeyPrefix + url
+}
+
+func userKey(userID string) string {
+	return userKeyPrefix + userID
+}
+
+// watch runs fn in a transaction watching the keys and retries it if the keys are changed concurrently
+// fn checks the keys on every attempt, so a retry sees the change which failed the previous one
+func (s *Storage) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	for attempt := 0; attempt < maxWatchAttempts; attempt++ {
+		err := s.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to run transaction in %d attempts: %w", maxWatchAttempts, redis.TxFailedErr)
+}
+
+// Save saves code with URL
+// returns service.ErrCodeAlreadyExists if the code is taken and service.ErrURLAlreadyExists if the URL is already saved
+func (s *Storage) Save(ctx context.Context, code, url string, expiresAt time.Time) error {
+	err := s.saveURLs(ctx, "", []service.URL{{Code: code, OriginalURL: url, ExpiresAt: expiresAt}})
+
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Conflicts[0].Err
+	}
+
+	return err
+}
+
+// SaveBatch saves URLs and makes the user their owner if the user is set in one transaction
+// returns *service.BatchError if any of codes or URLs is already saved
+func (s *Storage) SaveBatch(ctx context.Context, userID string, urls []service.URL) error {
+	return s.saveURLs(ctx, userID, urls)
+}
+
+// saveURLs checks that codes and URLs are free and saves them in one pipelined transaction
+// the keys are watched, so the transaction is retried if any of them is changed after the check
+func (s *Storage) saveURLs(ctx context.Context, userID string, urls []service.URL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(urls)*2)
+	for _, u := range urls {
+		keys = append(keys, urlKey(u.Code), codeKey(u.OriginalURL))
+	}
+
+	err := s.watch(ctx, func(tx *redis.Tx) error {
+		existing := make(map[string]*redis.IntCmd, len(keys))
+		_, err := tx.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for _, k := range keys {
+				existing[k] = p.Exists(ctx, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check keys: %w", err)
+		}
+
+		err = service.CheckBatch(urls,
+			func(code string) bool { return existing[urlKey(code)].Val() > 0 },
+			func(url string) bool { return existing[codeKey(url)].Val() > 0 },
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			now := time.Now().UnixNano()
+			for i, u := range urls {
+				fields := map[string]any{urlField: u.OriginalURL}
+				if !u.ExpiresAt.IsZero() {
+					fields[expiresAtField] = u.ExpiresAt.UnixNano()
+					p.ZAdd(ctx, expiringKey, redis.Z{Score: float64(u.ExpiresAt.UnixMilli()), Member: u.Code})
+				}
+
+				p.HSet(ctx, urlKey(u.Code), fields)
+				p.Set(ctx, codeKey(u.OriginalURL), u.Code, 0)
+				p.SAdd(ctx, liveURLsKey, u.Code)
+
+				if userID != "" {
+					p.ZAddNX(ctx, userKey(userID), redis.Z{Score: float64(now + int64(i)), Member: u.Code})
+					p.ZAddNX(ctx, userClicksKey(userID), redis.Z{Score: 0, Member: u.Code})
+					p.SAdd(ctx, ownersKey(u.Code), userID)
+				}
+			}
+
+			if userID != "" {
+				p.SAdd(ctx, usersKey, userID)
+			}
+			return nil
+		})
+
+		return err
+	}, keys...)
+	if err != nil {
+		var batchErr *service.BatchError
+		if errors.As(err, &batchErr) {
+			return err
+		}
+
+		return fmt.Errorf("failed to save urls: %w", err)
+	}
+
+	return nil
+}
+
+// Exists seek code and returns true if it exists otherwise false
+func (s *Storage) Exists(ctx context.Context, code string) (bool, error) {
+	n, err := s.client.Exists(ctx, urlKey(code)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check key: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// URL returns URL by code
+func (s *Storage) URL(ctx context.Context, code string) (string, error) {
+	url, _, err := s.URLWithExpiry(ctx, code)
+	return url, err
+}
+
+// URLWithExpiry returns URL by code with the time when it expires, zero time means never
+func (s *Storage) URLWithExpiry(ctx context.Context, code string) (string, time.Time, error) {
+	fields, err := s.client.HGetAll(ctx, urlKey(code)).Result()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get url: %w", err)
+	}
+
+	url, ok := fields[urlField]
+	if !ok {
+		return "", time.Time{}, service.ErrNotFound
+	}
+
+	if fields[deletedField] != "" {
+		return "", time.Time{}, service.ErrDeleted
+	}
+
+	var expiresAt time.Time
+	if v, ok := fields[expiresAtField]; ok {
+		nsec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to parse expiration: %w", err)
+		}
+
+		expiresAt = time.Unix(0, nsec)
+		if !expiresAt.After(time.Now()) {
+			return "", time.Time{}, service.ErrExpired
+		}
+	}
+
+	return url, expiresAt, nil
+}
+
+// CodeByURL returns code by URL
+// returns service.ErrNotFound if the URL isn't saved
+func (s *Storage) CodeByURL(ctx context.Context, url string) (string, error) {
+	code, err := s.client.Get(ctx, codeKey(url)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", service.ErrNotFound
+		}
+		return "", fmt.Errorf("failed to get code: %w", err)
+	}
+
+	return code, nil
+}
+
+// UpdateURL replaces URL of the code and moves the code from the key of the previous URL to the key of the new one
+// the keys are watched, so the update is retried if the code or the URL is changed concurrently
+func (s *Storage) UpdateURL(ctx context.Context, userID, code, url string) error {
+	err := s.watch(ctx, func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(ctx, urlKey(code)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get url: %w", err)
+		}
+
+		prev, ok := fields[urlField]
+		switch {
+		case !ok:
+			return service.ErrNotFound
+		case fields[deletedField] != "":
+			return service.ErrDeleted
+		case prev == url:
+			return nil
+		}
+
+		n, err := tx.Exists(ctx, codeKey(url)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check key: %w", err)
+		}
+
+		if n > 0 {
+			return service.ErrURLAlreadyExists
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, urlKey(code), urlField, url)
+			p.Del(ctx, codeKey(prev))
+			p.Set(ctx, codeKey(url), code, 0)
+			return nil
+		})
+
+		return err
+	}, urlKey(code), codeKey(url))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) || errors.Is(err, service.ErrDeleted) || errors.Is(err, service.ErrURLAlreadyExists) {
+			return err
+		}
+
+		return fmt.Errorf("failed to update url: %w", err)
+	}
+
+	return nil
+}
+
+// SaveUsersCode saves codes belong to the user
+// user's codes are a sorted set ordered by time of saving, the sorted set of user's clicks starts from the current count
+// clicks of the code are watched, so the count isn't missed if clicks are saved concurrently
+func (s *Storage) SaveUsersCode(ctx context.Context, userID string, code string) error {
+	var added *redis.IntCmd
+	err := s.watch(ctx, func(tx *redis.Tx) error {
+		clicks, err := tx.ZCard(ctx, clicksKey(code)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to count clicks: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			added = p.ZAddNX(ctx, userKey(userID), redis.Z{Score: float64(time.Now().UnixNano()), Member: code})
+			p.ZAddNX(ctx, userClicksKey(userID), redis.Z{Score: float64(clicks), Member: code})
+			p.SAdd(ctx, ownersKey(code), userID)
+			p.SAdd(ctx, usersKey, userID)
+			return nil
+		})
+
+		return err
+	}, clicksKey(code))
+	if err != nil {
+		return fmt.Errorf("failed to save user's code: %w", err)
+	}
+
+	if added.Val() == 0 {
+		return service.ErrRecordAlreadyExists
+	}
+
+	return nil
+}
+
+// UsersURLCodes return all users URL codes
+func (s *Storage) UsersURLCodes(ctx context.Context, userID string) ([]string, error) {
+	codes, err := s.client.ZRange(ctx, userKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user's codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// DeleteURLs marks URLs as deleted by codes
+func (s *Storage) DeleteURLs(ctx context.Context, userID string, codes []string) error {
+	if _, err := s.deleteURLs(ctx, codes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// deleteURLs marks existing URLs as deleted in a pipeline
+// returns count of URLs which weren't deleted before
+func (s *Storage) deleteURLs(ctx context.Context, codes []string) (int, error) {
+	if len(codes) == 0 {
+		return 0, nil
+	}
+
+	existing := make([]*redis.IntCmd, 0, len(codes))
+	_, err := s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, code := range codes {
+			existing = append(existing, p.Exists(ctx, urlKey(code)))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to check keys: %w", err)
+	}
+
+	removed := make([]*redis.IntCmd, 0, len(codes))
+	_, err = s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, code := range codes {
+			if existing[i].Val() == 0 {
+				continue
+			}
+
+			p.HSet(ctx, urlKey(code), deletedField, 1)
+			p.ZRem(ctx, expiringKey, code)
+			removed = append(removed, p.SRem(ctx, liveURLsKey, code))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete urls: %w", err)
+	}
+
+	cnt := 0
+	for _, r := range removed {
+		cnt += int(r.Val())
+	}
+
+	return cnt, nil
+}
+
+// UsersURLs returns a page of user's URLs
+// a page without search is read by ranks of the sorted set of user's codes or of user's clicks,
+// pages sorted by clicks are positioned by the rank of the cursor code, so clicks between requests may shift them
+// search isn't indexed, a query with search reads all user's URLs and filters and sorts them in memory
+func (s *Storage) UsersURLs(ctx context.Context, userID string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+	if q.Search == "" && q.Limit > 0 {
+		switch q.Sort {
+		case service.UsersURLsSortCreated:
+			return s.usersURLsByRank(ctx, userKey(userID), q)
+		case service.UsersURLsSortClicks:
+			return s.usersURLsByRank(ctx, userClicksKey(userID), q)
+		}
+	}
+
+	urls := make([]service.UsersURL, 0)
+	err := s.IterateUsersURLs(ctx, userID, func(u service.UsersURL) error {
+		urls = append(urls, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return service.PageUsersURLs(urls, q), nil
+}
+
+// usersURLsByRank reads a page of user's URLs from the sorted set of user's codes starting after the rank of the cursor code
+// equal scores are ordered by code, the same way as service.PageUsersURLs does
+func (s *Storage) usersURLsByRank(ctx context.Context, key string, q service.UsersURLsQuery) (*service.UsersURLsPage, error) {
+
+	start := int64(0)
+	if q.After != nil {
+		rank := s.client.ZRank
+		if q.Desc {
+			rank = s.client.ZRevRank
+		}
+
+		r, err := rank(ctx, key, q.After.Code).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%w: unknown cursor", service.ErrInvalidUsersURLsQuery)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rank of cursor: %w", err)
+		}
+
+		start = r + 1
+	}
+
+	rangeCodes := s.client.ZRange
+	if q.Desc {
+		rangeCodes = s.client.ZRevRange
+	}
+
+	// one more code is read to know if there is a next page
+	codes, err := rangeCodes(ctx, key, start, start+int64(q.Limit)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user's codes: %w", err)
+	}
+
+	page := &service.UsersURLsPage{}
+	if len(codes) > q.Limit {
+		codes = codes[:q.Limit]
+		page.Next = &service.URLsCursor{Code: codes[len(codes)-1]}
+	}
+
+	page.URLs, err = s.usersURLs(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// IterateUsersURLs calls fn for every URL of the user, the URLs are read in pages of iteratePageSize codes
+func (s *Storage) IterateUsersURLs(ctx context.Context, userID string, fn func(service.UsersURL) error) error {
+	for start := int64(0); ; start += iteratePageSize {
+		codes, err := s.client.ZRange(ctx, userKey(userID), start, start+iteratePageSize-1).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get user's codes: %w", err)
+		}
+
+		urls, err := s.usersURLs(ctx, codes)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range urls {
+			if err := fn(u); err != nil {
+				return err
+			}
+		}
+
+		if len(codes) < iteratePageSize {
+			return nil
+		}
+	}
+}
+
+// usersURLs reads URLs and counts of clicks of the codes in a pipeline, unknown codes are skipped
+func (s *Storage) usersURLs(ctx context.Context, codes []string) ([]service.UsersURL, error) {
+	urls := make([]service.UsersURL, 0, len(codes))
+	if len(codes) == 0 {
+		return urls, nil
+	}
+
+	fieldCmds := make([]*redis.MapStringStringCmd, 0, len(codes))
+	clickCmds := make([]*redis.IntCmd, 0, len(codes))
+	_, err := s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, code := range codes {
+			fieldCmds = append(fieldCmds, p.HGetAll(ctx, urlKey(code)))
+			clickCmds = append(clickCmds, p.ZCard(ctx, clicksKey(code)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get urls: %w", err)
+	}
+
+	for i, code := range codes {
+		fields := fieldCmds[i].Val()
+
+		url, ok := fields[urlField]
+		if !ok {
+			continue
+		}
+
+		u := service.UsersURL{
+			Code:        code,
+			OriginalURL: url,
+			Deleted:     fields[deletedField] != "",
+			Clicks:      int(clickCmds[i].Val()),
+		}
+		if v, ok := fields[expiresAtField]; ok {
+			nsec, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse expiration: %w", err)
+			}
+			u.ExpiresAt = time.Unix(0, nsec)
+		}
+
+		urls = append(urls, u)
+	}
+
+	return urls, nil
+}
+
+// URLCount returns count of URLs which are not deleted
+func (s *Storage) URLCount(ctx context.Context) (int, error) {
+	cnt, err := s.client.SCard(ctx, liveURLsKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count urls: %w", err)
+	}
+
+	return int(cnt), nil
+}
+
+// UserCount returns count of users owning at least one code
+func (s *Storage) UserCount(ctx context.Context) (int, error) {
+	cnt, err := s.client.SCard(ctx, usersKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return int(cnt), nil
+}
+
+// DeleteExpiredURLs marks as deleted all URLs which expiration time has come
+// returns count of deleted URLs
+func (s *Storage) DeleteExpiredURLs(ctx context.Context) (int, error) {
+	codes, err := s.client.ZRangeByScore(ctx, expiringKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired codes: %w", err)
+	}
+
+	return s.deleteURLs(ctx, codes)
+}
@@ -0,0 +1,144 @@
+package redisstorage_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/service"
+	"github.com/lks-go/url-shortener/internal/transport/redisstorage"
+	"github.com/lks-go/url-shortener/internal/transport/storagetest"
+)
+
+// newStorage returns a storage connected to an in-process RESP server
+func newStorage(t *testing.T) *redisstorage.Storage {
+	t.Helper()
+
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return redisstorage.New(client)
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.RunURLStorage(t, func(t *testing.T) service.URLStorage {
+		return newStorage(t)
+	})
+}
+
+func TestStorage_Clicks(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t)
+	now := time.Now().UTC()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{
+		{Code: "abc", Time: now.Add(-time.Minute), IP: "10.0.0.1", Browser: "Chrome"},
+		{Code: "abc", Time: now, IP: "10.0.0.2", Browser: "Firefox"},
+	}))
+
+	cnt, err := s.ClickCount(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	latest, err := s.Clicks(ctx, "abc", 1)
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	assert.Equal(t, "10.0.0.2", latest[0].IP)
+
+	_, err = s.ClickCount(ctx, "unknown")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	// equal clicks are kept as distinct events
+	old := service.Click{Code: "abc", Time: now.Add(-time.Hour), IP: "10.0.0.3"}
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{old, old}))
+
+	all, err := s.Clicks(ctx, "abc", 0)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, "10.0.0.2", all[0].IP, "clicks are ordered from the latest")
+	assert.Equal(t, "10.0.0.3", all[3].IP)
+
+	stats, err := s.ClickStats(ctx, service.ClickStatsQuery{
+		Code:     "abc",
+		From:     now.Add(-2 * time.Hour),
+		To:       now.Add(-30 * time.Minute),
+		Interval: service.IntervalHour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Clicks, "only clicks of the range are aggregated")
+}
+
+func TestStorage_UsersURLsSortedByClicks(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t)
+	now := time.Now().UTC()
+
+	// clicks of a code saved before the user gets it are counted for the user too
+	require.NoError(t, s.Save(ctx, "aaa", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{{Code: "aaa", Time: now}, {Code: "aaa", Time: now}}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user", "aaa"))
+
+	require.NoError(t, s.SaveBatch(ctx, "user", []service.URL{
+		{Code: "bbb", OriginalURL: "https://google.com"},
+		{Code: "ccc", OriginalURL: "https://go.dev"},
+	}))
+	require.NoError(t, s.SaveClicks(ctx, []service.Click{
+		{Code: "ccc", Time: now}, {Code: "ccc", Time: now}, {Code: "ccc", Time: now}, {Code: "aaa", Time: now},
+	}))
+
+	q := service.UsersURLsQuery{Sort: service.UsersURLsSortClicks, Desc: true, Limit: 2}
+	page, err := s.UsersURLs(ctx, "user", q)
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 2)
+	// equal counts are ordered by code in the same direction
+	assert.Equal(t, "ccc", page.URLs[0].Code)
+	assert.Equal(t, "aaa", page.URLs[1].Code)
+	assert.Equal(t, 3, page.URLs[1].Clicks)
+	require.NotNil(t, page.Next)
+
+	q.After = page.Next
+	page, err = s.UsersURLs(ctx, "user", q)
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, "bbb", page.URLs[0].Code)
+	assert.Equal(t, 0, page.URLs[0].Clicks)
+	assert.Nil(t, page.Next)
+}
+
+func TestStorage_ConcurrentSave(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Save(ctx, "abc", "https://ya.ru", time.Time{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	saved := 0
+	for err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+
+		// concurrent transactions are retried and see the saved code instead of failing
+		assert.ErrorIs(t, err, service.ErrCodeAlreadyExists)
+	}
+	assert.Equal(t, 1, saved)
+}
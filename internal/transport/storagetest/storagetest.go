@@ -0,0 +1,508 @@
+// Package storagetest contains the contract which every service.URLStorage implementation must satisfy
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/service"
+)
+
+// Factory returns a new empty storage for every test case
+type Factory func(t *testing.T) service.URLStorage
+
+// RunURLStorage runs the conformance tests against storages made by the factory
+func RunURLStorage(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s service.URLStorage)
+	}{
+		{name: "save and get URL", run: testSave},
+		{name: "unknown code", run: testUnknown},
+		{name: "code already exists", run: testCodeAlreadyExists},
+		{name: "URL already exists", run: testURLAlreadyExists},
+		{name: "batch save", run: testSaveBatch},
+		{name: "batch is atomic", run: testSaveBatchAtomic},
+		{name: "batch ownership", run: testSaveBatchOwnership},
+		{name: "users ownership", run: testOwnership},
+		{name: "iterate users URLs", run: testIterateUsersURLs},
+		{name: "users URLs pages", run: testUsersURLsPages},
+		{name: "update URL", run: testUpdateURL},
+		{name: "soft delete", run: testSoftDelete},
+		{name: "counts", run: testCounts},
+		{name: "expiration", run: testExpiration},
+		{name: "context canceled", run: testContextCanceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStorage(t))
+		})
+	}
+}
+
+func testSave(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+
+	exists, err := s.Exists(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	code, err := s.CodeByURL(ctx, "https://ya.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", code)
+}
+
+func testUnknown(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.URL(ctx, "abc")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	exists, err := s.Exists(ctx, "abc")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = s.CodeByURL(ctx, "https://ya.ru")
+	require.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func testCodeAlreadyExists(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.ErrorIs(t, s.Save(ctx, "abc", "https://google.com", time.Time{}), service.ErrCodeAlreadyExists)
+
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+}
+
+func testURLAlreadyExists(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.ErrorIs(t, s.Save(ctx, "xyz", "https://ya.ru", time.Time{}), service.ErrURLAlreadyExists)
+
+	code, err := s.CodeByURL(ctx, "https://ya.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", code)
+
+	exists, err := s.Exists(ctx, "xyz")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testSaveBatch(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	urls := []service.URL{
+		{Code: "abc", OriginalURL: "https://ya.ru"},
+		{Code: "xyz", OriginalURL: "https://google.com"},
+	}
+	require.NoError(t, s.SaveBatch(ctx, "", urls))
+
+	for _, u := range urls {
+		url, err := s.URL(ctx, u.Code)
+		require.NoError(t, err)
+		assert.Equal(t, u.OriginalURL, url)
+	}
+}
+
+func testSaveBatchAtomic(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+
+	tests := []struct {
+		name          string
+		urls          []service.URL
+		wantErr       error
+		wantConflicts []service.BatchConflict
+	}{
+		{
+			name: "existing code",
+			urls: []service.URL{
+				{Code: "new1", OriginalURL: "https://new1.com"},
+				{Code: "abc", OriginalURL: "https://new2.com"},
+			},
+			wantErr: service.ErrCodeAlreadyExists,
+			wantConflicts: []service.BatchConflict{
+				{Index: 1, Code: "abc", Err: service.ErrCodeAlreadyExists},
+			},
+		},
+		{
+			name: "existing URL",
+			urls: []service.URL{
+				{Code: "new1", OriginalURL: "https://new1.com"},
+				{Code: "new2", OriginalURL: "https://ya.ru"},
+			},
+			wantErr: service.ErrURLAlreadyExists,
+			wantConflicts: []service.BatchConflict{
+				{Index: 1, Code: "new2", Err: service.ErrURLAlreadyExists},
+			},
+		},
+		{
+			name: "duplicated URL in batch",
+			urls: []service.URL{
+				{Code: "new1", OriginalURL: "https://new1.com"},
+				{Code: "new2", OriginalURL: "https://new1.com"},
+			},
+			wantErr: service.ErrURLAlreadyExists,
+			wantConflicts: []service.BatchConflict{
+				{Index: 1, Code: "new2", Err: service.ErrURLAlreadyExists},
+			},
+		},
+		{
+			name: "several conflicts",
+			urls: []service.URL{
+				{Code: "abc", OriginalURL: "https://new1.com"},
+				{Code: "new1", OriginalURL: "https://new2.com"},
+				{Code: "new2", OriginalURL: "https://ya.ru"},
+			},
+			wantErr: service.ErrCodeAlreadyExists,
+			wantConflicts: []service.BatchConflict{
+				{Index: 0, Code: "abc", Err: service.ErrCodeAlreadyExists},
+				{Index: 2, Code: "new2", Err: service.ErrURLAlreadyExists},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.SaveBatch(ctx, "user1", tt.urls)
+			require.ErrorIs(t, err, tt.wantErr)
+
+			var batchErr *service.BatchError
+			require.ErrorAs(t, err, &batchErr)
+			assert.Equal(t, tt.wantConflicts, batchErr.Conflicts)
+
+			exists, err := s.Exists(ctx, "new1")
+			require.NoError(t, err)
+			assert.False(t, exists, "batch must be saved entirely or not at all")
+		})
+	}
+
+	cnt, err := s.URLCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	codes, err := s.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, codes, "ownership must not be saved with a failed batch")
+}
+
+func testSaveBatchOwnership(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	urls := []service.URL{
+		{Code: "abc", OriginalURL: "https://ya.ru"},
+		{Code: "xyz", OriginalURL: "https://google.com"},
+	}
+	require.NoError(t, s.SaveBatch(ctx, "user1", urls))
+
+	codes, err := s.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"abc", "xyz"}, codes)
+
+	require.ErrorIs(t, s.SaveUsersCode(ctx, "user1", "abc"), service.ErrRecordAlreadyExists)
+
+	cnt, err := s.UserCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+}
+
+func testOwnership(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "xyz"))
+	require.ErrorIs(t, s.SaveUsersCode(ctx, "user1", "abc"), service.ErrRecordAlreadyExists)
+
+	codes, err := s.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"abc", "xyz"}, codes)
+
+	page, err := s.UsersURLs(ctx, "user1", service.UsersURLsQuery{Sort: service.UsersURLsSortCreated})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []service.UsersURL{
+		{Code: "abc", OriginalURL: "https://ya.ru"},
+		{Code: "xyz", OriginalURL: "https://google.com"},
+	}, page.URLs)
+	assert.Nil(t, page.Next)
+
+	codes, err = s.UsersURLCodes(ctx, "user2")
+	require.NoError(t, err)
+	assert.Empty(t, codes)
+
+	page, err = s.UsersURLs(ctx, "user2", service.UsersURLsQuery{Sort: service.UsersURLsSortCreated})
+	require.NoError(t, err)
+	assert.Empty(t, page.URLs)
+}
+
+func testUsersURLsPages(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	urls := []service.URL{
+		{Code: "c1", OriginalURL: "https://ya.ru/one"},
+		{Code: "c2", OriginalURL: "https://google.com/two"},
+		{Code: "c3", OriginalURL: "https://YA.ru/three"},
+		{Code: "c4", OriginalURL: "https://bing.com/four"},
+		{Code: "c5", OriginalURL: "https://ya.ru/five"},
+	}
+	for _, u := range urls {
+		require.NoError(t, s.Save(ctx, u.Code, u.OriginalURL, time.Time{}))
+		require.NoError(t, s.SaveUsersCode(ctx, "user1", u.Code))
+	}
+
+	if cs, ok := s.(service.ClickStorage); ok {
+		now := time.Now()
+		require.NoError(t, cs.SaveClicks(ctx, []service.Click{
+			{Code: "c4", Time: now}, {Code: "c4", Time: now}, {Code: "c4", Time: now},
+			{Code: "c2", Time: now}, {Code: "c2", Time: now},
+			{Code: "c5", Time: now},
+		}))
+	}
+
+	// pages reads all pages of the query and returns codes of every page
+	pages := func(q service.UsersURLsQuery) [][]string {
+		res := make([][]string, 0)
+		for {
+			page, err := s.UsersURLs(ctx, "user1", q)
+			require.NoError(t, err)
+
+			codes := make([]string, 0, len(page.URLs))
+			for _, u := range page.URLs {
+				codes = append(codes, u.Code)
+			}
+			res = append(res, codes)
+
+			if page.Next == nil {
+				return res
+			}
+			require.Less(t, len(res), len(urls), "pages must end")
+			q.After = page.Next
+		}
+	}
+
+	assert.Equal(t, [][]string{{"c1", "c2"}, {"c3", "c4"}, {"c5"}},
+		pages(service.UsersURLsQuery{Sort: service.UsersURLsSortCreated, Limit: 2}))
+	assert.Equal(t, [][]string{{"c5", "c4", "c3"}, {"c2", "c1"}},
+		pages(service.UsersURLsQuery{Sort: service.UsersURLsSortCreated, Desc: true, Limit: 3}))
+	assert.Equal(t, [][]string{{"c1", "c3"}, {"c5"}},
+		pages(service.UsersURLsQuery{Sort: service.UsersURLsSortCreated, Search: "ya.RU", Limit: 2}))
+	assert.Equal(t, [][]string{{"c1", "c2", "c3", "c4", "c5"}},
+		pages(service.UsersURLsQuery{Sort: service.UsersURLsSortCreated}))
+
+	if _, ok := s.(service.ClickStorage); !ok {
+		return
+	}
+
+	assert.Equal(t, [][]string{{"c4", "c2"}, {"c5", "c3"}, {"c1"}},
+		pages(service.UsersURLsQuery{Sort: service.UsersURLsSortClicks, Desc: true, Limit: 2}))
+	assert.Equal(t, [][]string{{"c1", "c3", "c5"}, {"c2", "c4"}},
+		pages(service.UsersURLsQuery{Sort: service.UsersURLsSortClicks, Limit: 3}))
+
+	page, err := s.UsersURLs(ctx, "user1", service.UsersURLsQuery{Sort: service.UsersURLsSortClicks, Desc: true, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, 3, page.URLs[0].Clicks)
+}
+
+func testIterateUsersURLs(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", expiresAt))
+	require.NoError(t, s.Save(ctx, "def", "https://bing.com", time.Time{}))
+	for _, code := range []string{"abc", "xyz", "def"} {
+		require.NoError(t, s.SaveUsersCode(ctx, "user1", code))
+	}
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"def"}))
+
+	urls := make([]service.UsersURL, 0)
+	err := s.IterateUsersURLs(ctx, "user1", func(u service.UsersURL) error {
+		urls = append(urls, u)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, urls, 3)
+
+	assert.Equal(t, service.UsersURL{Code: "abc", OriginalURL: "https://ya.ru"}, urls[0])
+	assert.Equal(t, "xyz", urls[1].Code)
+	assert.WithinDuration(t, expiresAt, urls[1].ExpiresAt, time.Millisecond)
+	assert.False(t, urls[1].Deleted)
+	assert.Equal(t, service.UsersURL{Code: "def", OriginalURL: "https://bing.com", Deleted: true}, urls[2])
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = s.IterateUsersURLs(ctx, "user1", func(u service.UsersURL) error {
+		calls++
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+
+	err = s.IterateUsersURLs(ctx, "user2", func(u service.UsersURL) error {
+		t.Fatal("user2 has no URLs")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testUpdateURL(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+
+	require.NoError(t, s.UpdateURL(ctx, "user1", "abc", "https://ya.com"))
+	require.NoError(t, s.UpdateURL(ctx, "user1", "abc", "https://ya.com"), "the same URL must be accepted")
+
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.com", url)
+
+	code, err := s.CodeByURL(ctx, "https://ya.com")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", code)
+
+	_, err = s.CodeByURL(ctx, "https://ya.ru")
+	require.ErrorIs(t, err, service.ErrNotFound, "the previous URL must be released")
+
+	require.ErrorIs(t, s.UpdateURL(ctx, "user1", "abc", "https://google.com"), service.ErrURLAlreadyExists)
+	require.ErrorIs(t, s.UpdateURL(ctx, "user1", "unknown", "https://example.com"), service.ErrNotFound)
+
+	require.NoError(t, s.Save(ctx, "new", "https://ya.ru", time.Time{}), "the previous URL must be free for shortening")
+
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"xyz"}))
+	require.ErrorIs(t, s.UpdateURL(ctx, "user1", "xyz", "https://example.com"), service.ErrDeleted)
+}
+
+func testSoftDelete(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"abc", "unknown"}))
+
+	_, err := s.URL(ctx, "abc")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	exists, err := s.Exists(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, exists, "deleted code must stay taken")
+
+	url, err := s.URL(ctx, "xyz")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+}
+
+func testCounts(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	cnt, err := s.URLCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+
+	cnt, err = s.UserCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+
+	require.NoError(t, s.Save(ctx, "abc", "https://ya.ru", time.Time{}))
+	require.NoError(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}))
+	require.NoError(t, s.Save(ctx, "qwe", "https://example.com", time.Time{}))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "abc"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user1", "xyz"))
+	require.NoError(t, s.SaveUsersCode(ctx, "user2", "qwe"))
+	require.NoError(t, s.DeleteURLs(ctx, "user1", []string{"qwe"}))
+
+	cnt, err = s.URLCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	cnt, err = s.UserCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+}
+
+func testExpiration(t *testing.T, s service.URLStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, "old", "https://ya.ru", time.Now().Add(-time.Minute)))
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, s.Save(ctx, "new", "https://google.com", expiresAt))
+
+	_, err := s.URL(ctx, "old")
+	require.ErrorIs(t, err, service.ErrExpired)
+
+	// storages which return the expiration let the cache drop URLs when they expire
+	if es, ok := s.(interface {
+		URLWithExpiry(ctx context.Context, code string) (string, time.Time, error)
+	}); ok {
+		url, gotExpiresAt, err := es.URLWithExpiry(ctx, "new")
+		require.NoError(t, err)
+		assert.Equal(t, "https://google.com", url)
+		assert.WithinDuration(t, expiresAt, gotExpiresAt, time.Millisecond)
+	}
+
+	cnt, err := s.DeleteExpiredURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	_, err = s.URL(ctx, "old")
+	require.ErrorIs(t, err, service.ErrDeleted)
+
+	url, err := s.URL(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+
+	cnt, err = s.DeleteExpiredURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+}
+
+func testContextCanceled(t *testing.T, s service.URLStorage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, s.Save(context.Background(), "abc", "https://ya.ru", time.Time{}))
+
+	require.ErrorIs(t, s.Save(ctx, "xyz", "https://google.com", time.Time{}), context.Canceled)
+	require.ErrorIs(t, s.SaveBatch(ctx, "user1", []service.URL{{Code: "qwe", OriginalURL: "https://example.com"}}), context.Canceled)
+	require.ErrorIs(t, s.SaveUsersCode(ctx, "user1", "abc"), context.Canceled)
+	require.ErrorIs(t, s.DeleteURLs(ctx, "user1", []string{"abc"}), context.Canceled)
+
+	_, err := s.URL(ctx, "abc")
+	require.ErrorIs(t, err, context.Canceled)
+
+	ctx = context.Background()
+
+	for _, code := range []string{"xyz", "qwe"} {
+		exists, err := s.Exists(ctx, code)
+		require.NoError(t, err)
+		assert.False(t, exists, "code %s must not be saved with canceled context", code)
+	}
+
+	codes, err := s.UsersURLCodes(ctx, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, codes)
+
+	url, err := s.URL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+}
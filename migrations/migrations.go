@@ -1,85 +1,341 @@
+// Package migrations contains versioned migrations of the database schema
+// every migration is a pair of files sql/NNNN_name.up.sql and sql/NNNN_name.down.sql
 package migrations
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID is a key of the advisory lock which is held while migrations are run
+// so concurrent replicas don't migrate the database at the same time
+const lockID = 7263514890
+
+// Migration is a single version of the schema
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and the time it was applied at
+// AppliedAt is nil if the migration isn't applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
 // RunUp up migration to the last version
 func RunUp(db *sql.DB) error {
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
 
-	if err := createTableShorten(db); err != nil {
-		return fmt.Errorf("failed to create table 'shorten': %w", err)
+	if _, err := m.Up(context.Background()); err != nil {
+		return err
 	}
 
-	if err := createIndexForURL(db); err != nil {
-		return fmt.Errorf("failed to create index for column 'url' in table 'shorten': %w", err)
+	return nil
+}
+
+// New returns Migrator of the embedded migrations
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(files, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
 	}
 
-	if err := createTableUserCodes(db); err != nil {
-		return fmt.Errorf("failed to create table 'user_codes': %w", err)
+	migrations, err := Load(sub)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := createIndexForUserCodes(db); err != nil {
-		return fmt.Errorf("failed to create index for 'user_codes': %w", err)
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrator applies and rolls back migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Load reads migrations from the root of fsys and sorts them by version
+// every version must have both up and down files
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
 	}
 
-	if err := addColumnDeleteToShorten(db); err != nil {
-		return fmt.Errorf("failed to add column to 'shorten': %w", err)
+	byVersion := make(map[int64]*Migration)
+	for _, name := range names {
+		version, title, direction, err := parseFilename(name)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %d has different names %s and %s", version, m.Name, title)
+		}
+
+		switch direction {
+		case "up":
+			m.Up = string(b)
+		case "down":
+			m.Down = string(b)
+		}
 	}
 
-	return nil
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
 }
 
-func createTableShorten(db *sql.DB) error {
-	q := `CREATE TABLE IF NOT EXISTS shorten (
-			id SERIAL PRIMARY KEY,
-			code VARCHAR UNIQUE NOT NULL,
-			url VARCHAR NOT NULL
-		)`
+// parseFilename splits names like 0001_create_shorten.up.sql
+func parseFilename(name string) (int64, string, string, error) {
+	base := strings.TrimSuffix(path.Base(name), ".sql")
 
-	if _, err := db.Exec(q); err != nil {
-		return fmt.Errorf("failed to exec query: %w", err)
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 {
+		return 0, "", "", fmt.Errorf("migration %s has no direction", name)
 	}
 
-	return nil
+	direction := base[dot+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("migration %s has unknown direction %s", name, direction)
+	}
+
+	version, title, ok := strings.Cut(base[:dot], "_")
+	if !ok || title == "" {
+		return 0, "", "", fmt.Errorf("migration %s has no name", name)
+	}
+
+	v, err := strconv.ParseInt(version, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s has invalid version", name)
+	}
+
+	return v, title, direction, nil
+}
+
+// Up applies all migrations which aren't applied yet
+// returns the applied migrations
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := make([]Migration, 0)
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mg := range m.migrations {
+			if _, ok := versions[mg.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mg.Up); err != nil {
+					return fmt.Errorf("failed to exec query: %w", err)
+				}
+
+				q := `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, now())`
+				if _, err := tx.ExecContext(ctx, q, mg.Version, mg.Name); err != nil {
+					return fmt.Errorf("failed to save version: %w", err)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+
+			applied = append(applied, mg)
+		}
+
+		return nil
+	})
+
+	return applied, err
 }
 
-func createIndexForURL(db *sql.DB) error {
-	q := `CREATE UNIQUE INDEX IF NOT EXISTS shorten_url_key ON shorten (url)`
-	if _, err := db.Exec(q); err != nil {
-		return fmt.Errorf("failed to exec query: %w", err)
+// Down rolls back the given count of the latest applied migrations
+// returns the rolled back migrations
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	rolledBack := make([]Migration, 0)
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			mg := m.migrations[i]
+			if _, ok := versions[mg.Version]; !ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mg.Down); err != nil {
+					return fmt.Errorf("failed to exec query: %w", err)
+				}
+
+				if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version); err != nil {
+					return fmt.Errorf("failed to delete version: %w", err)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+
+			rolledBack = append(rolledBack, mg)
+		}
+
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status returns all known migrations with the time they were applied at
+// it's read only, so it doesn't wait for the lock and nothing is applied if the table of versions doesn't exist
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
+	defer conn.Close()
 
-	return nil
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check table 'schema_migrations': %w", err)
+	}
+
+	versions := make(map[int64]time.Time)
+	if exists {
+		versions, err = appliedVersions(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := Status{Migration: mg}
+		if appliedAt, ok := versions[mg.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
 }
 
-func createTableUserCodes(db *sql.DB) error {
-	q := `CREATE TABLE IF NOT EXISTS USER_CODES (
-		USER_ID UUID  NOT NULL,
-		CODE VARCHAR NOT NULL
-	);`
+// withLock runs fn on a single connection holding the advisory lock
+// the table of versions is created if it doesn't exist
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
 
-	if _, err := db.Exec(q); err != nil {
-		return fmt.Errorf("failed to exec query: %w", err)
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
 	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
 
-	return nil
+	q := `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`
+	if _, err := conn.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("failed to create table 'schema_migrations': %w", err)
+	}
+
+	return fn(conn)
 }
 
-func createIndexForUserCodes(db *sql.DB) error {
-	q := `CREATE UNIQUE INDEX IF NOT EXISTS USER_ID_CODE_KEY ON USER_CODES(USER_ID, CODE);`
-	if _, err := db.Exec(q); err != nil {
-		return fmt.Errorf("failed to exec query: %w", err)
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied versions: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+
+		versions[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read versions: %w", err)
+	}
+
+	return versions, nil
 }
 
-func addColumnDeleteToShorten(db *sql.DB) error {
-	q := `ALTER TABLE shorten ADD COLUMN IF NOT EXISTS deleted BOOL;`
-	if _, err := db.Exec(q); err != nil {
-		return fmt.Errorf("failed to exec query: %w", err)
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,123 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lks-go/url-shortener/internal/transport/pgtest"
+	"github.com/lks-go/url-shortener/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []migrations.Migration
+		wantErr bool
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"0010_second.up.sql":   file("up2"),
+				"0010_second.down.sql": file("down2"),
+				"0002_first.up.sql":    file("up1"),
+				"0002_first.down.sql":  file("down1"),
+				"README.md":            file("not a migration"),
+			},
+			want: []migrations.Migration{
+				{Version: 2, Name: "first", Up: "up1", Down: "down1"},
+				{Version: 10, Name: "second", Up: "up2", Down: "down2"},
+			},
+		},
+		{
+			name:    "missing down",
+			fsys:    fstest.MapFS{"0001_first.up.sql": file("up")},
+			wantErr: true,
+		},
+		{
+			name: "different names of version",
+			fsys: fstest.MapFS{
+				"0001_first.up.sql":     file("up"),
+				"0001_another.down.sql": file("down"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unknown direction",
+			fsys:    fstest.MapFS{"0001_first.sideways.sql": file("up")},
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			fsys:    fstest.MapFS{"first.up.sql": file("up")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := migrations.Load(tt.fsys)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNew_Embedded(t *testing.T) {
+	_, err := migrations.New(nil)
+	require.NoError(t, err)
+}
+
+// testDSNEnv is a connection string of a throwaway database, its schema is dropped by the test
+// if it isn't set an embedded Postgres is started
+const testDSNEnv = "TEST_MIGRATIONS_DSN"
+
+func TestMigrator_UpDown(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("pgx", pgtest.DSN(t, testDSNEnv))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.New(db)
+	require.NoError(t, err)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, "migration %d must be applied", s.Version)
+	}
+
+	rolledBack, err := m.Down(ctx, len(statuses))
+	require.NoError(t, err)
+	assert.Len(t, rolledBack, len(statuses))
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.Nil(t, s.AppliedAt, "migration %d must be rolled back", s.Version)
+	}
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(statuses))
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS shorten (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR UNIQUE NOT NULL,
+    url VARCHAR NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS shorten_url_key ON shorten (url);
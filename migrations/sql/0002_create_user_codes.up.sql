@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user_codes (
+    user_id UUID NOT NULL,
+    code VARCHAR NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_id_code_key ON user_codes (user_id, code);
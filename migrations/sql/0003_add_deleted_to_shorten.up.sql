@@ -0,0 +1 @@
+ALTER TABLE shorten ADD COLUMN IF NOT EXISTS deleted BOOL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS shorten_expires_at_idx;
+
+ALTER TABLE shorten DROP COLUMN IF EXISTS expires_at;
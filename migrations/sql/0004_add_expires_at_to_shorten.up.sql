@@ -0,0 +1,3 @@
+ALTER TABLE shorten ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS shorten_expires_at_idx ON shorten (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE shorten DROP COLUMN IF EXISTS clicks;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS click_events (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer VARCHAR NOT NULL DEFAULT '',
+    user_agent VARCHAR NOT NULL DEFAULT '',
+    ip VARCHAR NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS click_events_code_clicked_at_idx ON click_events (code, clicked_at);
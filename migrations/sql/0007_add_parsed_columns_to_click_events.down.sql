@@ -0,0 +1,4 @@
+ALTER TABLE click_events
+    DROP COLUMN IF EXISTS referrer_domain,
+    DROP COLUMN IF EXISTS browser,
+    DROP COLUMN IF EXISTS os;
@@ -0,0 +1,4 @@
+ALTER TABLE click_events
+    ADD COLUMN IF NOT EXISTS referrer_domain VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS browser VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS os VARCHAR NOT NULL DEFAULT '';
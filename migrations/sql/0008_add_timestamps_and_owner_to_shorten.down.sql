@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS shorten_live_idx;
+DROP INDEX IF EXISTS shorten_owner_id_idx;
+DROP INDEX IF EXISTS shorten_expires_at_idx;
+
+CREATE INDEX shorten_expires_at_idx ON shorten (expires_at) WHERE expires_at IS NOT NULL;
+
+ALTER TABLE shorten ADD COLUMN deleted BOOL;
+
+UPDATE shorten SET deleted = true WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE user_codes DROP COLUMN created_at;
+
+ALTER TABLE shorten
+    DROP COLUMN owner_id,
+    DROP COLUMN deleted_at,
+    DROP COLUMN created_at;
@@ -0,0 +1,22 @@
+ALTER TABLE shorten
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN deleted_at TIMESTAMPTZ,
+    ADD COLUMN owner_id UUID;
+
+ALTER TABLE user_codes ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- the time of deletion of existing rows is unknown, so the time of the migration is used
+UPDATE shorten SET deleted_at = now() WHERE deleted IS TRUE;
+
+-- the time of saving is unknown for existing rows, so the owner is chosen deterministically among users of the code
+UPDATE shorten s SET owner_id = uc.user_id
+FROM (SELECT DISTINCT ON (code) code, user_id FROM user_codes ORDER BY code, user_id) uc
+WHERE s.code = uc.code;
+
+ALTER TABLE shorten DROP COLUMN deleted;
+
+DROP INDEX IF EXISTS shorten_expires_at_idx;
+
+CREATE INDEX shorten_expires_at_idx ON shorten (expires_at) WHERE expires_at IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX shorten_owner_id_idx ON shorten (owner_id, created_at) WHERE deleted_at IS NULL;
+CREATE INDEX shorten_live_idx ON shorten (created_at) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE click_events DROP CONSTRAINT IF EXISTS click_events_code_fkey;
+
+ALTER TABLE user_codes DROP CONSTRAINT IF EXISTS user_codes_code_fkey;
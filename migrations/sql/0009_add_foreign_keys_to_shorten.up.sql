@@ -0,0 +1,9 @@
+-- rows referring to unknown codes can't satisfy the foreign keys and are useless anyway
+DELETE FROM user_codes uc WHERE NOT EXISTS (SELECT 1 FROM shorten s WHERE s.code = uc.code);
+DELETE FROM click_events ce WHERE NOT EXISTS (SELECT 1 FROM shorten s WHERE s.code = ce.code);
+
+ALTER TABLE user_codes
+    ADD CONSTRAINT user_codes_code_fkey FOREIGN KEY (code) REFERENCES shorten (code) ON DELETE CASCADE;
+
+ALTER TABLE click_events
+    ADD CONSTRAINT click_events_code_fkey FOREIGN KEY (code) REFERENCES shorten (code) ON DELETE CASCADE;
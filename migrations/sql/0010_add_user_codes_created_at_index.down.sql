@@ -0,0 +1 @@
+DROP INDEX IF EXISTS user_codes_user_id_created_at_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS user_codes_user_id_created_at_idx ON user_codes (user_id, created_at, code);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS shorten_history (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR NOT NULL REFERENCES shorten (code) ON DELETE CASCADE,
+    url VARCHAR NOT NULL,
+    user_id UUID NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS shorten_history_code_changed_at_idx ON shorten_history (code, changed_at);
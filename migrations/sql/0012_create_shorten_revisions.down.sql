@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS shorten_history (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR NOT NULL REFERENCES shorten (code) ON DELETE CASCADE,
+    url VARCHAR NOT NULL,
+    user_id UUID NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS shorten_history_code_changed_at_idx ON shorten_history (code, changed_at);
+
+-- only updates made by users are kept, restorations are lost
+INSERT INTO shorten_history (code, url, user_id, changed_at)
+SELECT code, previous_url, user_id, created_at
+FROM shorten_revisions
+WHERE action = 'updated' AND user_id IS NOT NULL
+ORDER BY id;
+
+DROP TABLE IF EXISTS shorten_revisions;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS shorten_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR NOT NULL REFERENCES shorten (code) ON DELETE CASCADE,
+    action VARCHAR NOT NULL,
+    url VARCHAR NOT NULL,
+    previous_url VARCHAR,
+    user_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS shorten_revisions_code_id_idx ON shorten_revisions (code, id);
+
+-- the first destination of a code is the previous URL of its first change or the current URL if it isn't changed
+INSERT INTO shorten_revisions (code, action, url, user_id, created_at)
+SELECT s.code, 'created', COALESCE(h.url, s.url), s.owner_id, s.created_at
+FROM shorten s
+LEFT JOIN LATERAL (SELECT url FROM shorten_history WHERE code = s.code ORDER BY id LIMIT 1) h ON true
+ORDER BY s.created_at, s.code;
+
+-- every change of the history sets the previous URL of the next change or the current URL if it's the last one
+INSERT INTO shorten_revisions (code, action, url, previous_url, user_id, created_at)
+SELECT h.code, 'updated', COALESCE(LEAD(h.url) OVER (PARTITION BY h.code ORDER BY h.id), s.url), h.url, h.user_id, h.changed_at
+FROM shorten_history h
+JOIN shorten s ON s.code = h.code
+ORDER BY h.id;
+
+-- the author of existing deletions is unknown
+INSERT INTO shorten_revisions (code, action, url, previous_url, created_at)
+SELECT code, 'deleted', url, url, deleted_at
+FROM shorten
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at, code;
+
+DROP TABLE shorten_history;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS accounts (
+    id UUID PRIMARY KEY,
+    login VARCHAR NOT NULL,
+    password_hash VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_login_key ON accounts (login);
+
+-- only sha256 of keys is stored, the keys themselves are shown to the user once
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    scopes VARCHAR[] NOT NULL,
+    key_hash VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_key_hash_key ON api_keys (key_hash);
+CREATE INDEX IF NOT EXISTS api_keys_user_id_created_at_idx ON api_keys (user_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS banned_users;
+
+ALTER TABLE shorten DROP COLUMN IF EXISTS blocked_at;
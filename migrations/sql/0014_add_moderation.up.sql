@@ -0,0 +1,6 @@
+ALTER TABLE shorten ADD COLUMN IF NOT EXISTS blocked_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS banned_users (
+    user_id UUID PRIMARY KEY,
+    banned_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
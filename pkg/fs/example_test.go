@@ -63,7 +63,7 @@ func ExampleProducer_WriteRow() {
 	fmt.Println(recordsInFile)
 
 	// Output:
-	// [{test1 x xxx } {test2 x xxx } {test3 x xxx }]
+	// [{test1 x xxx  <nil> false} {test2 x xxx  <nil> false} {test3 x xxx  <nil> false}]
 }
 
 func ExampleConsumer_ReadRow() {
@@ -115,5 +115,5 @@ func ExampleConsumer_ReadRow() {
 	fmt.Println(gotRec)
 
 	// Output:
-	// {test1 x xxx }
+	// {test1 x xxx  <nil> false}
 }
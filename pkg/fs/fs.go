@@ -3,14 +3,18 @@ package fs
 import (
 	"encoding/json"
 	"os"
+	"time"
 )
 
 // Record is a struct helps handle file records
+// Deleted marks the record as a tombstone of ShortURL
 type Record struct {
-	UUID        string `json:"uuid,omitempty"`
-	ShortURL    string `json:"short_url,omitempty"`
-	OriginalURL string `json:"original_url,omitempty"`
-	UserID      string `json:"user_id,omitempty"`
+	UUID        string     `json:"uuid,omitempty"`
+	ShortURL    string     `json:"short_url,omitempty"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Deleted     bool       `json:"deleted,omitempty"`
 }
 
 // NewProducer returns a new instance of Producer
@@ -37,6 +41,11 @@ func (p *Producer) WriteRow(r *Record) error {
 	return p.encoder.Encode(r)
 }
 
+// Sync commits written records to the stable storage
+func (p *Producer) Sync() error {
+	return p.file.Sync()
+}
+
 // Close wrapper of file closer
 func (p *Producer) Close() error {
 	return p.file.Close()
@@ -71,6 +80,11 @@ func (c *Consumer) ReadRow(r *Record) error {
 	return nil
 }
 
+// Offset returns the offset in the file right after the last read Record
+func (c *Consumer) Offset() int64 {
+	return c.decoder.InputOffset()
+}
+
 // Close wrapper of file closer
 func (c *Consumer) Close() error {
 	return c.file.Close()
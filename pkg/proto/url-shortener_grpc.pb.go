@@ -23,9 +23,15 @@ const (
 	URLShortener_Redirect_FullMethodName        = "/shortener.URLShortener/Redirect"
 	URLShortener_ShortenURL_FullMethodName      = "/shortener.URLShortener/ShortenURL"
 	URLShortener_ShortenBatchURL_FullMethodName = "/shortener.URLShortener/ShortenBatchURL"
+	URLShortener_ImportURLs_FullMethodName      = "/shortener.URLShortener/ImportURLs"
 	URLShortener_UsersURLs_FullMethodName       = "/shortener.URLShortener/UsersURLs"
+	URLShortener_ExportURLs_FullMethodName      = "/shortener.URLShortener/ExportURLs"
+	URLShortener_UpdateURL_FullMethodName       = "/shortener.URLShortener/UpdateURL"
+	URLShortener_URLHistory_FullMethodName      = "/shortener.URLShortener/URLHistory"
+	URLShortener_RestoreURL_FullMethodName      = "/shortener.URLShortener/RestoreURL"
 	URLShortener_Delete_FullMethodName          = "/shortener.URLShortener/Delete"
 	URLShortener_Stats_FullMethodName           = "/shortener.URLShortener/Stats"
+	URLShortener_ClickStats_FullMethodName      = "/shortener.URLShortener/ClickStats"
 )
 
 // URLShortenerClient is the client API for URLShortener service.
@@ -36,9 +42,15 @@ type URLShortenerClient interface {
 	Redirect(ctx context.Context, in *RedirectRequest, opts ...grpc.CallOption) (*RedirectResponse, error)
 	ShortenURL(ctx context.Context, in *ShortenURLRequest, opts ...grpc.CallOption) (*ShortenURLResponse, error)
 	ShortenBatchURL(ctx context.Context, in *ShortenBatchURLRequest, opts ...grpc.CallOption) (*ShortenBatchURLResponse, error)
+	ImportURLs(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ImportURLsRequest, ImportURLsResponse], error)
 	UsersURLs(ctx context.Context, in *UsersURLsRequest, opts ...grpc.CallOption) (*UsersURLsResponse, error)
+	ExportURLs(ctx context.Context, in *ExportURLsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportURLsResponse], error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
+	URLHistory(ctx context.Context, in *URLHistoryRequest, opts ...grpc.CallOption) (*URLHistoryResponse, error)
+	RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*RestoreURLResponse, error)
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
 	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	ClickStats(ctx context.Context, in *ClickStatsRequest, opts ...grpc.CallOption) (*ClickStatsResponse, error)
 }
 
 type uRLShortenerClient struct {
@@ -89,6 +101,19 @@ func (c *uRLShortenerClient) ShortenBatchURL(ctx context.Context, in *ShortenBat
 	return out, nil
 }
 
+func (c *uRLShortenerClient) ImportURLs(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ImportURLsRequest, ImportURLsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &URLShortener_ServiceDesc.Streams[0], URLShortener_ImportURLs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportURLsRequest, ImportURLsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_ImportURLsClient = grpc.BidiStreamingClient[ImportURLsRequest, ImportURLsResponse]
+
 func (c *uRLShortenerClient) UsersURLs(ctx context.Context, in *UsersURLsRequest, opts ...grpc.CallOption) (*UsersURLsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(UsersURLsResponse)
@@ -99,6 +124,55 @@ func (c *uRLShortenerClient) UsersURLs(ctx context.Context, in *UsersURLsRequest
 	return out, nil
 }
 
+func (c *uRLShortenerClient) ExportURLs(ctx context.Context, in *ExportURLsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportURLsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &URLShortener_ServiceDesc.Streams[1], URLShortener_ExportURLs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportURLsRequest, ExportURLsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_ExportURLsClient = grpc.ServerStreamingClient[ExportURLsResponse]
+
+func (c *uRLShortenerClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) URLHistory(ctx context.Context, in *URLHistoryRequest, opts ...grpc.CallOption) (*URLHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(URLHistoryResponse)
+	err := c.cc.Invoke(ctx, URLShortener_URLHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*RestoreURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_RestoreURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *uRLShortenerClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(DeleteResponse)
@@ -119,6 +193,16 @@ func (c *uRLShortenerClient) Stats(ctx context.Context, in *StatsRequest, opts .
 	return out, nil
 }
 
+func (c *uRLShortenerClient) ClickStats(ctx context.Context, in *ClickStatsRequest, opts ...grpc.CallOption) (*ClickStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClickStatsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_ClickStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // URLShortenerServer is the server API for URLShortener service.
 // All implementations must embed UnimplementedURLShortenerServer
 // for forward compatibility.
@@ -127,9 +211,15 @@ type URLShortenerServer interface {
 	Redirect(context.Context, *RedirectRequest) (*RedirectResponse, error)
 	ShortenURL(context.Context, *ShortenURLRequest) (*ShortenURLResponse, error)
 	ShortenBatchURL(context.Context, *ShortenBatchURLRequest) (*ShortenBatchURLResponse, error)
+	ImportURLs(grpc.BidiStreamingServer[ImportURLsRequest, ImportURLsResponse]) error
 	UsersURLs(context.Context, *UsersURLsRequest) (*UsersURLsResponse, error)
+	ExportURLs(*ExportURLsRequest, grpc.ServerStreamingServer[ExportURLsResponse]) error
+	UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error)
+	URLHistory(context.Context, *URLHistoryRequest) (*URLHistoryResponse, error)
+	RestoreURL(context.Context, *RestoreURLRequest) (*RestoreURLResponse, error)
 	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
 	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	ClickStats(context.Context, *ClickStatsRequest) (*ClickStatsResponse, error)
 	mustEmbedUnimplementedURLShortenerServer()
 }
 
@@ -152,15 +242,33 @@ func (UnimplementedURLShortenerServer) ShortenURL(context.Context, *ShortenURLRe
 func (UnimplementedURLShortenerServer) ShortenBatchURL(context.Context, *ShortenBatchURLRequest) (*ShortenBatchURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ShortenBatchURL not implemented")
 }
+func (UnimplementedURLShortenerServer) ImportURLs(grpc.BidiStreamingServer[ImportURLsRequest, ImportURLsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportURLs not implemented")
+}
 func (UnimplementedURLShortenerServer) UsersURLs(context.Context, *UsersURLsRequest) (*UsersURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UsersURLs not implemented")
 }
+func (UnimplementedURLShortenerServer) ExportURLs(*ExportURLsRequest, grpc.ServerStreamingServer[ExportURLsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportURLs not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
+func (UnimplementedURLShortenerServer) URLHistory(context.Context, *URLHistoryRequest) (*URLHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method URLHistory not implemented")
+}
+func (UnimplementedURLShortenerServer) RestoreURL(context.Context, *RestoreURLRequest) (*RestoreURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreURL not implemented")
+}
 func (UnimplementedURLShortenerServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
 }
 func (UnimplementedURLShortenerServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
 }
+func (UnimplementedURLShortenerServer) ClickStats(context.Context, *ClickStatsRequest) (*ClickStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClickStats not implemented")
+}
 func (UnimplementedURLShortenerServer) mustEmbedUnimplementedURLShortenerServer() {}
 func (UnimplementedURLShortenerServer) testEmbeddedByValue()                      {}
 
@@ -254,6 +362,13 @@ func _URLShortener_ShortenBatchURL_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_ImportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(URLShortenerServer).ImportURLs(&grpc.GenericServerStream[ImportURLsRequest, ImportURLsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_ImportURLsServer = grpc.BidiStreamingServer[ImportURLsRequest, ImportURLsResponse]
+
 func _URLShortener_UsersURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UsersURLsRequest)
 	if err := dec(in); err != nil {
@@ -272,6 +387,71 @@ func _URLShortener_UsersURLs_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_ExportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(URLShortenerServer).ExportURLs(m, &grpc.GenericServerStream[ExportURLsRequest, ExportURLsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_ExportURLsServer = grpc.ServerStreamingServer[ExportURLsResponse]
+
+func _URLShortener_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_URLHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(URLHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).URLHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_URLHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).URLHistory(ctx, req.(*URLHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_RestoreURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).RestoreURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_RestoreURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).RestoreURL(ctx, req.(*RestoreURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _URLShortener_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteRequest)
 	if err := dec(in); err != nil {
@@ -308,6 +488,24 @@ func _URLShortener_Stats_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_ClickStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClickStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).ClickStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_ClickStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).ClickStats(ctx, req.(*ClickStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // URLShortener_ServiceDesc is the grpc.ServiceDesc for URLShortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -335,6 +533,18 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UsersURLs",
 			Handler:    _URLShortener_UsersURLs_Handler,
 		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _URLShortener_UpdateURL_Handler,
+		},
+		{
+			MethodName: "URLHistory",
+			Handler:    _URLShortener_URLHistory_Handler,
+		},
+		{
+			MethodName: "RestoreURL",
+			Handler:    _URLShortener_RestoreURL_Handler,
+		},
 		{
 			MethodName: "Delete",
 			Handler:    _URLShortener_Delete_Handler,
@@ -343,6 +553,280 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Stats",
 			Handler:    _URLShortener_Stats_Handler,
 		},
+		{
+			MethodName: "ClickStats",
+			Handler:    _URLShortener_ClickStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportURLs",
+			Handler:       _URLShortener_ImportURLs_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportURLs",
+			Handler:       _URLShortener_ExportURLs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/proto/url-shortener.proto",
+}
+
+const (
+	AdminService_URL_FullMethodName       = "/shortener.AdminService/URL"
+	AdminService_DeleteURL_FullMethodName = "/shortener.AdminService/DeleteURL"
+	AdminService_BlockURL_FullMethodName  = "/shortener.AdminService/BlockURL"
+	AdminService_UserURLs_FullMethodName  = "/shortener.AdminService/UserURLs"
+	AdminService_BanUser_FullMethodName   = "/shortener.AdminService/BanUser"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AdminService is available to users having the admin role only
+type AdminServiceClient interface {
+	URL(ctx context.Context, in *AdminURLRequest, opts ...grpc.CallOption) (*AdminURLResponse, error)
+	DeleteURL(ctx context.Context, in *AdminDeleteURLRequest, opts ...grpc.CallOption) (*AdminDeleteURLResponse, error)
+	BlockURL(ctx context.Context, in *AdminBlockURLRequest, opts ...grpc.CallOption) (*AdminBlockURLResponse, error)
+	UserURLs(ctx context.Context, in *AdminUserURLsRequest, opts ...grpc.CallOption) (*UsersURLsResponse, error)
+	BanUser(ctx context.Context, in *AdminBanUserRequest, opts ...grpc.CallOption) (*AdminBanUserResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) URL(ctx context.Context, in *AdminURLRequest, opts ...grpc.CallOption) (*AdminURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminURLResponse)
+	err := c.cc.Invoke(ctx, AdminService_URL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DeleteURL(ctx context.Context, in *AdminDeleteURLRequest, opts ...grpc.CallOption) (*AdminDeleteURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminDeleteURLResponse)
+	err := c.cc.Invoke(ctx, AdminService_DeleteURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) BlockURL(ctx context.Context, in *AdminBlockURLRequest, opts ...grpc.CallOption) (*AdminBlockURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminBlockURLResponse)
+	err := c.cc.Invoke(ctx, AdminService_BlockURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) UserURLs(ctx context.Context, in *AdminUserURLsRequest, opts ...grpc.CallOption) (*UsersURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UsersURLsResponse)
+	err := c.cc.Invoke(ctx, AdminService_UserURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) BanUser(ctx context.Context, in *AdminBanUserRequest, opts ...grpc.CallOption) (*AdminBanUserResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminBanUserResponse)
+	err := c.cc.Invoke(ctx, AdminService_BanUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility.
+//
+// AdminService is available to users having the admin role only
+type AdminServiceServer interface {
+	URL(context.Context, *AdminURLRequest) (*AdminURLResponse, error)
+	DeleteURL(context.Context, *AdminDeleteURLRequest) (*AdminDeleteURLResponse, error)
+	BlockURL(context.Context, *AdminBlockURLRequest) (*AdminBlockURLResponse, error)
+	UserURLs(context.Context, *AdminUserURLsRequest) (*UsersURLsResponse, error)
+	BanUser(context.Context, *AdminBanUserRequest) (*AdminBanUserResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) URL(context.Context, *AdminURLRequest) (*AdminURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method URL not implemented")
+}
+func (UnimplementedAdminServiceServer) DeleteURL(context.Context, *AdminDeleteURLRequest) (*AdminDeleteURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteURL not implemented")
+}
+func (UnimplementedAdminServiceServer) BlockURL(context.Context, *AdminBlockURLRequest) (*AdminBlockURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlockURL not implemented")
+}
+func (UnimplementedAdminServiceServer) UserURLs(context.Context, *AdminUserURLsRequest) (*UsersURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserURLs not implemented")
+}
+func (UnimplementedAdminServiceServer) BanUser(context.Context, *AdminBanUserRequest) (*AdminBanUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanUser not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+func (UnimplementedAdminServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_URL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).URL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_URL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).URL(ctx, req.(*AdminURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DeleteURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminDeleteURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DeleteURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_DeleteURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DeleteURL(ctx, req.(*AdminDeleteURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_BlockURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminBlockURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).BlockURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_BlockURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).BlockURL(ctx, req.(*AdminBlockURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_UserURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminUserURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).UserURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_UserURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).UserURLs(ctx, req.(*AdminUserURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_BanUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminBanUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).BanUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_BanUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).BanUser(ctx, req.(*AdminBanUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "shortener.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "URL",
+			Handler:    _AdminService_URL_Handler,
+		},
+		{
+			MethodName: "DeleteURL",
+			Handler:    _AdminService_DeleteURL_Handler,
+		},
+		{
+			MethodName: "BlockURL",
+			Handler:    _AdminService_BlockURL_Handler,
+		},
+		{
+			MethodName: "UserURLs",
+			Handler:    _AdminService_UserURLs_Handler,
+		},
+		{
+			MethodName: "BanUser",
+			Handler:    _AdminService_BanUser_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/proto/url-shortener.proto",